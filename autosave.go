@@ -0,0 +1,320 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ncruces/zenity"
+)
+
+var (
+	// AutosaveInterval is how often files with unsaved changes are written to
+	// the recovery directory
+	AutosaveInterval = time.Minute
+	lastAutosave     = time.Now()
+
+	// lockInterval is how often the lock file is touched, to show other
+	// instances that this one is still running. A lock which hasn't been
+	// touched for lockTimeout belongs to an instance which has ended
+	lockInterval = 10 * time.Second
+	lockTimeout  = 3 * lockInterval
+	lastLock     time.Time
+)
+
+// recoveryMeta is written next to each autosave so that the file can be
+// restored with its original name and location
+type recoveryMeta struct {
+	Filename string
+	FileDir  string
+	PathDir  string
+	Saved    time.Time
+}
+
+// recoveryDir returns the directory autosaves are written to, creating it if
+// it doesn't exist
+func recoveryDir() (string, error) {
+	cacheDir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	dir := path.Join(cacheDir, "pixel", "recovery")
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", err
+	}
+	return dir, nil
+}
+
+// autosavePath returns the path of the file's autosave without an extension
+func (f *File) autosavePath() (string, error) {
+	dir, err := recoveryDir()
+	if err != nil {
+		return "", err
+	}
+	if f.autosaveID == "" {
+		f.autosaveID = newAutosaveID()
+	}
+	return path.Join(dir, f.autosaveID), nil
+}
+
+// newAutosaveID returns a unique id for an autosave, starting with this
+// instance's pid
+func newAutosaveID() string {
+	return fmt.Sprintf("%d-%d", os.Getpid(), time.Now().UnixNano())
+}
+
+// Autosave writes the file to the recovery directory if it has unsaved
+// changes, or removes its autosave if it doesn't, such as after undoing back
+// to where it was saved
+func (f *File) Autosave() error {
+	if !f.FileChanged {
+		f.RemoveAutosave()
+		return nil
+	}
+
+	base, err := f.autosavePath()
+	if err != nil {
+		return err
+	}
+	if err := writeFileAtomic(base+".pix", f.encodePix); err != nil {
+		return err
+	}
+
+	meta := recoveryMeta{
+		Filename: f.Filename,
+		FileDir:  f.FileDir,
+		PathDir:  f.PathDir,
+		Saved:    time.Now(),
+	}
+	return writeFileAtomic(base+".json", func(w io.Writer) error {
+		return json.NewEncoder(w).Encode(meta)
+	})
+}
+
+// RemoveAutosave removes the file's autosave, if it has one
+func (f *File) RemoveAutosave() {
+	if f.autosaveID == "" {
+		return
+	}
+	base, err := f.autosavePath()
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	os.Remove(base + ".json")
+	os.Remove(base + ".pix")
+}
+
+// lockPath returns the path of the lock file of the instance with the pid
+func lockPath(dir string, pid int) string {
+	return path.Join(dir, fmt.Sprintf("%d.lock", pid))
+}
+
+// TouchLock writes this instance's lock file once lockInterval has passed
+// since it was last written, so that other instances leave its autosaves
+// alone
+func TouchLock() {
+	if time.Since(lastLock) < lockInterval {
+		return
+	}
+	lastLock = time.Now()
+
+	dir, err := recoveryDir()
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	if err := os.WriteFile(lockPath(dir, os.Getpid()), nil, 0600); err != nil {
+		log.Println(err)
+	}
+}
+
+// removeStaleLocks removes the lock files which haven't been touched for
+// lockTimeout, left behind by instances which crashed
+func removeStaleLocks(dir string) {
+	lockPaths, err := filepath.Glob(path.Join(dir, "*.lock"))
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	for _, p := range lockPaths {
+		if p == lockPath(dir, os.Getpid()) {
+			continue
+		}
+		if info, err := os.Stat(p); err == nil && time.Since(info.ModTime()) >= lockTimeout {
+			os.Remove(p)
+		}
+	}
+}
+
+// RemoveLock removes this instance's lock file when it closes
+func RemoveLock() {
+	dir, err := recoveryDir()
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	os.Remove(lockPath(dir, os.Getpid()))
+}
+
+// AutosaveFiles autosaves every file once AutosaveInterval has passed since
+// the last autosave. It's called every frame
+func AutosaveFiles() {
+	TouchLock()
+	if time.Since(lastAutosave) < AutosaveInterval {
+		return
+	}
+	lastAutosave = time.Now()
+
+	for _, file := range Files {
+		if err := file.Autosave(); err != nil {
+			log.Println(err)
+		}
+	}
+}
+
+// RecoverableSession is an autosave left behind by a session which ended
+// without saving
+type RecoverableSession struct {
+	recoveryMeta
+	id  string
+	dir string
+}
+
+// FindRecoverableSessions returns every autosave in the recovery directory
+// which doesn't belong to another running instance. Stale lock files are
+// removed
+func FindRecoverableSessions() ([]RecoverableSession, error) {
+	dir, err := recoveryDir()
+	if err != nil {
+		return nil, err
+	}
+	removeStaleLocks(dir)
+	metaPaths, err := filepath.Glob(path.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+
+	sessions := make([]RecoverableSession, 0, len(metaPaths))
+	for _, metaPath := range metaPaths {
+		s := RecoverableSession{
+			id:  strings.TrimSuffix(path.Base(metaPath), ".json"),
+			dir: dir,
+		}
+
+		data, err := os.ReadFile(metaPath)
+		if err != nil {
+			log.Println(err)
+			continue
+		}
+		if err := json.Unmarshal(data, &s.recoveryMeta); err != nil {
+			log.Println(err)
+			continue
+		}
+		if _, err := os.Stat(s.pixPath()); err != nil {
+			log.Println(err)
+			continue
+		}
+		if s.running() {
+			continue
+		}
+		sessions = append(sessions, s)
+	}
+
+	return sessions, nil
+}
+
+// running returns true if the instance which made the autosave is still
+// running. The id starts with the instance's pid
+func (s RecoverableSession) running() bool {
+	pid, err := strconv.Atoi(strings.SplitN(s.id, "-", 2)[0])
+	if err != nil || pid == os.Getpid() {
+		return false
+	}
+	info, err := os.Stat(lockPath(s.dir, pid))
+	return err == nil && time.Since(info.ModTime()) < lockTimeout
+}
+
+func (s RecoverableSession) pixPath() string {
+	return path.Join(s.dir, s.id+".pix")
+}
+
+// Recover opens the autosave as an unsaved file with the original name and
+// location. The autosave is kept until the file is saved, renamed to belong
+// to this instance
+func (s RecoverableSession) Recover() (*File, error) {
+	f, err := Open(s.pixPath())
+	if err != nil {
+		return nil, err
+	}
+	f.Filename = s.Filename
+	f.FileDir = s.FileDir
+	f.PathDir = s.PathDir
+	f.markChanged()
+	f.autosaveID = s.id
+	if id := newAutosaveID(); os.Rename(s.pixPath(), path.Join(s.dir, id+".pix")) == nil {
+		os.Rename(path.Join(s.dir, s.id+".json"), path.Join(s.dir, id+".json"))
+		f.autosaveID = id
+	}
+	EditorsUIRebuild()
+	return f, nil
+}
+
+// Discard removes the autosave
+func (s RecoverableSession) Discard() {
+	os.Remove(path.Join(s.dir, s.id+".json"))
+	os.Remove(s.pixPath())
+}
+
+// OfferRecovery asks if the autosaves from previous sessions should be
+// recovered, discarded or kept for later. Closing the dialog keeps them.
+// Recovered files are added to Files
+func OfferRecovery() {
+	sessions, err := FindRecoverableSessions()
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	if len(sessions) == 0 {
+		return
+	}
+
+	names := make([]string, 0, len(sessions))
+	for _, s := range sessions {
+		names = append(names, fmt.Sprintf("%s (%s)", s.Filename, s.Saved.Format("2006-01-02 15:04")))
+	}
+
+	err = zenity.Question(
+		"These files have unsaved changes from a previous session:\n\n"+strings.Join(names, "\n"),
+		zenity.Title("Recover unsaved files"),
+		zenity.OKLabel("Recover"),
+		zenity.CancelLabel("Later"),
+		zenity.ExtraButton("Discard"),
+	)
+	switch {
+	case err == nil:
+		for _, s := range sessions {
+			f, err := s.Recover()
+			if err != nil {
+				log.Println(err)
+				continue
+			}
+			Files = append(Files, f)
+		}
+	case errors.Is(err, zenity.ErrExtraButton):
+		for _, s := range sessions {
+			s.Discard()
+		}
+	case !errors.Is(err, zenity.ErrCanceled):
+		// Closing the dialog keeps the autosaves for later
+		log.Println(err)
+	}
+}
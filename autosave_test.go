@@ -0,0 +1,54 @@
+package main
+
+import (
+	"os"
+	"testing"
+	"time"
+)
+
+// TestAutosaveCleanup checks that the autosave is removed once undoing brings
+// the file back to where it was saved, and that stale locks are removed
+func TestAutosaveCleanup(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	dir, err := recoveryDir()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	f := newTestFile(t)
+	f.AddNewLayer()
+	if err := f.Autosave(); err != nil {
+		t.Fatal(err)
+	}
+	base, _ := f.autosavePath()
+	if _, err := os.Stat(base + ".pix"); err != nil {
+		t.Fatal("The changed file wasn't autosaved")
+	}
+	f.Undo()
+	if err := f.Autosave(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(base + ".pix"); err == nil {
+		t.Fatal("The autosave was kept after undoing back to the saved file")
+	}
+
+	stale, fresh := lockPath(dir, 1), lockPath(dir, 2)
+	for _, p := range []string{stale, fresh} {
+		if err := os.WriteFile(p, nil, 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	old := time.Now().Add(-2 * lockTimeout)
+	if err := os.Chtimes(stale, old, old); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := FindRecoverableSessions(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(stale); err == nil {
+		t.Error("The stale lock wasn't removed")
+	}
+	if _, err := os.Stat(fresh); err != nil {
+		t.Error("The lock of a running instance was removed")
+	}
+}
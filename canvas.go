@@ -0,0 +1,540 @@
+package main
+
+import (
+	"fmt"
+
+	rl "github.com/gen2brain/raylib-go/raylib"
+)
+
+// MaxCanvasSize is the largest width or height the image can be scaled to, or
+// a selection transformed to. Every pixel is allocated, so larger sizes can
+// run out of memory
+const MaxCanvasSize = 4096
+
+// imageChange is a change to every layer of the image
+type imageChange struct {
+	name                  string
+	width, height         int32
+	tileWidth, tileHeight int32
+	// pixels returns a layer's pixels after the change
+	pixels func(pixels map[IntVec2]rl.Color) map[IntVec2]rl.Color
+	// point returns where a point between pixels ends up, such as the top
+	// left of a tile or a guide
+	point func(x, y int32) (int32, int32)
+	// tile returns the index a tile ends up at, if the tiles aren't moved by
+	// point
+	tile func(index int32) int32
+	// grid returns the reference grid after the change
+	grid func(grid ReferenceGrid) ReferenceGrid
+}
+
+// changeImage changes every layer as one history action. Animations are
+// moved to the tiles their first and last frames end up on, guides are moved
+// with the pixels or removed if they're off the canvas, and selection masks
+// are changed like the layers
+func (f *File) changeImage(change imageChange) {
+	f.CommitSelection()
+
+	layerStates := make([][]byte, 0, len(f.Layers))
+	prevAnimations := f.GetAnimationState()
+	prevGuides := f.Guides
+	prevGrid := f.ReferenceGrid
+	prevMasks := f.SelectionMasks
+	prevTileWidth, prevTileHeight := f.TileWidth, f.TileHeight
+	prevWidth, prevHeight := f.CanvasWidth, f.CanvasHeight
+
+	// Where each animation's frames are before the change
+	frames := make([][2]IntVec2, len(f.Animations))
+	for i, anim := range f.Animations {
+		frames[i] = [2]IntVec2{f.TileOrigin(anim.FrameStart), f.TileOrigin(anim.FrameEnd)}
+	}
+
+	f.CanvasWidth, f.CanvasHeight = change.width, change.height
+	f.CanvasWidthResizePreview, f.CanvasHeightResizePreview = change.width, change.height
+	for _, layer := range f.Layers {
+		prev := layer.PixelData
+		layer.PixelData = change.pixels(layer.PixelData)
+		layer.Resize(change.width, change.height, ResizeTL)
+		layerStates = append(layerStates, diffPixels(prev, layer.PixelData))
+	}
+	f.RenderLayer.Resize(change.width, change.height, ResizeTL)
+	f.setTileSize(MinInt32(change.tileWidth, change.width), MinInt32(change.tileHeight, change.height))
+
+	// A tile's top left can end up on any of its corners
+	tileAt := func(origin IntVec2) int32 {
+		x0, y0 := change.point(origin.X, origin.Y)
+		x1, y1 := change.point(origin.X+prevTileWidth, origin.Y+prevTileHeight)
+		x, y := MinInt32(x0, x1), MinInt32(y0, y1)
+		perRow := MaxInt32(f.CanvasWidth/f.TileWidth, 1)
+		index := MaxInt32(y, 0)/f.TileHeight*perRow + MinInt32(MaxInt32(x, 0)/f.TileWidth, perRow-1)
+		return MinInt32(index, MaxInt32(f.TileCount()-1, 0))
+	}
+	for i, anim := range f.Animations {
+		var start, end int32
+		if change.tile != nil {
+			last := MaxInt32(f.TileCount()-1, 0)
+			start, end = MinInt32(change.tile(anim.FrameStart), last), MinInt32(change.tile(anim.FrameEnd), last)
+		} else {
+			start, end = tileAt(frames[i][0]), tileAt(frames[i][1])
+		}
+		anim.FrameStart, anim.FrameEnd = MinInt32(start, end), MaxInt32(start, end)
+	}
+
+	guides := make([]Guide, 0, len(f.Guides))
+	for _, guide := range f.Guides {
+		x0, y0, x1, y1 := guide.Position, int32(0), guide.Position, prevHeight
+		if !guide.Vertical {
+			x0, y0, x1, y1 = 0, guide.Position, prevWidth, guide.Position
+		}
+		x0, y0 = change.point(x0, y0)
+		x1, y1 = change.point(x1, y1)
+		moved := Guide{Vertical: x0 == x1, Position: x0}
+		size := f.CanvasWidth
+		if !moved.Vertical {
+			moved.Position, size = y0, f.CanvasHeight
+		}
+		if moved.Position >= 0 && moved.Position <= size {
+			guides = append(guides, moved)
+		}
+	}
+	f.Guides = guides
+
+	masks := make([]SelectionMask, len(f.SelectionMasks))
+	for i, mask := range f.SelectionMasks {
+		pixels := make(map[IntVec2]rl.Color, len(mask.Pixels))
+		for loc := range mask.Pixels {
+			pixels[loc] = rl.White
+		}
+		masks[i] = SelectionMask{Name: mask.Name, Pixels: make(map[IntVec2]bool)}
+		for loc, color := range change.pixels(pixels) {
+			if color.A >= 128 && loc.X >= 0 && loc.Y >= 0 && loc.X < f.CanvasWidth && loc.Y < f.CanvasHeight {
+				masks[i].Pixels[loc] = true
+			}
+		}
+	}
+	f.SelectionMasks = masks
+
+	if change.grid != nil {
+		f.ReferenceGrid = change.grid(f.ReferenceGrid)
+	}
+
+	f.AppendHistory(HistoryImage{
+		HistoryResize:     HistoryResize{layerStates, prevWidth, prevHeight, f.CanvasWidth, f.CanvasHeight},
+		Name:              change.name,
+		PrevTileWidth:     prevTileWidth,
+		PrevTileHeight:    prevTileHeight,
+		CurrentTileWidth:  f.TileWidth,
+		CurrentTileHeight: f.TileHeight,
+		PrevAnimations:    prevAnimations,
+		CurrentAnimations: f.GetAnimationState(),
+		PrevGuides:        prevGuides,
+		CurrentGuides:     f.Guides,
+		PrevGrid:          prevGrid,
+		CurrentGrid:       f.ReferenceGrid,
+		PrevMasks:         prevMasks,
+		CurrentMasks:      f.SelectionMasks,
+	})
+
+	f.RedrawRenderLayer()
+	LayersUIRebuildList()
+}
+
+// ScaleImage enlarges the image by a whole number of times. Tiles, guides
+// and the grid are enlarged with it
+func (f *File) ScaleImage(factor int32, mode ScaleMode) error {
+	if factor < 2 {
+		return fmt.Errorf("Couldn't scale image: The scale has to be at least 2")
+	}
+	if factor > MaxCanvasSize/MaxInt32(f.CanvasWidth, f.CanvasHeight) {
+		return fmt.Errorf("Couldn't scale image: The image can't be larger than %dx%d", MaxCanvasSize, MaxCanvasSize)
+	}
+	width, height := f.CanvasWidth, f.CanvasHeight
+	f.changeImage(imageChange{
+		name:       fmt.Sprintf("Scale %dx with %s", factor, mode),
+		width:      width * factor,
+		height:     height * factor,
+		tileWidth:  f.TileWidth * factor,
+		tileHeight: f.TileHeight * factor,
+		pixels: func(pixels map[IntVec2]rl.Color) map[IntVec2]rl.Color {
+			grid := newTransformGrid(width, height)
+			for y := int32(0); y < height; y++ {
+				for x := int32(0); x < width; x++ {
+					grid.Cells[y*width+x] = transformCell{pixels[IntVec2{x, y}], true}
+				}
+			}
+			grid = grid.scale(width*factor, height*factor, mode)
+
+			scaled := make(map[IntVec2]rl.Color, len(grid.Cells))
+			for i, cell := range grid.Cells {
+				if cell.Color != rl.Blank {
+					scaled[IntVec2{int32(i) % grid.Width, int32(i) / grid.Width}] = cell.Color
+				}
+			}
+			return scaled
+		},
+		point: func(x, y int32) (int32, int32) {
+			return x * factor, y * factor
+		},
+		grid: func(grid ReferenceGrid) ReferenceGrid {
+			grid.Width *= factor
+			grid.Height *= factor
+			grid.OffsetX *= factor
+			grid.OffsetY *= factor
+			grid.IsoWidth *= factor
+			return grid
+		},
+	})
+	return nil
+}
+
+// crop cuts the image down to the rectangle
+func (f *File) crop(name string, x0, y0, width, height int32) {
+	f.changeImage(imageChange{
+		name:       name,
+		width:      width,
+		height:     height,
+		tileWidth:  f.TileWidth,
+		tileHeight: f.TileHeight,
+		pixels: func(pixels map[IntVec2]rl.Color) map[IntVec2]rl.Color {
+			cropped := make(map[IntVec2]rl.Color)
+			for loc, color := range pixels {
+				if loc.X >= x0 && loc.Y >= y0 && loc.X < x0+width && loc.Y < y0+height {
+					cropped[IntVec2{loc.X - x0, loc.Y - y0}] = color
+				}
+			}
+			return cropped
+		},
+		point: func(x, y int32) (int32, int32) {
+			return x - x0, y - y0
+		},
+		grid: func(grid ReferenceGrid) ReferenceGrid {
+			grid.OffsetX -= x0
+			grid.OffsetY -= y0
+			return grid
+		},
+	})
+}
+
+// CropToSelection cuts the image down to the selection's bounds
+func (f *File) CropToSelection() error {
+	if !f.DoingSelection || len(f.Selection) == 0 {
+		return fmt.Errorf("Couldn't crop: Nothing is selected")
+	}
+	b := f.SelectionBounds
+	x0 := MaxInt32(MinInt32(b[0], b[2]), 0)
+	y0 := MaxInt32(MinInt32(b[1], b[3]), 0)
+	x1 := MinInt32(MaxInt32(b[0], b[2]), f.CanvasWidth-1)
+	y1 := MinInt32(MaxInt32(b[1], b[3]), f.CanvasHeight-1)
+	if x1 < x0 || y1 < y0 {
+		return fmt.Errorf("Couldn't crop: The selection is off the canvas")
+	}
+	f.crop("Crop to selection", x0, y0, x1-x0+1, y1-y0+1)
+	return nil
+}
+
+// TrimImage cuts off the rows and columns which are transparent on every
+// layer
+func (f *File) TrimImage() error {
+	x0, y0, x1, y1 := f.CanvasWidth, f.CanvasHeight, int32(-1), int32(-1)
+	for _, layer := range f.Layers {
+		if layer.IsGroup {
+			continue
+		}
+		for loc, color := range layer.PixelData {
+			if color.A == 0 {
+				continue
+			}
+			x0, y0 = MinInt32(x0, loc.X), MinInt32(y0, loc.Y)
+			x1, y1 = MaxInt32(x1, loc.X), MaxInt32(y1, loc.Y)
+		}
+	}
+	if x1 < 0 {
+		return fmt.Errorf("Couldn't trim: The image is empty")
+	}
+	if x0 == 0 && y0 == 0 && x1 == f.CanvasWidth-1 && y1 == f.CanvasHeight-1 {
+		return nil
+	}
+	f.crop("Trim", x0, y0, x1-x0+1, y1-y0+1)
+	return nil
+}
+
+// RotateImage turns the image clockwise by a number of quarter turns. The
+// tile size is swapped for quarter and three quarter turns
+func (f *File) RotateImage(turns int32) {
+	turns = (turns%4 + 4) % 4
+	if turns == 0 {
+		return
+	}
+	width, height := f.CanvasWidth, f.CanvasHeight
+	tileWidth, tileHeight := f.TileWidth, f.TileHeight
+	if turns%2 == 1 {
+		width, height = height, width
+		tileWidth, tileHeight = tileHeight, tileWidth
+	}
+
+	// point turns a point between pixels, the pixel at x, y turns with its
+	// bottom right corner at x+1, y+1
+	w, h := f.CanvasWidth, f.CanvasHeight
+	point := func(x, y int32) (int32, int32) {
+		switch turns {
+		case 1:
+			return h - y, x
+		case 2:
+			return w - x, h - y
+		}
+		return y, w - x
+	}
+
+	f.changeImage(imageChange{
+		name:       fmt.Sprintf("Rotate %d", turns*90),
+		width:      width,
+		height:     height,
+		tileWidth:  tileWidth,
+		tileHeight: tileHeight,
+		pixels: func(pixels map[IntVec2]rl.Color) map[IntVec2]rl.Color {
+			rotated := make(map[IntVec2]rl.Color, len(pixels))
+			for loc, color := range pixels {
+				x0, y0 := point(loc.X, loc.Y)
+				x1, y1 := point(loc.X+1, loc.Y+1)
+				rotated[IntVec2{MinInt32(x0, x1), MinInt32(y0, y1)}] = color
+			}
+			return rotated
+		},
+		point: point,
+		grid: func(grid ReferenceGrid) ReferenceGrid {
+			if turns%2 == 1 {
+				grid.Width, grid.Height = grid.Height, grid.Width
+				grid.OffsetX, grid.OffsetY = grid.OffsetY, grid.OffsetX
+			}
+			return grid
+		},
+	})
+}
+
+// RegridImage moves each tile into a layout of cells of the new size, with
+// columns cells on each row. Sprites are placed in their cell by the anchor
+// and cropped if the cell is smaller. Tiles stay in the same order so
+// animations keep pointing at the same sprites. Pixels outside of whole
+// tiles are lost
+func (f *File) RegridImage(tileWidth, tileHeight, columns int32, anchor ResizeDirection) error {
+	if tileWidth < 1 || tileHeight < 1 || columns < 1 {
+		return fmt.Errorf("Couldn't re-grid: The tile size and columns have to be at least 1")
+	}
+	count := f.TileCount()
+	if count == 0 {
+		return fmt.Errorf("Couldn't re-grid: The canvas is smaller than a tile")
+	}
+
+	prevTileWidth, prevTileHeight := f.TileWidth, f.TileHeight
+	origins := make([]IntVec2, count)
+	for i := range origins {
+		origins[i] = f.TileOrigin(int32(i))
+	}
+
+	// The anchor's side is -1, 0 or 1 across and down
+	place := func(space, side int32) int32 {
+		return space * (side + 1) / 2
+	}
+	offsetX := place(tileWidth-prevTileWidth, int32(anchor)%3-1)
+	offsetY := place(tileHeight-prevTileHeight, int32(anchor)/3-1)
+	rows := (count + columns - 1) / columns
+
+	f.changeImage(imageChange{
+		name:       fmt.Sprintf("Re-grid to %dx%d", tileWidth, tileHeight),
+		width:      MinInt32(columns, count) * tileWidth,
+		height:     rows * tileHeight,
+		tileWidth:  tileWidth,
+		tileHeight: tileHeight,
+		pixels: func(pixels map[IntVec2]rl.Color) map[IntVec2]rl.Color {
+			moved := make(map[IntVec2]rl.Color)
+			for i, origin := range origins {
+				cellX, cellY := int32(i)%columns*tileWidth, int32(i)/columns*tileHeight
+				for y := int32(0); y < prevTileHeight; y++ {
+					for x := int32(0); x < prevTileWidth; x++ {
+						color, ok := pixels[IntVec2{origin.X + x, origin.Y + y}]
+						cx, cy := x+offsetX, y+offsetY
+						if ok && cx >= 0 && cy >= 0 && cx < tileWidth && cy < tileHeight {
+							moved[IntVec2{cellX + cx, cellY + cy}] = color
+						}
+					}
+				}
+			}
+			return moved
+		},
+		point: func(x, y int32) (int32, int32) {
+			return x, y
+		},
+		tile: func(index int32) int32 {
+			return index
+		},
+	})
+	return nil
+}
+
+// reorderTiles lays the tiles out again in the order, where each entry is
+// the index of the tile to put there or -1 for a blank tile. Rows are added
+// if there are more tiles than fit. Animations follow their tiles, frames on
+// tiles which are gone move to the tile after
+func (f *File) reorderTiles(name string, order []int32) {
+	count := f.TileCount()
+	tileWidth, tileHeight := f.TileWidth, f.TileHeight
+	columns := MaxInt32(f.CanvasWidth/tileWidth, 1)
+	rows := (int32(len(order)) + columns - 1) / columns
+
+	moved := make([]int32, count)
+	for i := range moved {
+		moved[i] = -1
+	}
+	for i, index := range order {
+		if index >= 0 && moved[index] < 0 {
+			moved[index] = int32(i)
+		}
+	}
+	next := int32(len(order))
+	for i := count - 1; i >= 0; i-- {
+		if moved[i] < 0 {
+			moved[i] = next
+		}
+		next = moved[i]
+	}
+
+	f.changeImage(imageChange{
+		name:       name,
+		width:      f.CanvasWidth,
+		height:     MaxInt32(f.CanvasHeight, rows*tileHeight),
+		tileWidth:  tileWidth,
+		tileHeight: tileHeight,
+		pixels: func(pixels map[IntVec2]rl.Color) map[IntVec2]rl.Color {
+			slot := func(index int32) IntVec2 {
+				return IntVec2{index % columns * tileWidth, index / columns * tileHeight}
+			}
+
+			// Pixels outside of the tiles are kept
+			reordered := make(map[IntVec2]rl.Color, len(pixels))
+			for loc, color := range pixels {
+				reordered[loc] = color
+			}
+			for i := int32(0); i < MaxInt32(count, int32(len(order))); i++ {
+				origin := slot(i)
+				for y := int32(0); y < tileHeight; y++ {
+					for x := int32(0); x < tileWidth; x++ {
+						delete(reordered, IntVec2{origin.X + x, origin.Y + y})
+					}
+				}
+			}
+
+			for i, index := range order {
+				if index < 0 {
+					continue
+				}
+				from, to := slot(index), slot(int32(i))
+				for y := int32(0); y < tileHeight; y++ {
+					for x := int32(0); x < tileWidth; x++ {
+						if color, ok := pixels[IntVec2{from.X + x, from.Y + y}]; ok {
+							reordered[IntVec2{to.X + x, to.Y + y}] = color
+						}
+					}
+				}
+			}
+			return reordered
+		},
+		point: func(x, y int32) (int32, int32) {
+			return x, y
+		},
+		tile: func(index int32) int32 {
+			if index < 0 || index >= count {
+				return index
+			}
+			return moved[index]
+		},
+	})
+}
+
+// tileRange returns an error if the tiles first to last don't exist
+func (f *File) tileRange(first, last int32) error {
+	if first < 0 || last < first || last >= f.TileCount() {
+		return fmt.Errorf("Tiles %d to %d don't exist", first, last)
+	}
+	return nil
+}
+
+// tileOrder returns the indexes of every tile in order
+func (f *File) tileOrder() []int32 {
+	order := make([]int32, f.TileCount())
+	for i := range order {
+		order[i] = int32(i)
+	}
+	return order
+}
+
+// MoveTiles moves the tiles first to last so that they start at to, the
+// tiles in between shift over to make room
+func (f *File) MoveTiles(first, last, to int32) error {
+	if err := f.tileRange(first, last); err != nil {
+		return err
+	}
+	order := f.tileOrder()
+	moving := append([]int32{}, order[first:last+1]...)
+	rest := append(append([]int32{}, order[:first]...), order[last+1:]...)
+	to = MinInt32(MaxInt32(to, 0), int32(len(rest)))
+	if to == first {
+		return nil
+	}
+	order = append(append(append([]int32{}, rest[:to]...), moving...), rest[to:]...)
+	f.reorderTiles("Move tiles", order)
+	return nil
+}
+
+// SwapTiles swaps two tiles
+func (f *File) SwapTiles(a, b int32) error {
+	if err := f.tileRange(MinInt32(a, b), MaxInt32(a, b)); err != nil {
+		return err
+	}
+	if a == b {
+		return nil
+	}
+	order := f.tileOrder()
+	order[a], order[b] = order[b], order[a]
+	f.reorderTiles("Swap tiles", order)
+	return nil
+}
+
+// InsertTile inserts a blank tile before the tile at, the tiles after it
+// shift along
+func (f *File) InsertTile(at int32) error {
+	count := f.TileCount()
+	if at < 0 || at > count {
+		return fmt.Errorf("Couldn't insert a tile at %d", at)
+	}
+	order := f.tileOrder()
+	order = append(append(append([]int32{}, order[:at]...), -1), order[at:]...)
+	f.reorderTiles("Insert tile", order)
+	return nil
+}
+
+// DeleteTiles removes the tiles first to last, the tiles after them shift
+// back and blank tiles are left at the end
+func (f *File) DeleteTiles(first, last int32) error {
+	if err := f.tileRange(first, last); err != nil {
+		return err
+	}
+	order := f.tileOrder()
+	order = append(append([]int32{}, order[:first]...), order[last+1:]...)
+	for int32(len(order)) < f.TileCount() {
+		order = append(order, -1)
+	}
+	f.reorderTiles("Delete tiles", order)
+	return nil
+}
+
+// DuplicateTiles inserts a copy of the tiles first to last after them
+func (f *File) DuplicateTiles(first, last int32) error {
+	if err := f.tileRange(first, last); err != nil {
+		return err
+	}
+	order := f.tileOrder()
+	copies := append([]int32{}, order[first:last+1]...)
+	order = append(append(append([]int32{}, order[:last+1]...), copies...), order[last+1:]...)
+	f.reorderTiles("Duplicate tiles", order)
+	return nil
+}
@@ -0,0 +1,48 @@
+package main
+
+import "testing"
+
+// TestChangeImageSelectionMasks checks that saved selection masks are
+// changed with the image and put back by undoing
+func TestChangeImageSelectionMasks(t *testing.T) {
+	f := newTestFile(t)
+	f.SelectionMasks = []SelectionMask{{Name: "mask", Pixels: map[IntVec2]bool{{0, 1}: true}}}
+
+	f.RotateImage(1)
+	want := IntVec2{f.CanvasWidth - 2, 0}
+	if pixels := f.SelectionMasks[0].Pixels; len(pixels) != 1 || !pixels[want] {
+		t.Fatalf("The rotated mask is %v, want %v", pixels, want)
+	}
+
+	f.Undo()
+	if pixels := f.SelectionMasks[0].Pixels; len(pixels) != 1 || !pixels[IntVec2{0, 1}] {
+		t.Fatalf("Undoing didn't restore the mask, got %v", pixels)
+	}
+}
+
+// TestScaleImageMaxSize checks that the image can't be scaled past
+// MaxCanvasSize
+func TestScaleImageMaxSize(t *testing.T) {
+	f := newTestFile(t)
+	if err := f.ScaleImage(MaxCanvasSize, ScaleModeNearest); err == nil {
+		t.Fatal("Scaling past the largest size succeeded")
+	}
+	if f.CanvasWidth != 32 {
+		t.Fatalf("The canvas is %d wide after the failed scale, want 32", f.CanvasWidth)
+	}
+}
+
+// TestTransformMaxSize checks that the selection can't be transformed past
+// MaxCanvasSize
+func TestTransformMaxSize(t *testing.T) {
+	f := newTestFile(t)
+	f.SelectPixels(map[IntVec2]bool{{0, 0}: true}, SelectOpReplace)
+	tr := f.BeginSelectionTransform()
+	tr.Params.Width = MaxCanvasSize * 1000
+	if err := f.UpdateSelectionTransform(); err == nil {
+		t.Error("Transforming past the largest size succeeded")
+	}
+	if tr.Params.Width != MaxCanvasSize {
+		t.Errorf("The transform is %d wide, want %d", tr.Params.Width, MaxCanvasSize)
+	}
+}
@@ -0,0 +1,393 @@
+package main
+
+import (
+	"math"
+
+	rl "github.com/gen2brain/raylib-go/raylib"
+)
+
+// RampInterpolation is the color space used when generating a ramp
+type RampInterpolation int32
+
+// Ramp interpolation modes
+const (
+	RampInterpolationRGB RampInterpolation = iota
+	RampInterpolationHSV
+	RampInterpolationOKLab
+)
+
+func (r RampInterpolation) String() string {
+	switch r {
+	case RampInterpolationRGB:
+		return "RGB"
+	case RampInterpolationHSV:
+		return "HSV"
+	case RampInterpolationOKLab:
+		return "OKLab"
+	}
+	return "unknown"
+}
+
+// clamp01 limits v to the range 0 to 1
+func clamp01(v float64) float64 {
+	if v < 0 {
+		return 0
+	}
+	if v > 1 {
+		return 1
+	}
+	return v
+}
+
+// wrapHue keeps the hue within 0 to 360
+func wrapHue(h float64) float64 {
+	h = math.Mod(h, 360)
+	if h < 0 {
+		h += 360
+	}
+	return h
+}
+
+// floatToUint8 converts a 0 to 1 value into a 0 to 255 value
+func floatToUint8(v float64) uint8 {
+	return uint8(math.Round(clamp01(v) * 255))
+}
+
+// ColorToHSV converts a color into hue (0-360), saturation and value (0-1)
+func ColorToHSV(c rl.Color) (h, s, v float64) {
+	r := float64(c.R) / 255
+	g := float64(c.G) / 255
+	b := float64(c.B) / 255
+
+	max := math.Max(r, math.Max(g, b))
+	min := math.Min(r, math.Min(g, b))
+	d := max - min
+
+	v = max
+	if max > 0 {
+		s = d / max
+	}
+	h = rgbHue(r, g, b, max, d)
+	return h, s, v
+}
+
+// HSVToColor converts hue (0-360), saturation and value (0-1) into a color
+func HSVToColor(h, s, v float64, a uint8) rl.Color {
+	h = wrapHue(h)
+	s = clamp01(s)
+	v = clamp01(v)
+
+	c := v * s
+	x := c * (1 - math.Abs(math.Mod(h/60, 2)-1))
+	r, g, b := hueSector(h, c, x)
+	m := v - c
+	return rl.NewColor(floatToUint8(r+m), floatToUint8(g+m), floatToUint8(b+m), a)
+}
+
+// ColorToHSL converts a color into hue (0-360), saturation and lightness (0-1)
+func ColorToHSL(c rl.Color) (h, s, l float64) {
+	r := float64(c.R) / 255
+	g := float64(c.G) / 255
+	b := float64(c.B) / 255
+
+	max := math.Max(r, math.Max(g, b))
+	min := math.Min(r, math.Min(g, b))
+	d := max - min
+
+	l = (max + min) / 2
+	if d > 0 {
+		s = d / (1 - math.Abs(2*l-1))
+	}
+	h = rgbHue(r, g, b, max, d)
+	return h, s, l
+}
+
+// HSLToColor converts hue (0-360), saturation and lightness (0-1) into a color
+func HSLToColor(h, s, l float64, a uint8) rl.Color {
+	h = wrapHue(h)
+	s = clamp01(s)
+	l = clamp01(l)
+
+	c := (1 - math.Abs(2*l-1)) * s
+	x := c * (1 - math.Abs(math.Mod(h/60, 2)-1))
+	r, g, b := hueSector(h, c, x)
+	m := l - c/2
+	return rl.NewColor(floatToUint8(r+m), floatToUint8(g+m), floatToUint8(b+m), a)
+}
+
+// rgbHue returns the hue shared by HSV and HSL
+func rgbHue(r, g, b, max, d float64) float64 {
+	if d == 0 {
+		return 0
+	}
+	var h float64
+	switch max {
+	case r:
+		h = math.Mod((g-b)/d, 6)
+	case g:
+		h = (b-r)/d + 2
+	default:
+		h = (r-g)/d + 4
+	}
+	return wrapHue(h * 60)
+}
+
+// hueSector returns the unscaled rgb values for a hue
+func hueSector(h, c, x float64) (r, g, b float64) {
+	switch {
+	case h < 60:
+		return c, x, 0
+	case h < 120:
+		return x, c, 0
+	case h < 180:
+		return 0, c, x
+	case h < 240:
+		return 0, x, c
+	case h < 300:
+		return x, 0, c
+	}
+	return c, 0, x
+}
+
+// srgbToLinear removes the gamma from an sRGB channel
+func srgbToLinear(c float64) float64 {
+	if c <= 0.04045 {
+		return c / 12.92
+	}
+	return math.Pow((c+0.055)/1.055, 2.4)
+}
+
+// linearToSrgb applies the gamma to a linear channel
+func linearToSrgb(c float64) float64 {
+	if c <= 0.0031308 {
+		return c * 12.92
+	}
+	return 1.055*math.Pow(c, 1/2.4) - 0.055
+}
+
+// ColorToOKLab converts a color into the OKLab color space
+// https://bottosson.github.io/posts/oklab/
+func ColorToOKLab(c rl.Color) (l, a, b float64) {
+	r := srgbToLinear(float64(c.R) / 255)
+	g := srgbToLinear(float64(c.G) / 255)
+	bl := srgbToLinear(float64(c.B) / 255)
+
+	lc := math.Cbrt(0.4122214708*r + 0.5363325363*g + 0.0514459929*bl)
+	mc := math.Cbrt(0.2119034982*r + 0.6806995451*g + 0.1073969566*bl)
+	sc := math.Cbrt(0.0883024619*r + 0.2817188376*g + 0.6299787005*bl)
+
+	l = 0.2104542553*lc + 0.7936177850*mc - 0.0040720468*sc
+	a = 1.9779984951*lc - 2.4285922050*mc + 0.4505937099*sc
+	b = 0.0259040371*lc + 0.7827717662*mc - 0.8086757660*sc
+	return l, a, b
+}
+
+// OKLabToColor converts OKLab values into a color, clipping anything which is
+// out of the sRGB gamut
+func OKLabToColor(l, a, b float64, alpha uint8) rl.Color {
+	lc := l + 0.3963377774*a + 0.2158037573*b
+	mc := l - 0.1055613458*a - 0.0638541728*b
+	sc := l - 0.0894841775*a - 1.2914855480*b
+
+	lc = lc * lc * lc
+	mc = mc * mc * mc
+	sc = sc * sc * sc
+
+	r := 4.0767416621*lc - 3.3077115913*mc + 0.2309699292*sc
+	g := -1.2684380046*lc + 2.6097574011*mc - 0.3413193965*sc
+	bl := -0.0041960863*lc - 0.7034186147*mc + 1.7076147010*sc
+
+	return rl.NewColor(
+		floatToUint8(linearToSrgb(clamp01(r))),
+		floatToUint8(linearToSrgb(clamp01(g))),
+		floatToUint8(linearToSrgb(clamp01(bl))),
+		alpha,
+	)
+}
+
+// ColorToOKLCH converts a color into lightness, chroma and hue (0-360)
+func ColorToOKLCH(c rl.Color) (l, ch, h float64) {
+	l, a, b := ColorToOKLab(c)
+	ch = math.Hypot(a, b)
+	h = wrapHue(math.Atan2(b, a) * 180 / math.Pi)
+	return l, ch, h
+}
+
+// OKLCHToColor converts lightness, chroma and hue (0-360) into a color
+func OKLCHToColor(l, ch, h float64, alpha uint8) rl.Color {
+	rad := h * math.Pi / 180
+	return OKLabToColor(l, ch*math.Cos(rad), ch*math.Sin(rad), alpha)
+}
+
+// lerpHue interpolates between two hues using the shortest path
+func lerpHue(a, b, t float64) float64 {
+	d := math.Mod(b-a+540, 360) - 180
+	return wrapHue(a + d*t)
+}
+
+// LerpColor interpolates between two colors in the given color space
+func LerpColor(a, b rl.Color, t float64, mode RampInterpolation) rl.Color {
+	alpha := uint8(math.Round(float64(a.A) + (float64(b.A)-float64(a.A))*t))
+
+	switch mode {
+	case RampInterpolationHSV:
+		ah, as, av := ColorToHSV(a)
+		bh, bs, bv := ColorToHSV(b)
+		// Greys don't have a hue, use the other color's instead
+		if as == 0 {
+			ah = bh
+		}
+		if bs == 0 {
+			bh = ah
+		}
+		return HSVToColor(lerpHue(ah, bh, t), as+(bs-as)*t, av+(bv-av)*t, alpha)
+	case RampInterpolationOKLab:
+		al, aa, ab := ColorToOKLab(a)
+		bl, ba, bb := ColorToOKLab(b)
+		return OKLabToColor(al+(bl-al)*t, aa+(ba-aa)*t, ab+(bb-ab)*t, alpha)
+	}
+
+	return rl.NewColor(
+		uint8(math.Round(float64(a.R)+(float64(b.R)-float64(a.R))*t)),
+		uint8(math.Round(float64(a.G)+(float64(b.G)-float64(a.G))*t)),
+		uint8(math.Round(float64(a.B)+(float64(b.B)-float64(a.B))*t)),
+		alpha,
+	)
+}
+
+// GenerateRamp returns steps colors going from a to b, including both ends
+func GenerateRamp(a, b rl.Color, steps int32, mode RampInterpolation) []rl.Color {
+	if steps < 2 {
+		return []rl.Color{a}
+	}
+
+	ramp := make([]rl.Color, steps)
+	for i := int32(0); i < steps; i++ {
+		ramp[i] = LerpColor(a, b, float64(i)/float64(steps-1), mode)
+	}
+	return ramp
+}
+
+// GenerateHueShiftRamp returns a ramp with the base color in the middle.
+// Shadows are shifted towards blue and highlights towards yellow by up to
+// hueShift degrees, like you'd do by hand when shading pixel art.
+func GenerateHueShiftRamp(base rl.Color, steps int32, hueShift float64, mode RampInterpolation) []rl.Color {
+	if steps < 2 {
+		return []rl.Color{base}
+	}
+
+	h, s, v := ColorToHSV(base)
+
+	// Shadows and highlights move towards these hues
+	shadowHue := 240.0
+	highlightHue := 60.0
+	towards := func(from, to, amount float64) float64 {
+		d := math.Mod(to-from+540, 360) - 180
+		if math.Abs(d) < amount {
+			return to
+		}
+		if d < 0 {
+			return wrapHue(from - amount)
+		}
+		return wrapHue(from + amount)
+	}
+
+	dark := HSVToColor(towards(h, shadowHue, hueShift), math.Min(1, s+0.15), v*0.2, base.A)
+	light := HSVToColor(towards(h, highlightHue, hueShift), s*0.35, math.Min(1, v+(1-v)*0.9+0.1), base.A)
+
+	ramp := make([]rl.Color, 0, steps)
+	mid := steps / 2
+	// Darkest to the base color
+	for i := int32(0); i < mid; i++ {
+		ramp = append(ramp, LerpColor(dark, base, float64(i)/float64(mid), mode))
+	}
+	ramp = append(ramp, base)
+	// Base color to the lightest
+	remaining := steps - mid - 1
+	for i := int32(1); i <= remaining; i++ {
+		ramp = append(ramp, LerpColor(base, light, float64(i)/float64(remaining), mode))
+	}
+
+	return ramp
+}
+
+// ColorHarmony is a rule for picking colors which go well together
+type ColorHarmony int32
+
+// Color harmonies
+const (
+	ColorHarmonyComplementary ColorHarmony = iota
+	ColorHarmonyTriadic
+	ColorHarmonySplitComplementary
+	ColorHarmonyAnalogous
+)
+
+func (c ColorHarmony) String() string {
+	switch c {
+	case ColorHarmonyComplementary:
+		return "complementary"
+	case ColorHarmonyTriadic:
+		return "triadic"
+	case ColorHarmonySplitComplementary:
+		return "split complementary"
+	case ColorHarmonyAnalogous:
+		return "analogous"
+	}
+	return "unknown"
+}
+
+// GenerateHarmony returns the base color followed by the suggestions for the
+// harmony. Hues are rotated in OKLCH so that the suggestions keep a similar
+// perceived lightness to the base color.
+func GenerateHarmony(base rl.Color, harmony ColorHarmony) []rl.Color {
+	var offsets []float64
+	switch harmony {
+	case ColorHarmonyComplementary:
+		offsets = []float64{180}
+	case ColorHarmonyTriadic:
+		offsets = []float64{120, 240}
+	case ColorHarmonySplitComplementary:
+		offsets = []float64{150, 210}
+	case ColorHarmonyAnalogous:
+		offsets = []float64{-30, 30}
+	}
+
+	l, c, h := ColorToOKLCH(base)
+	colors := []rl.Color{base}
+	for _, offset := range offsets {
+		colors = append(colors, OKLCHToColor(l, c, wrapHue(h+offset), base.A))
+	}
+	return colors
+}
+
+// RelativeLuminance returns the WCAG relative luminance of a color (0-1)
+// https://www.w3.org/TR/WCAG21/#dfn-relative-luminance
+func RelativeLuminance(c rl.Color) float64 {
+	r := srgbToLinear(float64(c.R) / 255)
+	g := srgbToLinear(float64(c.G) / 255)
+	b := srgbToLinear(float64(c.B) / 255)
+	return 0.2126*r + 0.7152*g + 0.0722*b
+}
+
+// ContrastRatio returns the WCAG contrast ratio between two colors, from 1 to 21
+func ContrastRatio(a, b rl.Color) float64 {
+	la := RelativeLuminance(a)
+	lb := RelativeLuminance(b)
+	if la < lb {
+		la, lb = lb, la
+	}
+	return (la + 0.05) / (lb + 0.05)
+}
+
+// ContrastRating returns the WCAG level met by a contrast ratio
+func ContrastRating(ratio float64) string {
+	switch {
+	case ratio >= 7:
+		return "AAA"
+	case ratio >= 4.5:
+		return "AA"
+	case ratio >= 3:
+		return "AA large"
+	}
+	return "fail"
+}
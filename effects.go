@@ -0,0 +1,292 @@
+package main
+
+import (
+	"fmt"
+	"math"
+
+	rl "github.com/gen2brain/raylib-go/raylib"
+)
+
+// LayerEffectKind is what a layer effect draws
+type LayerEffectKind int32
+
+// Layer effect kinds
+const (
+	LayerEffectOutline LayerEffectKind = iota
+	LayerEffectDropShadow
+	LayerEffectColorOverlay
+	LayerEffectPaletteRemap
+	LayerEffectOpacity
+)
+
+func (k LayerEffectKind) String() string {
+	switch k {
+	case LayerEffectOutline:
+		return "outline"
+	case LayerEffectDropShadow:
+		return "drop shadow"
+	case LayerEffectColorOverlay:
+		return "color overlay"
+	case LayerEffectPaletteRemap:
+		return "palette remap"
+	case LayerEffectOpacity:
+		return "opacity"
+	}
+	return "unknown"
+}
+
+// LayerEffect is drawn from a layer's pixels every time the layer is drawn,
+// so the pixels underneath can still be edited
+type LayerEffect struct {
+	Kind    LayerEffectKind
+	Enabled bool
+	// Color is the color of an outline, shadow or overlay
+	Color rl.Color
+	// Inside, Diagonal and PerTile are the same as for OutlineOptions,
+	// PerTile also keeps shadows inside their tile
+	Inside, Diagonal, PerTile bool
+	// OffsetX and OffsetY are how far the shadow falls
+	OffsetX, OffsetY int32
+	// Amount is how strong an overlay is, or the opacity, in percent
+	Amount int32
+	// Palette is the colors which palette remap picks from
+	Palette []rl.Color
+}
+
+// NewLayerEffect returns an effect of the kind with its default settings
+func NewLayerEffect(kind LayerEffectKind) LayerEffect {
+	return LayerEffect{
+		Kind:    kind,
+		Enabled: true,
+		Color:   rl.Black,
+		OffsetX: 1,
+		OffsetY: 1,
+		Amount:  100,
+	}
+}
+
+// sameLayerEffect returns true if the effects have the same settings
+func sameLayerEffect(a, b LayerEffect) bool {
+	if len(a.Palette) != len(b.Palette) {
+		return false
+	}
+	for i := range a.Palette {
+		if a.Palette[i] != b.Palette[i] {
+			return false
+		}
+	}
+	return a.Kind == b.Kind && a.Enabled == b.Enabled && a.Color == b.Color &&
+		a.Inside == b.Inside && a.Diagonal == b.Diagonal && a.PerTile == b.PerTile &&
+		a.OffsetX == b.OffsetX && a.OffsetY == b.OffsetY && a.Amount == b.Amount
+}
+
+// nearestColor returns the palette color which looks closest to the color
+func nearestColor(color rl.Color, palette []rl.Color) rl.Color {
+	l, a, b := ColorToOKLab(color)
+	nearest, distance := color, math.Inf(1)
+	for _, c := range palette {
+		pl, pa, pb := ColorToOKLab(c)
+		if d := (l-pl)*(l-pl) + (a-pa)*(a-pa) + (b-pb)*(b-pb); d < distance {
+			nearest, distance = c, d
+		}
+	}
+	return nearest
+}
+
+// applyLayerEffect returns the pixels with the effect drawn over them
+func (f *File) applyLayerEffect(effect LayerEffect, pixels map[IntVec2]rl.Color) map[IntVec2]rl.Color {
+	region := f.effectRegion(effect.PerTile)
+	amount := float64(MinInt32(MaxInt32(effect.Amount, 0), 100)) / 100
+
+	drawn := make(map[IntVec2]rl.Color, len(pixels))
+	for loc, color := range pixels {
+		drawn[loc] = color
+	}
+
+	switch effect.Kind {
+	case LayerEffectOutline:
+		options := OutlineOptions{Color: effect.Color, Inside: effect.Inside, Diagonal: effect.Diagonal}
+		for loc, color := range outlinePixels(pixels, options, region) {
+			drawn[loc] = color
+		}
+	case LayerEffectDropShadow:
+		options := ShadowOptions{OffsetX: effect.OffsetX, OffsetY: effect.OffsetY, Color: effect.Color}
+		for loc, color := range shadowPixels(pixels, options, region) {
+			drawn[loc] = color
+		}
+	case LayerEffectColorOverlay:
+		t := amount * float64(effect.Color.A) / 255
+		mix := func(a, b uint8) uint8 {
+			return uint8(math.Round(float64(a) + (float64(b)-float64(a))*t))
+		}
+		for loc, color := range pixels {
+			if color.A > 0 {
+				drawn[loc] = rl.NewColor(mix(color.R, effect.Color.R), mix(color.G, effect.Color.G), mix(color.B, effect.Color.B), color.A)
+			}
+		}
+	case LayerEffectPaletteRemap:
+		if len(effect.Palette) == 0 {
+			break
+		}
+		for loc, color := range pixels {
+			if color.A > 0 {
+				c := nearestColor(color, effect.Palette)
+				c.A = color.A
+				drawn[loc] = c
+			}
+		}
+	case LayerEffectOpacity:
+		for loc, color := range pixels {
+			color.A = uint8(math.Round(float64(color.A) * amount))
+			drawn[loc] = color
+		}
+	}
+	return drawn
+}
+
+// HasEffects returns true if any of the layer's effects are drawn. Groups
+// and masks don't have effects
+func (l *Layer) HasEffects() bool {
+	if l.EffectsHidden || l.IsGroup || l.IsMask {
+		return false
+	}
+	for _, effect := range l.Effects {
+		if effect.Enabled {
+			return true
+		}
+	}
+	return false
+}
+
+// DrawnPixels returns the layer's pixels as they're shown, with its effects
+func (l *Layer) DrawnPixels() map[IntVec2]rl.Color {
+	if l.effectPixels != nil {
+		return l.effectPixels
+	}
+	return l.PixelData
+}
+
+// updateLayerEffects draws the effects of every layer again
+func (f *File) updateLayerEffects() {
+	f.layerEffectsChanged = false
+	for _, layer := range f.Layers {
+		f.drawLayerEffects(layer)
+	}
+}
+
+// drawLayerEffects draws the effects of the layer again
+func (f *File) drawLayerEffects(layer *Layer) {
+	layer.effectPixels = nil
+	if !layer.HasEffects() {
+		return
+	}
+	pixels := layer.PixelData
+	for _, effect := range layer.Effects {
+		if effect.Enabled {
+			pixels = f.applyLayerEffect(effect, pixels)
+		}
+	}
+	layer.effectPixels = pixels
+}
+
+// copyLayerEffects returns a copy of the effects which shares nothing
+func copyLayerEffects(effects []LayerEffect) []LayerEffect {
+	if effects == nil {
+		return nil
+	}
+	c := make([]LayerEffect, len(effects))
+	for i, effect := range effects {
+		effect.Palette = append([]rl.Color(nil), effect.Palette...)
+		c[i] = effect
+	}
+	return c
+}
+
+// setLayerEffects replaces the layer's effects and records it as name. When
+// merge is true and the last action has the same name and layer, it's
+// extended instead so typing a value is a single action
+func (f *File) setLayerEffects(index int32, effects []LayerEffect, name string, merge bool) {
+	layer := f.Layers[index]
+	prev := copyLayerEffects(layer.Effects)
+	layer.Effects = copyLayerEffects(effects)
+	f.RedrawRenderLayer()
+
+	if last, ok := f.lastHistory(); ok && merge {
+		if typed, ok := last.(HistoryLayerEffects); ok && typed.LayerIndex == index && typed.Name == name {
+			typed.Current = copyLayerEffects(effects)
+			f.replaceLastHistory(typed)
+			return
+		}
+	}
+	f.AppendHistory(HistoryLayerEffects{LayerIndex: index, Prev: prev, Current: copyLayerEffects(effects), Name: name})
+}
+
+// AddLayerEffect adds an effect to the top of the layer's effects
+func (f *File) AddLayerEffect(index int32, effect LayerEffect) error {
+	if layer := f.Layers[index]; layer.IsGroup || layer.IsMask {
+		return fmt.Errorf("Couldn't add effect: Groups and masks can't have effects")
+	}
+	effects := append(copyLayerEffects(f.Layers[index].Effects), effect)
+	f.setLayerEffects(index, effects, "Add effect", false)
+	return nil
+}
+
+// SetLayerEffect changes one of the layer's effects
+func (f *File) SetLayerEffect(index, effectIndex int32, effect LayerEffect) {
+	effects := copyLayerEffects(f.Layers[index].Effects)
+	if effectIndex < 0 || effectIndex >= int32(len(effects)) {
+		return
+	}
+	effects[effectIndex] = effect
+	f.setLayerEffects(index, effects, fmt.Sprintf("Edit %s", effect.Kind), true)
+}
+
+// DeleteLayerEffect removes one of the layer's effects
+func (f *File) DeleteLayerEffect(index, effectIndex int32) {
+	effects := copyLayerEffects(f.Layers[index].Effects)
+	if effectIndex < 0 || effectIndex >= int32(len(effects)) {
+		return
+	}
+	effects = append(effects[:effectIndex], effects[effectIndex+1:]...)
+	f.setLayerEffects(index, effects, "Delete effect", false)
+}
+
+// SetLayerEffectsHidden hides or shows all of the layer's effects
+func (f *File) SetLayerEffectsHidden(index int32, hidden bool) {
+	name := "Show effects"
+	if hidden {
+		name = "Hide effects"
+	}
+	f.setLayerProperties(index, name, false, func(p *LayerProperties) {
+		p.EffectsHidden = hidden
+	})
+}
+
+// ApplyLayerEffects draws the layer's effects into its pixels and removes
+// them, as a single undo
+func (f *File) ApplyLayerEffects(index int32) error {
+	layer := f.Layers[index]
+	if len(layer.Effects) == 0 {
+		return fmt.Errorf("Couldn't apply effects: The layer has no effects")
+	}
+	if layer.EffectsHidden {
+		return fmt.Errorf("Couldn't apply effects: The effects are hidden")
+	}
+
+	f.updateLayerEffects()
+	history := HistoryLayerEffects{
+		LayerIndex: index,
+		Prev:       copyLayerEffects(layer.Effects),
+		PixelState: make(map[IntVec2]PixelStateData),
+		Name:       "Apply effects",
+	}
+	for loc, color := range layer.DrawnPixels() {
+		if prev := layer.PixelData[loc]; prev != color {
+			history.PixelState[loc] = PixelStateData{Prev: prev, Current: color}
+		}
+	}
+	history.Apply(f)
+	f.AppendHistory(history)
+	f.RedrawRenderLayer()
+	return nil
+}
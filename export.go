@@ -0,0 +1,573 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"log"
+	"path/filepath"
+	"strings"
+
+	rl "github.com/gen2brain/raylib-go/raylib"
+)
+
+// ExportLayers is which layers are exported
+type ExportLayers int32
+
+// Layers which can be exported
+const (
+	ExportLayersVisible  ExportLayers = iota // every visible layer blended
+	ExportLayersSelected                     // the current and selected layers
+	ExportLayersEach                         // a file for each top level layer
+)
+
+func (l ExportLayers) String() string {
+	switch l {
+	case ExportLayersVisible:
+		return "visible"
+	case ExportLayersSelected:
+		return "selected"
+	case ExportLayersEach:
+		return "each"
+	}
+	return ""
+}
+
+// ExportRegion is which part of the canvas is exported
+type ExportRegion int32
+
+// Regions which can be exported
+const (
+	ExportRegionCanvas    ExportRegion = iota
+	ExportRegionTiles                  // FirstTile onwards, in a row
+	ExportRegionSelection              // the selection's bounds
+)
+
+func (r ExportRegion) String() string {
+	switch r {
+	case ExportRegionCanvas:
+		return "canvas"
+	case ExportRegionTiles:
+		return "tiles"
+	case ExportRegionSelection:
+		return "selection"
+	}
+	return ""
+}
+
+// ExportSplit splits the export into a file for each tile or frame
+type ExportSplit int32
+
+// Ways the export can be split
+const (
+	ExportSplitNone ExportSplit = iota
+	ExportSplitTiles
+	ExportSplitAnimations
+)
+
+func (s ExportSplit) String() string {
+	switch s {
+	case ExportSplitNone:
+		return "none"
+	case ExportSplitTiles:
+		return "tiles"
+	case ExportSplitAnimations:
+		return "animations"
+	}
+	return ""
+}
+
+// ExportSettings are remembered per file so that it can be re-exported
+// without choosing everything again
+type ExportSettings struct {
+	// Path is where the file was last exported to, empty if it hasn't been.
+	// When there are several files, they're written next to it
+	Path string
+
+	// Scale is how many times larger the pixels are made, 0 is the same as 1
+	Scale  int32
+	Layers ExportLayers
+	Region ExportRegion
+	// FirstTile and Tiles are the tile range for ExportRegionTiles. Tiles is
+	// 0 for every tile from FirstTile to the end
+	FirstTile, Tiles int32
+	// Trim removes transparent rows and columns around the image
+	Trim  bool
+	Split ExportSplit
+	// Template names the files when there are several. {name}, {layer},
+	// {tile}, {anim} and {frame} are replaced
+	Template string
+}
+
+// exporters encode the file for each extension which can be exported to
+var exporters = map[string]func(f *File, settings ExportSettings) error{
+	".png": (*File).exportPNG,
+	".ora": (*File).exportORA,
+}
+
+// Export writes the file to settings.Path in the format for its extension.
+// Unlike SaveAs, it doesn't change where the document is saved
+func (f *File) Export(settings ExportSettings) error {
+	if filepath.Ext(settings.Path) == "" {
+		settings.Path += ".png"
+	}
+	ext := filepath.Ext(settings.Path)
+	encode, ok := exporters[ext]
+	if !ok {
+		return fmt.Errorf("Can't export: extension \"%s\" not supported", ext)
+	}
+
+	if err := encode(f, settings); err != nil {
+		return err
+	}
+
+	log.Println("Exported", f.Filename, "to", settings.Path)
+	f.ExportSettings = settings
+	return nil
+}
+
+// exportImage is an image to be written by exportPNG and the values for
+// the filename template
+type exportImage struct {
+	vars map[string]string
+	img  *image.NRGBA
+}
+
+// exportPNG writes one or more pngs next to settings.Path
+func (f *File) exportPNG(settings ExportSettings) error {
+	images, err := f.exportImages(settings)
+	if err != nil {
+		return err
+	}
+
+	if len(images) == 1 && settings.Layers != ExportLayersEach && settings.Split == ExportSplitNone {
+		return writeFileAtomic(settings.Path, func(w io.Writer) error {
+			return png.Encode(w, images[0].img)
+		})
+	}
+
+	template := settings.Template
+	if template == "" {
+		template = defaultExportTemplate(settings)
+	}
+	if filepath.Ext(template) == "" {
+		template += ".png"
+	}
+
+	dir := filepath.Dir(settings.Path)
+	name := strings.TrimSuffix(filepath.Base(settings.Path), filepath.Ext(settings.Path))
+	written := make(map[string]struct{}, len(images))
+	for _, ei := range images {
+		ei.vars["name"] = name
+		filename := template
+		for k, v := range ei.vars {
+			filename = strings.ReplaceAll(filename, "{"+k+"}", exportFilenameValue(v))
+		}
+		if !exportFilenameLocal(filename) {
+			return fmt.Errorf("Can't export: \"%s\" isn't in the export folder", filename)
+		}
+		if _, ok := written[filename]; ok {
+			return fmt.Errorf("Can't export: the template \"%s\" gives more than one image the name \"%s\"", template, filename)
+		}
+		written[filename] = struct{}{}
+
+		img := ei.img
+		err := writeFileAtomic(filepath.Join(dir, filename), func(w io.Writer) error {
+			return png.Encode(w, img)
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// exportFilenameValue makes a layer or animation name safe to put in a
+// filename, so it can't add folders
+func exportFilenameValue(value string) string {
+	return strings.NewReplacer("/", "_", "\\", "_").Replace(value)
+}
+
+// exportFilenameLocal returns whether the filename is inside the folder it's
+// written to, without going up out of it
+func exportFilenameLocal(filename string) bool {
+	clean := filepath.Clean(filename)
+	parent := ".." + string(filepath.Separator)
+	return !filepath.IsAbs(clean) && filepath.VolumeName(clean) == "" && clean != ".." && !strings.HasPrefix(clean, parent)
+}
+
+// defaultExportTemplate returns a template which gives each image a
+// different name
+func defaultExportTemplate(settings ExportSettings) string {
+	template := "{name}"
+	if settings.Layers == ExportLayersEach {
+		template += "_{layer}"
+	}
+	switch settings.Split {
+	case ExportSplitTiles:
+		template += "_{tile}"
+	case ExportSplitAnimations:
+		template += "_{anim}_{frame}"
+	}
+	return template + ".png"
+}
+
+// exportSource returns a pixel to be exported
+type exportSource func(loc IntVec2) rl.Color
+
+// exportSources returns the layers to export, named for the template. A
+// moving selection is exported where it will be placed
+func (f *File) exportSources(settings ExportSettings) ([]exportSource, []string) {
+	f.updateLayerEffects()
+	layers := f.Layers[:len(f.Layers)-1]
+	if f.SelectionMoving {
+		layers = f.placeFloatingSelection(layers)
+	}
+	children := groupLayers(layers)
+
+	// The layer is exported even if it's hidden
+	layerSource := func(layer *Layer) exportSource {
+		if layer.IsGroup {
+			return func(loc IntVec2) rl.Color {
+				return compositePixel(children, layer, loc)
+			}
+		}
+		return func(loc IntVec2) rl.Color {
+			return layer.DrawnPixels()[loc]
+		}
+	}
+
+	switch settings.Layers {
+	case ExportLayersSelected:
+		if len(f.SelectedLayers) == 0 {
+			layer := layers[f.CurrentLayer]
+			return []exportSource{layerSource(layer)}, []string{layer.Name}
+		}
+		selected := f.selectedLayerChildren(layers)
+		return []exportSource{func(loc IntVec2) rl.Color {
+			return compositePixel(selected, nil, loc)
+		}}, []string{"selected"}
+	case ExportLayersEach:
+		siblings := children[nil]
+		sources := make([]exportSource, 0, len(siblings))
+		names := make([]string, 0, len(siblings))
+		for i, layer := range siblings {
+			if layer.IsMask {
+				continue
+			}
+			i := i
+			sources = append(sources, func(loc IntVec2) rl.Color {
+				return siblingColor(children, siblings, i, loc)
+			})
+			names = append(names, fmt.Sprintf("%d_%s", i, layer.Name))
+		}
+		return sources, names
+	}
+
+	return []exportSource{func(loc IntVec2) rl.Color {
+		return compositePixel(children, nil, loc)
+	}}, []string{"visible"}
+}
+
+// placeFloatingSelection returns the layers with the one under the moving
+// selection replaced by a copy which has the selection placed on it
+func (f *File) placeFloatingSelection(layers []*Layer) []*Layer {
+	under := f.floatingLayer()
+	placed := *under
+	placed.PixelData = make(map[IntVec2]rl.Color, len(under.PixelData))
+	for loc, color := range under.PixelData {
+		placed.PixelData[loc] = color
+	}
+	for loc, color := range f.Selection {
+		if loc.X >= 0 && loc.X < f.CanvasWidth && loc.Y >= 0 && loc.Y < f.CanvasHeight {
+			placed.PixelData[loc] = BlendWithOpacity(placed.PixelData[loc], color, under.BlendMode)
+		}
+	}
+	f.drawLayerEffects(&placed)
+
+	replaced := make([]*Layer, len(layers))
+	for i, layer := range layers {
+		replaced[i] = layer
+		if layer == under {
+			replaced[i] = &placed
+		}
+	}
+	return replaced
+}
+
+// selectedLayerChildren is like layerChildren, but only has the selected
+// layers along with their masks and children, and the groups they're in.
+// layers are the file's layers, in the same order
+func (f *File) selectedLayerChildren(layers []*Layer) map[*Layer][]*Layer {
+	selected := make(map[*Layer]bool)
+	for i, layer := range layers {
+		if f.IsLayerSelected(int32(i)) {
+			selected[layer] = true
+		}
+	}
+
+	included := make(map[*Layer]bool)
+	for i, layer := range layers {
+		for l := layer; l != nil; l = l.Parent {
+			if selected[l] {
+				included[layer] = true
+				break
+			}
+		}
+		if f.isLayerMask(int32(i)) && included[layers[i-1]] {
+			included[layer] = true
+		}
+	}
+	for layer := range included {
+		for p := layer.Parent; p != nil; p = p.Parent {
+			included[p] = true
+		}
+	}
+
+	shown := make([]*Layer, 0, len(included))
+	for _, layer := range layers {
+		if included[layer] {
+			shown = append(shown, layer)
+		}
+	}
+	return groupLayers(shown)
+}
+
+// exportRect is part of the canvas which is exported
+type exportRect struct {
+	X, Y, Width, Height int32
+	vars                map[string]string
+}
+
+// exportRects returns the parts of the canvas to export. All of the rects
+// are placed in a row in the same image unless the export is split
+func (f *File) exportRects(settings ExportSettings) ([]exportRect, error) {
+	tile := func(index int32, vars map[string]string) exportRect {
+		origin := f.TileOrigin(index)
+		return exportRect{origin.X, origin.Y, f.TileWidth, f.TileHeight, vars}
+	}
+	tileRange := func() (int32, int32) {
+		first, last := settings.FirstTile, f.TileCount()-1
+		if first < 0 {
+			first = 0
+		}
+		if settings.Tiles > 0 && first+settings.Tiles-1 < last {
+			last = first + settings.Tiles - 1
+		}
+		return first, last
+	}
+
+	switch settings.Split {
+	case ExportSplitTiles:
+		first, last := int32(0), f.TileCount()-1
+		if settings.Region == ExportRegionTiles {
+			first, last = tileRange()
+		}
+		if first > last {
+			return nil, fmt.Errorf("Can't export: tile range %d to %d is empty", first, last)
+		}
+		rects := make([]exportRect, 0, last-first+1)
+		for i := first; i <= last; i++ {
+			rects = append(rects, tile(i, map[string]string{"tile": fmt.Sprint(i)}))
+		}
+		return rects, nil
+
+	case ExportSplitAnimations:
+		rects := make([]exportRect, 0)
+		for _, anim := range f.Animations {
+			for i := anim.FrameStart; i <= anim.FrameEnd; i++ {
+				rects = append(rects, tile(i, map[string]string{
+					"anim":  anim.Name,
+					"frame": fmt.Sprint(i - anim.FrameStart),
+					"tile":  fmt.Sprint(i),
+				}))
+			}
+		}
+		if len(rects) == 0 {
+			return nil, fmt.Errorf("Can't export: there aren't any animations")
+		}
+		return rects, nil
+	}
+
+	switch settings.Region {
+	case ExportRegionTiles:
+		first, last := tileRange()
+		if first > last {
+			return nil, fmt.Errorf("Can't export: tile range %d to %d is empty", first, last)
+		}
+		rects := make([]exportRect, 0, last-first+1)
+		for i := first; i <= last; i++ {
+			rects = append(rects, tile(i, nil))
+		}
+		return rects, nil
+	case ExportRegionSelection:
+		if !f.DoingSelection {
+			return nil, fmt.Errorf("Can't export: nothing is selected")
+		}
+		// The selection can be moved past the edges of the canvas
+		x0, y0 := MaxInt32(f.SelectionBounds[0], 0), MaxInt32(f.SelectionBounds[1], 0)
+		x1, y1 := MinInt32(f.SelectionBounds[2], f.CanvasWidth-1), MinInt32(f.SelectionBounds[3], f.CanvasHeight-1)
+		if x1 < x0 || y1 < y0 {
+			return nil, fmt.Errorf("Can't export: the selection is outside of the canvas")
+		}
+		return []exportRect{{x0, y0, x1 - x0 + 1, y1 - y0 + 1, nil}}, nil
+	}
+
+	return []exportRect{{0, 0, f.CanvasWidth, f.CanvasHeight, nil}}, nil
+}
+
+// exportImages renders every image which settings describes
+func (f *File) exportImages(settings ExportSettings) ([]exportImage, error) {
+	rects, err := f.exportRects(settings)
+	if err != nil {
+		return nil, err
+	}
+	if len(rects) == 0 {
+		return nil, fmt.Errorf("Can't export: there's nothing to export")
+	}
+	sources, names := f.exportSources(settings)
+	if settings.Split == ExportSplitNone && settings.Region == ExportRegionSelection {
+		// Only the selected pixels, not everything in the selection's bounds
+		for i, source := range sources {
+			source := source
+			sources[i] = func(loc IntVec2) rl.Color {
+				if _, ok := f.Selection[loc]; !ok {
+					return rl.Blank
+				}
+				return source(loc)
+			}
+		}
+	}
+
+	images := make([]exportImage, 0, len(sources)*len(rects))
+	for s, source := range sources {
+		if settings.Split == ExportSplitNone {
+			img := renderExportRow(source, rects)
+			images = append(images, exportImage{
+				vars: map[string]string{"layer": names[s]},
+				img:  finishExportImage(img, settings),
+			})
+			continue
+		}
+
+		for _, rect := range rects {
+			vars := map[string]string{"layer": names[s]}
+			for k, v := range rect.vars {
+				vars[k] = v
+			}
+			images = append(images, exportImage{
+				vars: vars,
+				img:  finishExportImage(renderExportRow(source, []exportRect{rect}), settings),
+			})
+		}
+	}
+	return images, nil
+}
+
+// renderExportRow draws the rects next to each other in a single image
+func renderExportRow(source exportSource, rects []exportRect) *image.NRGBA {
+	var width, height int32
+	for _, rect := range rects {
+		width += rect.Width
+		if rect.Height > height {
+			height = rect.Height
+		}
+	}
+
+	img := image.NewNRGBA(image.Rect(0, 0, int(width), int(height)))
+	var ox int32
+	for _, rect := range rects {
+		for x := int32(0); x < rect.Width; x++ {
+			for y := int32(0); y < rect.Height; y++ {
+				c := source(IntVec2{rect.X + x, rect.Y + y})
+				img.SetNRGBA(int(ox+x), int(y), color.NRGBA{c.R, c.G, c.B, c.A})
+			}
+		}
+		ox += rect.Width
+	}
+	return img
+}
+
+// finishExportImage trims and scales the image
+func finishExportImage(img *image.NRGBA, settings ExportSettings) *image.NRGBA {
+	if settings.Trim {
+		img = trimImage(img)
+	}
+	if settings.Scale > 1 {
+		img = scaleImage(img, int(settings.Scale))
+	}
+	return img
+}
+
+// trimImage removes the transparent rows and columns around the image. A
+// fully transparent image becomes 1x1
+func trimImage(img *image.NRGBA) *image.NRGBA {
+	b := img.Bounds()
+	minX, minY, maxX, maxY := b.Max.X, b.Max.Y, b.Min.X-1, b.Min.Y-1
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			if img.NRGBAAt(x, y).A == 0 {
+				continue
+			}
+			if x < minX {
+				minX = x
+			}
+			if x > maxX {
+				maxX = x
+			}
+			if y < minY {
+				minY = y
+			}
+			if y > maxY {
+				maxY = y
+			}
+		}
+	}
+	if maxX < minX {
+		return image.NewNRGBA(image.Rect(0, 0, 1, 1))
+	}
+
+	trimmed := image.NewNRGBA(image.Rect(0, 0, maxX-minX+1, maxY-minY+1))
+	for y := minY; y <= maxY; y++ {
+		for x := minX; x <= maxX; x++ {
+			trimmed.SetNRGBA(x-minX, y-minY, img.NRGBAAt(x, y))
+		}
+	}
+	return trimmed
+}
+
+// scaleImage makes every pixel scale times larger
+func scaleImage(img *image.NRGBA, scale int) *image.NRGBA {
+	b := img.Bounds()
+	scaled := image.NewNRGBA(image.Rect(0, 0, b.Dx()*scale, b.Dy()*scale))
+	for y := 0; y < b.Dy()*scale; y++ {
+		for x := 0; x < b.Dx()*scale; x++ {
+			scaled.SetNRGBA(x, y, img.NRGBAAt(b.Min.X+x/scale, b.Min.Y+y/scale))
+		}
+	}
+	return scaled
+}
+
+// UIExport shows the export options
+func UIExport() {
+	ExportUIShowDialog()
+}
+
+// UIExportChooseFile asks where to export the file to
+func UIExportChooseFile() {
+	UIControlSystemCmds <- UIControlChanData{CommandType: CommandTypeExport}
+}
+
+// UIReExport exports the file with the settings it was last exported with,
+// showing the export options if it hasn't been exported yet
+func UIReExport() {
+	if len(CurrentFile.ExportSettings.Path) == 0 {
+		UIExport()
+		return
+	}
+	if err := CurrentFile.Export(CurrentFile.ExportSettings); err != nil {
+		UIShowError(err)
+	}
+}
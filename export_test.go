@@ -0,0 +1,95 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+
+	rl "github.com/gen2brain/raylib-go/raylib"
+)
+
+// TestExportFilenameLocal checks that names from the template can't write
+// outside of the export folder
+func TestExportFilenameLocal(t *testing.T) {
+	for _, value := range []string{"../up", "a/../../up", "..\\up"} {
+		if filename := exportFilenameValue(value) + ".png"; !exportFilenameLocal(filename) {
+			t.Errorf("\"%s\" gave \"%s\" which isn't in the export folder", value, filename)
+		}
+	}
+	for _, filename := range []string{"../up.png", "a/../../up.png", "/up.png", ".."} {
+		if exportFilenameLocal(filename) {
+			t.Errorf("\"%s\" is allowed", filename)
+		}
+	}
+}
+
+// TestExportSelection checks that only the selected pixels are exported,
+// with a moving selection where it will be placed
+func TestExportSelection(t *testing.T) {
+	f := newTestFile(t)
+	pixels := f.Layers[0].PixelData
+	moved := pixels[IntVec2{0, 0}]
+
+	f.SelectPixels(map[IntVec2]bool{{0, 0}: true, {1, 1}: true}, SelectOpReplace)
+	f.MoveSelection(4, 0)
+	images, err := f.exportImages(ExportSettings{Region: ExportRegionSelection})
+	if err != nil {
+		t.Fatal(err)
+	}
+	img := images[0].img
+	if got := img.NRGBAAt(0, 0); got.A != moved.A || got.R != moved.R || got.G != moved.G {
+		t.Errorf("The moving selection is %v, want %v", got, moved)
+	}
+	if got := img.NRGBAAt(1, 0); got.A != 0 {
+		t.Errorf("A pixel outside of the selection is %v, want blank", got)
+	}
+}
+
+// TestExportEachLayer checks that each layer is exported with its mask and
+// clipping applied
+func TestExportEachLayer(t *testing.T) {
+	f := newTestFile(t)
+	f.AddNewLayer()
+	clipped := f.Layers[1]
+	clipped.Clipping = true
+	f.AddLayerMask(0)
+	mask := f.Layers[1]
+
+	loc := IntVec2{2, 2}
+	mask.PixelData[loc] = rl.Black
+	clipped.PixelData[loc] = rl.White
+	clipped.PixelData[IntVec2{3, 3}] = rl.White
+
+	images, err := f.exportImages(ExportSettings{Layers: ExportLayersEach})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(images) != 2 {
+		t.Fatalf("%d images were exported, want 2", len(images))
+	}
+	if got := images[0].img.NRGBAAt(2, 2); got.A != 0 {
+		t.Errorf("The masked pixel is %v, want blank", got)
+	}
+	if got := images[1].img.NRGBAAt(2, 2); got.A != 0 {
+		t.Errorf("The pixel clipped to the masked pixel is %v, want blank", got)
+	}
+	if got := images[1].img.NRGBAAt(3, 3); got.A != 255 {
+		t.Errorf("The clipped pixel is %v, want opaque", got)
+	}
+}
+
+// TestExportEmptyTiles checks that exporting tiles past the last one is an
+// error instead of writing nothing
+func TestExportEmptyTiles(t *testing.T) {
+	f := newTestFile(t)
+	for _, split := range []ExportSplit{ExportSplitNone, ExportSplitTiles} {
+		err := f.Export(ExportSettings{
+			Path:      filepath.Join(t.TempDir(), "tiles.png"),
+			Region:    ExportRegionTiles,
+			Split:     split,
+			FirstTile: 100,
+		})
+		if err == nil {
+			t.Errorf("Exporting from tile 100 with split %v succeeded", split)
+		}
+	}
+}
@@ -6,10 +6,12 @@ import (
 	"image"
 	"image/color"
 	"image/png"
+	"io"
 	"log"
 	"os"
 	"path"
 	"path/filepath"
+	"reflect"
 	"strings"
 
 	rl "github.com/gen2brain/raylib-go/raylib"
@@ -32,80 +34,112 @@ type Tool interface {
 	DrawUI(camera rl.Camera2D)
 }
 
-// HistoryLayerAction specifies the action which has been called upon the layer
-type HistoryLayerAction int32
-
-// What HistoryLayer action has happened
-const (
-	HistoryLayerActionDelete HistoryLayerAction = iota
-	HistoryLayerActionCreate
-	HistoryLayerActionMoveUp
-	HistoryLayerActionMoveDown
-)
-
-//CompoundHistory is a group of history actions
-type CompoundHistory struct {
-	Actions []interface{}
+// scaleAlpha multiplies two alpha values
+func scaleAlpha(a, b uint8) uint8 {
+	return uint8(uint16(a) * uint16(b) / 255)
 }
 
-// HistoryLayer is for layer operations
-type HistoryLayer struct {
-	HistoryLayerAction
-	LayerIndex int32
+// layerChildren maps each group to its children, from bottom to top. The top
+// level layers are stored under nil
+func (f *File) layerChildren() map[*Layer][]*Layer {
+	return groupLayers(f.Layers[:len(f.Layers)-1])
 }
 
-// PixelStateData stores what the state was previously and currently
-// Prev is used by undo and Current is used by redo
-type PixelStateData struct {
-	Prev, Current rl.Color
+// groupLayers maps each group to its children in layers, in the same order
+func groupLayers(layers []*Layer) map[*Layer][]*Layer {
+	children := make(map[*Layer][]*Layer)
+	for _, layer := range layers {
+		children[layer.Parent] = append(children[layer.Parent], layer)
+	}
+	return children
 }
 
-// HistoryPixel is for pixel operations
-type HistoryPixel struct {
-	PixelState map[IntVec2]PixelStateData
-	LayerIndex int32
-}
+// compositePixel blends the children of parent at loc, applying groups,
+// masks and clipping
+func compositePixel(children map[*Layer][]*Layer, parent *Layer, loc IntVec2) rl.Color {
+	color := rl.Blank
+	// What clipping layers are clipped to
+	base := rl.Blank
+
+	siblings := children[parent]
+	for i, layer := range siblings {
+		if layer.IsMask {
+			continue
+		}
+		if layer.Hidden {
+			if !layer.Clipping {
+				base = rl.Blank
+			}
+			continue
+		}
+
+		var layerColor rl.Color
+		if layer.IsGroup {
+			layerColor = compositePixel(children, layer, loc)
+		} else {
+			layerColor = layer.DrawnPixels()[loc]
+		}
+
+		// Masks sit directly above the layer they hide
+		if i+1 < len(siblings) && siblings[i+1].IsMask && !siblings[i+1].Hidden {
+			layerColor.A = scaleAlpha(layerColor.A, 255-siblings[i+1].PixelData[loc].A)
+		}
 
-// HistoryResize is for resize operations
-type HistoryResize struct {
-	// PrevLayerState is a slice consisting of all layer's PixelData
-	PrevLayerState, CurrentLayerState []map[IntVec2]rl.Color
-	// Used for calling Layer.Resize. ResizeDirection doesn't matter
-	PrevWidth, PrevHeight       int32
-	CurrentWidth, CurrentHeight int32
+		if layer.Clipping {
+			layerColor.A = scaleAlpha(layerColor.A, base.A)
+		} else {
+			base = layerColor
+		}
+
+		color = BlendWithOpacity(color, layerColor, layer.BlendMode)
+	}
+
+	return color
 }
 
 // RedrawRenderLayer redraws the render layer
 func (f *File) RedrawRenderLayer() {
-	rl.BeginTextureMode(f.RenderLayer.Canvas)
-	rl.ClearBackground(rl.Black)
-	rl.BeginBlendMode(rl.BlendAlpha)
+	f.updateLayerEffects()
+	children := f.layerChildren()
+
 	for x := int32(0); x < f.CanvasWidth; x++ {
 		for y := int32(0); y < f.CanvasHeight; y++ {
-			color := rl.Blank
 			loc := IntVec2{x, y}
-			for _, layer := range f.Layers[:len(f.Layers)-1] {
-				if !layer.Hidden {
-					if layerColor, ok := layer.PixelData[loc]; ok {
-						color = BlendWithOpacity(color, layerColor, layer.BlendMode)
-					}
-				}
-			}
-			f.RenderLayer.PixelData[loc] = color
-			rl.DrawPixel(x, y, color)
+			f.RenderLayer.PixelData[loc] = compositePixel(children, nil, loc)
 		}
 	}
+	if Headless {
+		return
+	}
+
+	rl.BeginTextureMode(f.RenderLayer.Canvas)
+	rl.ClearBackground(rl.Black)
+	rl.BeginBlendMode(rl.BlendAlpha)
+	for loc, color := range f.RenderLayer.PixelData {
+		rl.DrawPixel(loc.X, loc.Y, color)
+	}
 	rl.EndBlendMode()
 	rl.EndTextureMode()
 }
 
 // DrawPixel draws a pixel. It records actions into history.
+// children is from layerChildren, built once for the whole stroke
 // TODO replace all instances of accessing layer.PixelData with file.DrawPixel
-func (f *File) DrawPixel(x, y int32, color rl.Color, layer *Layer) {
+func (f *File) DrawPixel(x, y int32, color rl.Color, layer *Layer, children map[*Layer][]*Layer) {
+	// Groups don't have pixels of their own
+	if layer.IsGroup {
+		return
+	}
+
 	// Set the pixel data in the current layer
 	if x >= 0 && y >= 0 && x < f.CanvasWidth && y < f.CanvasHeight {
 		loc := IntVec2{x, y}
 
+		// The selection masks what can be painted
+		if !f.InSelection(loc) {
+			return
+		}
+
 		// Add old color to history
 		oldColor, ok := layer.PixelData[loc]
 		if !ok {
@@ -117,20 +151,37 @@ func (f *File) DrawPixel(x, y int32, color rl.Color, layer *Layer) {
 			color = BlendWithOpacity(oldColor, color, layer.BlendMode)
 		}
 		layer.PixelData[loc] = color
+		if layer == f.GetCurrentLayer() {
+			f.syncSelectedPixel(loc, color)
+		}
+		// Effects can change pixels around this one
+		if layer.HasEffects() {
+			f.layerEffectsChanged = true
+		}
 
 		// Prevent overwriting the old color with the new color since this function is called every frame
 		// Always draws to the last element of f.History since the offset is removed automatically on mouse down
-		if oldColor != color {
+		if oldColor != color && len(f.History) > 0 {
 			latestHistoryInterface := f.History[len(f.History)-1]
 			latestHistory, ok := latestHistoryInterface.(HistoryPixel)
 			if ok {
-				ps := latestHistory.PixelState[IntVec2{x, y}]
+				// The pixel keeps the color it had before the first change
+				ps, changed := latestHistory.PixelState[loc]
+				if !changed {
+					ps.Prev = oldColor
+				}
 				ps.Current = color
-				ps.Prev = oldColor
-				latestHistory.PixelState[IntVec2{x, y}] = ps
+				latestHistory.PixelState[loc] = ps
 			}
 		}
 
+		prevComposite := f.RenderLayer.PixelData[loc]
+		nc := compositePixel(children, nil, loc)
+		f.RenderLayer.PixelData[loc] = nc
+		if Headless {
+			return
+		}
+
 		// Draw to passed layer
 		rl.BeginTextureMode(layer.Canvas)
 		if color == rl.Blank {
@@ -148,19 +199,10 @@ func (f *File) DrawPixel(x, y int32, color rl.Color, layer *Layer) {
 
 		// Erase current pixel color
 		rl.BeginBlendMode(rl.BlendSubtractColors)
-		rl.DrawPixel(x, y, f.RenderLayer.PixelData[loc])
+		rl.DrawPixel(x, y, prevComposite)
 		rl.EndBlendMode()
 
 		rl.BeginBlendMode(rl.BlendAlpha)
-		nc := rl.Blank
-		for _, layer := range f.Layers[:len(f.Layers)-1] {
-			if !layer.Hidden {
-				if layerColor, ok := layer.PixelData[loc]; ok {
-					nc = BlendWithOpacity(nc, layerColor, layer.BlendMode)
-				}
-			}
-		}
-		f.RenderLayer.PixelData[loc] = nc
 		rl.DrawPixel(x, y, rl.Black)
 		rl.DrawPixel(x, y, nc)
 		rl.EndBlendMode()
@@ -188,6 +230,14 @@ type FileSer struct {
 
 	Layers     []*LayerSer
 	Animations []*AnimationSer
+
+	// ExportSettings are the settings the file was last exported with
+	ExportSettings ExportSettings
+
+	Guides        []Guide
+	ReferenceGrid ReferenceGrid
+
+	SelectionMasks []SelectionMask
 }
 
 // LayerSer contains only the fields that need to be serialized
@@ -196,6 +246,40 @@ type LayerSer struct {
 	Name          string
 	PixelData     map[IntVec2]rl.Color
 	Width, Height int32
+
+	// Parent is the index of the parent group plus one, 0 for no parent
+	Parent                               int32
+	IsGroup, Collapsed, Clipping, IsMask bool
+	BlendMode                            rl.BlendMode
+	Effects                              []LayerEffect
+	EffectsHidden                        bool
+}
+
+// checkLayerParents returns an error unless every layer's group is a group
+// above it, with the layers in between also in the group. Following the
+// groups can't loop since each one is higher up
+func checkLayerParents(layers []*LayerSer) error {
+	// The last layer is for tool previews
+	layers = layers[:len(layers)-1]
+	for i, layer := range layers {
+		parent := int(layer.Parent) - 1
+		if parent >= 0 && (parent <= i || parent >= len(layers) || !layers[parent].IsGroup) {
+			return fmt.Errorf("layer \"%s\" isn't below a group it can be in", layer.Name)
+		}
+	}
+	for i, layer := range layers {
+		parent := int(layer.Parent) - 1
+		for j := i + 1; j < parent; j++ {
+			p := int(layers[j].Parent) - 1
+			for p >= 0 && p < parent {
+				p = int(layers[p].Parent) - 1
+			}
+			if p != parent {
+				return fmt.Errorf("layer \"%s\" is between the group \"%s\" and its layers", layers[j].Name, layers[parent].Name)
+			}
+		}
+	}
+	return nil
 }
 
 // AnimationSer contains only the fields that need to be serialized
@@ -222,18 +306,33 @@ type File struct {
 
 	// FileChanged is true if a change has been made since saving
 	FileChanged bool
+	// savedPosition is the HistoryPosition when the file was saved, so that
+	// undoing back to it leaves the file unchanged. It's unsavedPosition if
+	// the saved state can't be reached
+	savedPosition int32
+	// layerEffectsChanged is true if a layer with effects has been drawn on
+	// since the effects were last drawn
+	layerEffectsChanged bool
+	// autosaveID names the file's autosave in the recovery directory
+	autosaveID string
+
+	// ExportSettings are the settings the file was last exported with
+	ExportSettings ExportSettings
 
 	Layers       []*Layer // The last one is for tool previews
 	RenderLayer  *Layer   // Blends all layers and renders only this layer
 	CurrentLayer int32
+	// SelectedLayers are selected along with the current layer, by shift
+	// clicking them in the layers list
+	SelectedLayers map[*Layer]bool
 
 	Animations       []*Animation
 	CurrentAnimation int32
 
-	History           []interface{}
+	History           []HistoryAction
 	HistoryMaxActions int32
-	historyOffset     int32    // How many undos have been made
-	deletedLayers     []*Layer // stack of layers, AddNewLayer destroys history chain
+	HistoryMaxBytes   int   // Oldest actions are dropped when the history uses more than this
+	historyOffset     int32 // How many undos have been made
 
 	// For preventing multiple event firing
 	HasDoneMouseUpLeft  bool
@@ -241,6 +340,11 @@ type File struct {
 
 	// If grid should be drawn
 	DrawGrid bool
+	// Guides and the reference grid help with lining things up
+	Guides        []Guide
+	ReferenceGrid ReferenceGrid
+	// SelectionMasks are saved selections
+	SelectionMasks []SelectionMask
 
 	// Used by system_file.go
 	FileCameraTarget rl.Vector2 // temp storage for calculations
@@ -255,6 +359,9 @@ type File struct {
 	// Used for history appending, pixel overwriting/transparency logic
 	// True after a selection has been made, false when nothing is selected
 	SelectionMoving bool
+	// selectionID identifies the action which recorded picking up the
+	// moving selection
+	selectionID int32
 	// SelectionResizing is true when the selection is being resized
 	SelectionResizing bool
 	// Bounds can be moved if dragged within this area
@@ -263,6 +370,11 @@ type File struct {
 	OrigSelectionBounds [4]int32
 	// True if paste event has just happened
 	IsSelectionPasted bool
+	// SelectionTransform is the scale, flip and rotation of the selection
+	// until it's committed, or nil
+	SelectionTransform *SelectionTransform
+	// FilterPreview is the filter being shown before it's applied, or nil
+	FilterPreview *FilterPreview
 
 	CurrentPalette int32
 
@@ -302,18 +414,20 @@ func NewFile(canvasWidth, canvasHeight, tileWidth, tileHeight int32) *File {
 		},
 		RenderLayer: NewLayer(canvasWidth, canvasHeight, "render", rl.Blank, true),
 
-		FileChanged: false,
+		FileChanged:   false,
+		savedPosition: -1,
 
 		Animations: make([]*Animation, 0),
 
-		History:           make([]interface{}, 0, 50),
-		HistoryMaxActions: 500, // TODO get from config
-		deletedLayers:     make([]*Layer, 0, 10),
+		History:           make([]HistoryAction, 0, 50),
+		HistoryMaxActions: Settings.HistoryData.MaxActions,
+		HistoryMaxBytes:   int(Settings.HistoryData.MaxMemoryMB) * 1024 * 1024,
 
 		HasDoneMouseUpLeft:  true,
 		HasDoneMouseUpRight: true,
 
-		DrawGrid: canvasHeight <= 64, // don't draw the grid for anything bigger than default size
+		DrawGrid:      canvasHeight <= 64, // don't draw the grid for anything bigger than default size
+		ReferenceGrid: NewReferenceGrid(tileWidth, tileHeight),
 
 		FileCamera: rl.Camera2D{Zoom: 12.0 * scaleRatio,
 			Offset: rl.NewVector2(
@@ -361,17 +475,16 @@ const (
 
 // ResizeCanvas resizes the canvas from a specified edge
 func (f *File) ResizeCanvas(width, height int32, direction ResizeDirection) {
-	prevLayerDatas := make([]map[IntVec2]rl.Color, 0, len(f.Layers))
-	currentLayerDatas := make([]map[IntVec2]rl.Color, 0, len(f.Layers))
+	layerStates := make([][]byte, 0, len(f.Layers))
 
 	for _, layer := range f.Layers {
-		prevLayerDatas = append(prevLayerDatas, layer.PixelData)
+		prev := layer.PixelData
 		layer.Resize(width, height, direction)
-		currentLayerDatas = append(currentLayerDatas, layer.PixelData)
+		layerStates = append(layerStates, diffPixels(prev, layer.PixelData))
 	}
 	f.RenderLayer.Resize(width, height, direction)
 
-	f.AppendHistory(HistoryResize{prevLayerDatas, currentLayerDatas, f.CanvasWidth, f.CanvasHeight, width, height})
+	f.AppendHistory(HistoryResize{layerStates, f.CanvasWidth, f.CanvasHeight, width, height})
 	f.CanvasWidth = width
 	f.CanvasHeight = height
 
@@ -381,9 +494,35 @@ func (f *File) ResizeCanvas(width, height int32, direction ResizeDirection) {
 
 // ResizeTileSize resizes the tile size
 func (f *File) ResizeTileSize(width, height int32) {
+	if width == f.TileWidth && height == f.TileHeight {
+		return
+	}
+	f.AppendHistory(HistoryTileSize{f.TileWidth, f.TileHeight, width, height})
+	f.setTileSize(width, height)
+}
+
+// setTileSize sets the tile size without recording history
+func (f *File) setTileSize(width, height int32) {
 	f.RedrawRenderLayer()
 	f.TileWidth = width
 	f.TileHeight = height
+	f.TileWidthResizePreview = width
+	f.TileHeightResizePreview = height
+}
+
+// TileCount returns how many whole tiles fit on the canvas
+func (f *File) TileCount() int32 {
+	return (f.CanvasWidth / f.TileWidth) * (f.CanvasHeight / f.TileHeight)
+}
+
+// TileOrigin returns the top left of a tile. Tiles are counted left to right,
+// then top to bottom
+func (f *File) TileOrigin(index int32) IntVec2 {
+	perRow := MaxInt32(f.CanvasWidth/f.TileWidth, 1)
+	return IntVec2{
+		X: (index % perRow) * f.TileWidth,
+		Y: (index / perRow) * f.TileHeight,
+	}
 }
 
 // DeleteSelection deletes the selection
@@ -401,6 +540,7 @@ func (f *File) CancelSelection() {
 	f.Selection = make(map[IntVec2]rl.Color)
 	f.SelectionMoving = false
 	f.DoingSelection = false
+	f.SelectionTransform = nil
 }
 
 // Copy the selection
@@ -442,6 +582,7 @@ func (f *File) Paste() {
 	f.SelectionMoving = false
 	f.IsSelectionPasted = true
 	f.DoingSelection = true
+	f.SelectionTransform = nil
 
 	f.Selection = make(map[IntVec2]rl.Color)
 	for v, c := range CopiedSelection {
@@ -473,24 +614,21 @@ func (f *File) MoveSelection(dx, dy int32) {
 
 	if len(f.Selection) > 0 {
 		if !f.SelectionMoving {
+			// The action is kept by CommitSelection, even if others are
+			// added before the selection is placed
+			f.selectionID++
+			latestHistory := HistoryPixel{
+				PixelState:  make(map[IntVec2]PixelStateData),
+				LayerIndex:  f.CurrentLayer,
+				Name:        "Move selection",
+				selectionID: f.selectionID,
+			}
+			f.AppendHistory(latestHistory)
 			f.SelectionMoving = true
 
-			f.AppendHistory(HistoryPixel{make(map[IntVec2]PixelStateData), CurrentFile.CurrentLayer})
-
 			for loc := range f.Selection {
-				// Alter history
-				latestHistoryInterface := f.History[len(f.History)-1]
-				latestHistory, ok := latestHistoryInterface.(HistoryPixel)
-				if ok {
-					ps := latestHistory.PixelState[loc]
-					if !f.IsSelectionPasted {
-						ps.Current = rl.Blank
-						ps.Prev = cl.PixelData[loc]
-						latestHistory.PixelState[loc] = ps
-					}
-				}
-
 				if !f.IsSelectionPasted {
+					latestHistory.PixelState[loc] = PixelStateData{Prev: cl.PixelData[loc], Current: rl.Blank}
 					cl.PixelData[loc] = rl.Blank
 				}
 			}
@@ -505,6 +643,12 @@ func (f *File) MoveSelection(dx, dy int32) {
 		f.OrigSelectionBounds[1] = f.SelectionBounds[1]
 		f.OrigSelectionBounds[2] = f.SelectionBounds[2]
 		f.OrigSelectionBounds[3] = f.SelectionBounds[3]
+		if t := f.SelectionTransform; t != nil {
+			t.Params.X += dx
+			t.Params.Y += dy
+			t.applied.X += dx
+			t.applied.Y += dy
+		}
 
 		newSelection := make(map[IntVec2]rl.Color)
 		for loc, color := range f.Selection {
@@ -524,13 +668,15 @@ func (f *File) CommitSelection() {
 	f.DoingSelection = false
 
 	if f.SelectionMoving {
-		f.SelectionMoving = false
-
-		if len(f.History) <= 0 {
-			return
+		// The pixels are always placed, the action which picked them up
+		// records it unless it has been removed
+		cl := f.floatingLayer()
+		var latestHistory HistoryPixel
+		index := f.floatingHistory()
+		if index >= 0 {
+			latestHistory = f.History[index].(HistoryPixel)
 		}
-
-		cl := f.GetCurrentLayer()
+		f.SelectionMoving = false
 
 		// Alter PixelData and history
 		for loc, color := range f.Selection {
@@ -539,29 +685,27 @@ func (f *File) CommitSelection() {
 				continue
 			}
 
-			latestHistoryInterface := f.History[len(f.History)-1]
-			latestHistory, ok := latestHistoryInterface.(HistoryPixel)
-			if ok {
-				var currentColor rl.Color
-
-				alreadyWritten, ok := latestHistory.PixelState[loc]
-				if ok {
-					currentColor = BlendWithOpacity(alreadyWritten.Current, color, cl.BlendMode)
-					// Overwrite the existing history
-					alreadyWritten.Current = currentColor
-					latestHistory.PixelState[loc] = alreadyWritten
-
-				} else {
-					currentColor = BlendWithOpacity(cl.PixelData[loc], color, cl.BlendMode)
-					ps := latestHistory.PixelState[loc]
-					ps.Current = currentColor
+			currentColor := BlendWithOpacity(cl.PixelData[loc], color, cl.BlendMode)
+			if index >= 0 {
+				ps, ok := latestHistory.PixelState[loc]
+				if !ok {
 					ps.Prev = cl.PixelData[loc]
-					latestHistory.PixelState[loc] = ps
-
 				}
+				ps.Current = currentColor
+				latestHistory.PixelState[loc] = ps
+			}
+			cl.PixelData[loc] = currentColor
+		}
 
-				cl.PixelData[loc] = currentColor
-
+		if index >= 0 {
+			latestHistory.selectionID = 0
+			f.History[index] = latestHistory
+			if f.savedPosition >= int32(index) {
+				f.savedPosition = unsavedPosition
+			}
+			// Actions are compressed once nothing else is added to them
+			if index < len(f.History)-1 {
+				f.History[index] = latestHistory.compress()
 			}
 		}
 
@@ -572,21 +716,70 @@ func (f *File) CommitSelection() {
 	// Reset the selection
 	f.Selection = make(map[IntVec2]rl.Color)
 	f.SelectionPixels = make([]rl.Color, 0, 0)
+	f.SelectionTransform = nil
 }
 
-// DeleteAnimation deletes an animation
-func (f *File) DeleteAnimation(index int32) error {
-	if index-1 >= int32(len(f.Animations)) {
-		return fmt.Errorf("Animation not in range")
+// GetAnimationState returns a copy of the animations
+func (f *File) GetAnimationState() AnimationState {
+	s := AnimationState{
+		Animations: make([]Animation, len(f.Animations)),
+		Current:    f.CurrentAnimation,
+	}
+	for i, anim := range f.Animations {
+		s.Animations[i] = *anim
 	}
+	return s
+}
 
-	f.Animations = append(f.Animations[:index], f.Animations[index+1:]...)
-	// set animation to last
-	f.CurrentAnimation = int32(len(f.Animations) - 1)
+// SetAnimationState replaces the animations with the ones in the state
+func (f *File) SetAnimationState(s AnimationState) {
+	f.Animations = make([]*Animation, len(s.Animations))
+	for i := range s.Animations {
+		anim := s.Animations[i]
+		f.Animations[i] = &anim
+	}
+	f.CurrentAnimation = s.Current
+}
+
+// changeAnimations records the changes made to the animations by change.
+// When merge is true and the last action has the same name, it's extended
+// instead so typing a name is a single action
+func (f *File) changeAnimations(name string, merge bool, change func() error) error {
+	prev := f.GetAnimationState()
+	if err := change(); err != nil {
+		return err
+	}
+	current := f.GetAnimationState()
+	if reflect.DeepEqual(prev, current) {
+		return nil
+	}
 
+	if last, ok := f.lastHistory(); ok && merge {
+		if typed, ok := last.(HistoryAnimations); ok && typed.Name == name {
+			typed.Current = current
+			f.replaceLastHistory(typed)
+			return nil
+		}
+	}
+	f.AppendHistory(HistoryAnimations{prev, current, name})
 	return nil
 }
 
+// DeleteAnimation deletes an animation
+func (f *File) DeleteAnimation(index int32) error {
+	return f.changeAnimations("Delete animation", false, func() error {
+		if index < 0 || index >= int32(len(f.Animations)) {
+			return fmt.Errorf("Animation not in range")
+		}
+
+		f.Animations = append(f.Animations[:index], f.Animations[index+1:]...)
+		// set animation to last
+		f.CurrentAnimation = int32(len(f.Animations) - 1)
+
+		return nil
+	})
+}
+
 // SetCurrentAnimation sets the current animation
 func (f *File) SetCurrentAnimation(index int32) {
 	f.CurrentAnimation = index
@@ -602,7 +795,7 @@ func (f *File) GetCurrentAnimation() *Animation {
 
 // GetAnimation gets the animation at the specified index
 func (f *File) GetAnimation(index int32) (*Animation, error) {
-	if index-1 >= int32(len(f.Animations)) {
+	if index < 0 || index >= int32(len(f.Animations)) {
 		return nil, fmt.Errorf("Animation not in range")
 	}
 	return f.Animations[index], nil
@@ -610,42 +803,59 @@ func (f *File) GetAnimation(index int32) (*Animation, error) {
 
 // AddNewAnimation adds a new animation
 func (f *File) AddNewAnimation() {
-	f.Animations = append(f.Animations, &Animation{
-		Name:       fmt.Sprintf("Anim %d", len(f.Animations)),
-		FrameStart: 0,
-		FrameEnd:   0,
-		Timing:     5.0, // 5 fps
+	f.changeAnimations("New animation", false, func() error {
+		f.Animations = append(f.Animations, &Animation{
+			Name:       fmt.Sprintf("Anim %d", len(f.Animations)),
+			FrameStart: 0,
+			FrameEnd:   0,
+			Timing:     5.0, // 5 fps
+		})
+		return nil
 	})
 }
 
 // SetAnimationFrames sets the current animation's frames
 func (f *File) SetAnimationFrames(index, firstSprite, lastSprite int32) {
-	anim, err := f.GetAnimation(index)
+	err := f.changeAnimations("Animation frames", false, func() error {
+		anim, err := f.GetAnimation(index)
+		if err != nil {
+			return err
+		}
+		anim.FrameStart = firstSprite
+		anim.FrameEnd = lastSprite
+		return nil
+	})
 	if err != nil {
 		log.Println(err)
-		return
 	}
-	anim.FrameStart = firstSprite
-	anim.FrameEnd = lastSprite
 }
 
 // SetCurrentAnimationTiming sets the current animation's timing
 // The argument is the frames per second
 func (f *File) SetCurrentAnimationTiming(timing float32) {
-	anim := f.GetCurrentAnimation()
-	if anim != nil {
-		anim.Timing = timing
-	}
+	name := fmt.Sprintf("Animation %d timing", f.CurrentAnimation)
+	f.changeAnimations(name, true, func() error {
+		anim := f.GetCurrentAnimation()
+		if anim != nil {
+			anim.Timing = timing
+		}
+		return nil
+	})
 }
 
 // SetAnimationName sets the current animation's name
 func (f *File) SetAnimationName(index int32, name string) {
-	anim, err := f.GetAnimation(index)
+	err := f.changeAnimations(fmt.Sprintf("Rename animation %d", index), true, func() error {
+		anim, err := f.GetAnimation(index)
+		if err != nil {
+			return err
+		}
+		anim.Name = name
+		return nil
+	})
 	if err != nil {
 		log.Println(err)
-		return
 	}
-	anim.Name = name
 }
 
 // SetCurrentLayer sets the current layer
@@ -653,21 +863,50 @@ func (f *File) SetCurrentLayer(index int32) {
 	f.CurrentLayer = index
 }
 
+// ToggleLayerSelected selects the layer along with the current layer, or
+// deselects it if it's already selected
+func (f *File) ToggleLayerSelected(index int32) {
+	if f.SelectedLayers == nil {
+		f.SelectedLayers = make(map[*Layer]bool)
+	}
+	layer := f.Layers[index]
+	if f.SelectedLayers[layer] {
+		delete(f.SelectedLayers, layer)
+	} else {
+		f.SelectedLayers[layer] = true
+	}
+}
+
+// ClearSelectedLayers leaves only the current layer selected
+func (f *File) ClearSelectedLayers() {
+	f.SelectedLayers = nil
+}
+
+// IsLayerSelected returns whether the layer at index is the current layer or
+// is selected along with it
+func (f *File) IsLayerSelected(index int32) bool {
+	return index == f.CurrentLayer || f.SelectedLayers[f.Layers[index]]
+}
+
 // GetCurrentLayer returns the current layer
 func (f *File) GetCurrentLayer() *Layer {
 	return f.Layers[f.CurrentLayer]
 }
 
-// DeleteLayer deletes the layer.
+// DeleteLayer deletes the layer along with its mask.
 // Won't delete anything if only one visible layer exists
 // Sets the current layer to the top-most layer
 func (f *File) DeleteLayer(index int32, appendHistory bool) error {
+	if f.Layers[index].IsGroup || f.layerBlockEnd(index) != index {
+		return f.deleteLayerBlock(index, appendHistory)
+	}
+
 	if len(f.Layers) > 2 {
-		f.deletedLayers = append(f.deletedLayers, f.Layers[index])
+		layer := f.Layers[index]
 		f.Layers = append(f.Layers[:index], f.Layers[index+1:]...)
 
 		if appendHistory {
-			f.AppendHistory(HistoryLayer{HistoryLayerActionDelete, index})
+			f.AppendHistory(HistoryLayer{HistoryLayerActionDelete, index, layer})
 		}
 
 		if f.CurrentLayer > int32(len(f.Layers)-2) {
@@ -680,27 +919,48 @@ func (f *File) DeleteLayer(index int32, appendHistory bool) error {
 	return fmt.Errorf("Couldn't delete layer as it's the only one visible")
 }
 
-// RestoreLayer restores the last layer from f.deletedLayers to the position of
-// index in f.Layers
-func (f *File) RestoreLayer(index int32) error {
-	if len(f.deletedLayers) == 0 {
-		return fmt.Errorf("No layers to restore")
+// deleteLayerBlock deletes the layer, its mask and all of its children
+func (f *File) deleteLayerBlock(index int32, appendHistory bool) error {
+	start, end := f.layerBlockStart(index), f.layerBlockEnd(index)
+	name := "Delete layer"
+	if f.Layers[index].IsGroup {
+		name = "Delete group"
+	}
+	if len(f.Layers)-int(end-start+1) < 2 {
+		if f.Layers[index].IsGroup {
+			return fmt.Errorf("Couldn't delete group as it contains every layer")
+		}
+		return fmt.Errorf("Couldn't delete layer as it's the only one visible")
+	}
+
+	prev := f.GetLayerStructure()
+	f.Layers = append(f.Layers[:start], f.Layers[end+1:]...)
+
+	if appendHistory {
+		f.AppendHistory(HistoryLayerStructure{prev, f.GetLayerStructure(), name})
+	}
+
+	if f.CurrentLayer > int32(len(f.Layers)-2) {
+		f.SetCurrentLayer(int32(len(f.Layers) - 2))
+	}
+
+	return nil
+}
+
+// RestoreLayer puts a deleted layer back at index in f.Layers
+func (f *File) RestoreLayer(index int32, layer *Layer) error {
+	if index < 0 || index > int32(len(f.Layers)-1) {
+		return fmt.Errorf("Couldn't restore layer: index %d out of range", index)
 	}
 
-	f.Layers = append(
-		f.Layers[:index],
-		append(
-			[]*Layer{f.deletedLayers[len(f.deletedLayers)-1]},
-			f.Layers[index:]...)...)
-	f.deletedLayers = append(
-		f.deletedLayers[:len(f.deletedLayers)-1],
-		f.deletedLayers[len(f.deletedLayers):]...)
+	f.insertLayer(index, layer)
 
 	f.RedrawRenderLayer()
 	return nil
 }
 
-// MergeLayerDown merges the layer with the one below
+// MergeLayerDown merges the layer with the one below. The layer's mask and
+// clipping are applied to the merged pixels and the mask is removed
 func (f *File) MergeLayerDown(index int32) error {
 	if len(f.Layers) <= 2 {
 		return fmt.Errorf("Couldn't merge layer down: Not enough layers")
@@ -708,12 +968,36 @@ func (f *File) MergeLayerDown(index int32) error {
 	if index == 0 {
 		return fmt.Errorf("Couldn't merge layer down: Can't merge lowest layer")
 	}
+	if from, to := f.Layers[index], f.Layers[index-1]; from.IsGroup || to.IsGroup || from.IsMask || to.IsMask || from.Parent != to.Parent {
+		return fmt.Errorf("Couldn't merge layer down: Only layers in the same group can be merged")
+	}
+	if from, to := f.Layers[index], f.Layers[index-1]; to.Clipping && !from.Clipping {
+		return fmt.Errorf("Couldn't merge layer down: The layer below is clipped")
+	}
+
+	// Effects are merged as they're shown
+	f.updateLayerEffects()
 
-	// old layer pixel state
-	historyPixel := HistoryPixel{make(map[IntVec2]PixelStateData), index - 1}
+	prev := f.GetLayerStructure()
+	historyPixel := HistoryPixel{PixelState: make(map[IntVec2]PixelStateData), LayerIndex: index - 1}
 	from := f.Layers[index]
 	to := f.Layers[index-1]
-	for loc, color := range from.PixelData {
+	end := f.layerBlockEnd(index)
+	var mask map[IntVec2]rl.Color
+	if end != index && !f.Layers[end].Hidden {
+		mask = f.Layers[end].PixelData
+	}
+	toPixels := to.DrawnPixels()
+	for loc, color := range from.DrawnPixels() {
+		if mask != nil {
+			color.A = scaleAlpha(color.A, 255-mask[loc].A)
+		}
+		// Clipped to the layer below unless both are clipped to the same
+		// layer
+		if from.Clipping && !to.Clipping {
+			color.A = scaleAlpha(color.A, toPixels[loc].A)
+		}
+
 		hist := historyPixel.PixelState[loc]
 		hist.Prev = to.PixelData[loc]
 		newColor := BlendWithOpacity(to.PixelData[loc], color, from.BlendMode)
@@ -722,20 +1006,19 @@ func (f *File) MergeLayerDown(index int32) error {
 
 		// Save back into the map
 		historyPixel.PixelState[loc] = hist
-
-		if color != rl.Blank && color != to.PixelData[loc] {
-		}
 	}
 	to.Redraw()
 
-	if err := f.DeleteLayer(index, false); err != nil {
-		return err
+	f.Layers = append(f.Layers[:index], f.Layers[end+1:]...)
+	if f.CurrentLayer > int32(len(f.Layers)-2) {
+		f.SetCurrentLayer(int32(len(f.Layers) - 2))
 	}
 
 	comp := CompoundHistory{
-		Actions: []interface{}{
+		Name: "Merge down",
+		Actions: []HistoryAction{
 			historyPixel,
-			HistoryLayer{HistoryLayerActionDelete, index},
+			HistoryLayerStructure{prev, f.GetLayerStructure(), "Merge down"},
 		},
 	}
 	f.AppendHistory(comp)
@@ -750,210 +1033,430 @@ func (f *File) AddNewLayer() {
 	f.Layers = append(f.Layers[:len(f.Layers)-1], newLayer, f.Layers[len(f.Layers)-1])
 	f.SetCurrentLayer(int32(len(f.Layers) - 2)) // -2 bc temp layer is excluded
 
-	f.AppendHistory(HistoryLayer{HistoryLayerActionCreate, f.CurrentLayer})
+	f.AppendHistory(HistoryLayer{HistoryLayerActionCreate, f.CurrentLayer, newLayer})
+	f.RedrawRenderLayer()
+}
+
+// LayerIndex returns the index of the layer in f.Layers, or -1 if it isn't
+// in the file
+func (f *File) LayerIndex(layer *Layer) int32 {
+	for i, l := range f.Layers {
+		if l == layer {
+			return int32(i)
+		}
+	}
+	return -1
+}
+
+// GetLayerStructure returns the current order and nesting of the layers
+func (f *File) GetLayerStructure() LayerStructure {
+	s := LayerStructure{
+		Layers:  make([]*Layer, len(f.Layers)),
+		Parents: make([]*Layer, len(f.Layers)),
+	}
+	for i, layer := range f.Layers {
+		s.Layers[i] = layer
+		s.Parents[i] = layer.Parent
+	}
+	return s
+}
+
+// SetLayerStructure restores the order and nesting of the layers
+func (f *File) SetLayerStructure(s LayerStructure) {
+	current := f.GetCurrentLayer()
+
+	f.Layers = make([]*Layer, len(s.Layers))
+	for i, layer := range s.Layers {
+		f.Layers[i] = layer
+		layer.Parent = s.Parents[i]
+	}
+
+	if index := f.LayerIndex(current); index >= 0 && index < int32(len(f.Layers)-1) {
+		f.SetCurrentLayer(index)
+	} else {
+		f.SetCurrentLayer(int32(len(f.Layers) - 2))
+	}
 	f.RedrawRenderLayer()
 }
 
-// MoveLayerUp moves the layer up
+// layerBlockStart returns the index of the lowest layer in the layer's block,
+// which is the layer, its mask and all of its children. A mask is in the
+// block of the layer it hides
+func (f *File) layerBlockStart(index int32) int32 {
+	if f.isLayerMask(index) {
+		index--
+	}
+	layer := f.Layers[index]
+	start := index
+	for start > 0 && f.Layers[start-1].HasAncestor(layer) {
+		start--
+	}
+	return start
+}
+
+// layerBlockEnd returns the index of the highest layer in the layer's block,
+// which is the layer's mask if it has one
+func (f *File) layerBlockEnd(index int32) int32 {
+	if index+1 < int32(len(f.Layers)-1) && f.isLayerMask(index+1) {
+		return index + 1
+	}
+	return index
+}
+
+// isLayerMask returns whether the layer at index is a mask hiding the layer
+// directly below it
+func (f *File) isLayerMask(index int32) bool {
+	layer := f.Layers[index]
+	return layer.IsMask && index > 0 && !f.Layers[index-1].IsMask && f.Layers[index-1].Parent == layer.Parent
+}
+
+// moveLayerBlocks swaps two neighbouring blocks of layers, the lower one
+// being from lowStart to highStart-1 and the upper one from highStart to
+// highEnd
+func (f *File) moveLayerBlocks(lowStart, highStart, highEnd int32) {
+	low := append([]*Layer{}, f.Layers[lowStart:highStart]...)
+	high := append([]*Layer{}, f.Layers[highStart:highEnd+1]...)
+	copy(f.Layers[lowStart:], high)
+	copy(f.Layers[lowStart+int32(len(high)):], low)
+}
+
+// MoveLayerUp moves the layer, along with its mask and its children if it is
+// a group, above the next layer in the same group
 func (f *File) MoveLayerUp(index int32, appendHistory bool) error {
-	if index < int32(len(f.Layers)-2) {
-		toMove := f.Layers[index]
-		f.Layers = append(f.Layers[:index], f.Layers[index+1:]...)
-		f.Layers = append(f.Layers[:index], append([]*Layer{f.Layers[index], toMove}, f.Layers[index+1:]...)...)
+	layer := f.Layers[index]
+	top := f.layerBlockEnd(index)
+	if top >= int32(len(f.Layers)-2) || f.Layers[top+1] == layer.Parent {
+		return fmt.Errorf("Couldn't move layer up")
+	}
 
-		if appendHistory {
-			f.AppendHistory(HistoryLayer{HistoryLayerActionMoveUp, index})
-		}
-		f.RedrawRenderLayer()
-		return nil
+	prev := f.GetLayerStructure()
+	current := f.GetCurrentLayer()
+
+	// Find the top of the block above
+	end := top + 1
+	for f.Layers[end].Parent != layer.Parent {
+		end++
 	}
+	f.moveLayerBlocks(f.layerBlockStart(index), top+1, f.layerBlockEnd(end))
+	f.SetCurrentLayer(f.LayerIndex(current))
 
-	return fmt.Errorf("Couldn't move layer up")
+	if appendHistory {
+		f.AppendHistory(HistoryLayerStructure{prev, f.GetLayerStructure(), "Move layer up"})
+	}
+	f.RedrawRenderLayer()
+	return nil
 }
 
-// MoveLayerDown moves the layer down
+// MoveLayerDown moves the layer, along with its mask and its children if it is
+// a group, below the previous layer in the same group
 func (f *File) MoveLayerDown(index int32, appendHistory bool) error {
-	if index > 0 {
-		toMove := f.Layers[index]
-		f.Layers = append(f.Layers[:index], f.Layers[index+1:]...)
-		if index-1 == 0 {
-			f.Layers = append([]*Layer{toMove}, append(f.Layers[:index], f.Layers[index:]...)...)
-		} else {
-			f.Layers = append(f.Layers[:index-1], append([]*Layer{toMove}, f.Layers[index-1:]...)...)
-		}
+	layer := f.Layers[index]
+	start := f.layerBlockStart(index)
+	if start == 0 || f.Layers[start-1].Parent != layer.Parent {
+		return fmt.Errorf("Couldn't move layer down")
+	}
 
-		if appendHistory {
-			f.AppendHistory(HistoryLayer{HistoryLayerActionMoveDown, index})
+	prev := f.GetLayerStructure()
+	current := f.GetCurrentLayer()
+
+	f.moveLayerBlocks(f.layerBlockStart(start-1), start, f.layerBlockEnd(index))
+	f.SetCurrentLayer(f.LayerIndex(current))
+
+	if appendHistory {
+		f.AppendHistory(HistoryLayerStructure{prev, f.GetLayerStructure(), "Move layer down"})
+	}
+	f.RedrawRenderLayer()
+	return nil
+}
+
+// MoveLayer moves the layer up or down within its group until it reaches
+// target, or can't be moved any further
+func (f *File) MoveLayer(index, target int32) error {
+	prev := f.GetLayerStructure()
+	layer := f.Layers[index]
+
+	for index < target {
+		if err := f.MoveLayerUp(index, false); err != nil {
+			break
 		}
-		f.RedrawRenderLayer()
-		return nil
+		index = f.LayerIndex(layer)
+	}
+	for index > target {
+		if err := f.MoveLayerDown(index, false); err != nil {
+			break
+		}
+		index = f.LayerIndex(layer)
 	}
 
-	return fmt.Errorf("Couldn't move layer down")
+	if f.LayerIndex(layer) == prev.layerIndex(layer) {
+		return fmt.Errorf("Couldn't move layer")
+	}
+	f.AppendHistory(HistoryLayerStructure{prev, f.GetLayerStructure(), "Move layer"})
+	return nil
+}
 
+// copyLayerBlock copies the layer at index along with its mask and children,
+// sized to width and height. The copied block keeps its nesting, but the
+// layer and its mask aren't in any group. It also returns where the copy of
+// the layer is in the block, which is below its mask if it has one
+func (f *File) copyLayerBlock(index, width, height int32) ([]*Layer, int32) {
+	if f.isLayerMask(index) {
+		index--
+	}
+	start, end := f.layerBlockStart(index), f.layerBlockEnd(index)
+	copies := make(map[*Layer]*Layer)
+	block := make([]*Layer, 0, end-start+1)
+	for _, layer := range f.Layers[start : end+1] {
+		c := layer.Copy(width, height)
+		copies[layer] = c
+		block = append(block, c)
+	}
+	for i, layer := range f.Layers[start : end+1] {
+		block[i].Parent = copies[layer.Parent]
+	}
+	return block, index - start
 }
 
-// AppendHistory inserts a new history interface{} to f.History depending on the
-// historyOffset
-func (f *File) AppendHistory(action interface{}) {
-	f.FileChanged = true
-	// Clear everything past the offset if a change has been made after undoing
-	f.History = f.History[0 : int32(len(f.History))-f.historyOffset]
-	f.historyOffset = 0
+// insertLayerBlock inserts a block of layers made by copyLayerBlock at index
+// in the group parent and records the history under name
+func (f *File) insertLayerBlock(index int32, parent *Layer, block []*Layer, name string) {
+	if len(block) == 1 && !block[0].IsGroup {
+		block[0].Parent = parent
+		f.insertLayer(index, block[0])
+		f.AppendHistory(HistoryLayer{HistoryLayerActionCreate, index, block[0]})
+		f.RedrawRenderLayer()
+		return
+	}
 
-	if int32(len(f.History)) >= f.HistoryMaxActions {
-		f.History = append(f.History[int32(len(f.History))-f.HistoryMaxActions+1:f.HistoryMaxActions], action)
-	} else {
-		f.History = append(f.History, action)
+	prev := f.GetLayerStructure()
+	for _, layer := range block {
+		if layer.Parent == nil {
+			layer.Parent = parent
+		}
 	}
+	f.Layers = append(f.Layers[:index], append(block, f.Layers[index:]...)...)
+	f.AppendHistory(HistoryLayerStructure{prev, f.GetLayerStructure(), name})
+	f.RedrawRenderLayer()
+}
 
-	EditorsUIRebuild()
+// DuplicateLayer copies the layer, or the group and its children, along with
+// its mask and puts the copy above it. Duplicating a mask duplicates its layer
+func (f *File) DuplicateLayer(index int32) {
+	if f.isLayerMask(index) {
+		index--
+	}
+	block, copied := f.copyLayerBlock(index, f.CanvasWidth, f.CanvasHeight)
+	block[copied].Name += " copy"
+
+	at := f.layerBlockEnd(index) + 1
+	f.insertLayerBlock(at, f.Layers[index].Parent, block, "Duplicate layer")
+	f.SetCurrentLayer(at + copied)
 }
 
-// DrawPixelDataToCanvas redraws the canvas using the pixel data
-// This is useful for removing pixels since DrawPixel is additive, meaning that
-// a pixel can never be erased
-func (f *File) DrawPixelDataToCanvas() {
-	layer := f.GetCurrentLayer()
-	rl.BeginTextureMode(layer.Canvas)
-	rl.ClearBackground(rl.Blank)
-	for v, color := range layer.PixelData {
-		rl.DrawPixel(v.X, v.Y, color)
+// NewLayerFromSelection creates a layer containing the selected pixels above
+// the current layer
+func (f *File) NewLayerFromSelection() error {
+	if len(f.Selection) == 0 {
+		return fmt.Errorf("Couldn't create layer: Nothing is selected")
 	}
-	rl.EndTextureMode()
+
+	layer := NewLayer(f.CanvasWidth, f.CanvasHeight, "selection", rl.Blank, true)
+	for loc, color := range f.Selection {
+		if loc.X >= 0 && loc.Y >= 0 && loc.X < f.CanvasWidth && loc.Y < f.CanvasHeight {
+			layer.PixelData[loc] = color
+		}
+	}
+	layer.Redraw()
+
+	f.insertLayerBlock(f.layerBlockEnd(f.CurrentLayer)+1, f.GetCurrentLayer().Parent, []*Layer{layer}, "Layer from selection")
+	return nil
 }
 
-// Outline draws the left color around any non-transparent pixels (and is
-// restricted to the selection)
-// Will only outline pixels on the current layer. Make sure to merge layers if
-// sprite is composed of multiple parts
-func (f *File) Outline() {
-	var sx, sy int32 = 0, 0
-	mx, my := f.CanvasWidth, f.CanvasHeight
+// CopyLayerToFile copies the layer, or the group and its children, with its
+// mask to the top of another file. Pixels outside of the other file's canvas
+// are dropped
+func (f *File) CopyLayerToFile(index int32, target *File) {
+	block, copied := f.copyLayerBlock(index, target.CanvasWidth, target.CanvasHeight)
+	at := int32(len(target.Layers) - 1)
+	target.insertLayerBlock(at, nil, block, "Copy layer")
+	target.SetCurrentLayer(at + copied)
+}
 
-	latestHistory := HistoryPixel{make(map[IntVec2]PixelStateData), CurrentFile.CurrentLayer}
-	if f.DoingSelection {
-		// latestHistory is essentially ignored and whatever is in the selection
-		// is accounted for by f.MoveSelection
-		sx = f.SelectionBounds[0]
-		sy = f.SelectionBounds[1]
-		mx = f.SelectionBounds[2] + 1
-		my = f.SelectionBounds[3] + 1
-	} else {
-		// New history
-		CurrentFile.AppendHistory(latestHistory)
+// insertLayer inserts a layer at index, shifting the layers above it up
+func (f *File) insertLayer(index int32, layer *Layer) {
+	f.Layers = append(f.Layers[:index], append([]*Layer{layer}, f.Layers[index:]...)...)
+}
+
+// AddNewGroup puts the layer at index, along with its mask, in a new group.
+// Grouping a mask groups the layer it hides
+func (f *File) AddNewGroup(index int32) {
+	prev := f.GetLayerStructure()
+	if f.isLayerMask(index) {
+		index--
 	}
+	layer := f.Layers[index]
 
-	cl := f.GetCurrentLayer()
-	pixelLocations := make([]IntVec2, 0, 0)
-
-	for y := sy; y < my; y++ {
-		for x := sx; x < mx; x++ {
-			currentPos := IntVec2{x, y}
-			leftPos := IntVec2{x - 1, y}
-			rightPos := IntVec2{x + 1, y}
-			abovePos := IntVec2{x, y - 1}
-			belowPos := IntVec2{x, y + 1}
-
-			// Change where the pixels are sampled from if there is a selection
-			pixelSource := cl.PixelData
-			if f.DoingSelection {
-				pixelSource = f.Selection
-			}
+	group := NewLayer(f.CanvasWidth, f.CanvasHeight, "group", rl.Blank, true)
+	group.IsGroup = true
+	group.Parent = layer.Parent
 
-			if pixelSource[currentPos] != rl.Blank {
-				if pixelSource[leftPos] == rl.Blank {
-					pixelLocations = append(pixelLocations, leftPos)
-				}
-				if pixelSource[rightPos] == rl.Blank {
-					pixelLocations = append(pixelLocations, rightPos)
-				}
-				if pixelSource[abovePos] == rl.Blank {
-					pixelLocations = append(pixelLocations, abovePos)
-				}
-				if pixelSource[belowPos] == rl.Blank {
-					pixelLocations = append(pixelLocations, belowPos)
-				}
-			}
-		}
+	top := f.layerBlockEnd(index)
+	for _, l := range f.Layers[index : top+1] {
+		l.Parent = group
 	}
+	f.insertLayer(top+1, group)
+	f.SetCurrentLayer(top + 1)
+
+	f.AppendHistory(HistoryLayerStructure{prev, f.GetLayerStructure(), "Group"})
+	f.RedrawRenderLayer()
+}
 
-	for _, loc := range pixelLocations {
-		l := latestHistory.PixelState[loc]
-		l.Prev = rl.Blank // Only replacing transparent pixels
-		l.Current = LeftColor
-		latestHistory.PixelState[loc] = l
+// Ungroup removes the group, moving its children to the group's parent
+func (f *File) Ungroup(index int32) error {
+	group := f.Layers[index]
+	if !group.IsGroup {
+		return fmt.Errorf("Couldn't ungroup: Layer isn't a group")
+	}
 
-		if f.DoingSelection {
-			f.Selection[loc] = LeftColor
-		} else {
-			cl.PixelData[loc] = LeftColor
+	prev := f.GetLayerStructure()
+	for _, layer := range f.Layers {
+		if layer.Parent == group {
+			layer.Parent = group.Parent
 		}
 	}
+	f.Layers = append(f.Layers[:index], f.Layers[index+1:]...)
+	if f.CurrentLayer >= index && f.CurrentLayer > 0 {
+		f.SetCurrentLayer(f.CurrentLayer - 1)
+	}
+
+	f.AppendHistory(HistoryLayerStructure{prev, f.GetLayerStructure(), "Ungroup"})
+	f.RedrawRenderLayer()
+	return nil
+}
 
-	if f.DoingSelection && !f.SelectionMoving {
-		// Allow CommitSelection to detect a change
-		f.MoveSelection(0, 0)
-		f.CommitSelection()
+// AddLayerMask adds a mask directly above the layer at index
+func (f *File) AddLayerMask(index int32) error {
+	layer := f.Layers[index]
+	if layer.IsMask {
+		return fmt.Errorf("Couldn't add mask: Masks can't have masks")
+	}
+	if above := f.Layers[index+1]; above.IsMask && above.Parent == layer.Parent {
+		return fmt.Errorf("Couldn't add mask: Layer already has a mask")
 	}
 
-	cl.Redraw()
+	prev := f.GetLayerStructure()
+	mask := NewLayer(f.CanvasWidth, f.CanvasHeight, layer.Name+" mask", rl.Blank, true)
+	mask.IsMask = true
+	mask.Parent = layer.Parent
+	f.insertLayer(index+1, mask)
+	f.SetCurrentLayer(index + 1)
+
+	f.AppendHistory(HistoryLayerStructure{prev, f.GetLayerStructure(), "Add mask"})
+	f.RedrawRenderLayer()
+	return nil
+}
+
+// setLayerProperties records the changes made to the layer's properties by
+// change. When merge is true and the last action has the same name and
+// layer, it's extended instead so typing a name is a single action
+func (f *File) setLayerProperties(index int32, name string, merge bool, change func(p *LayerProperties)) {
+	layer := f.Layers[index]
+	prev := layer.Properties()
+	current := prev
+	change(&current)
+	if current == prev {
+		return
+	}
+	layer.SetProperties(current)
 	f.RedrawRenderLayer()
+
+	if last, ok := f.lastHistory(); ok && merge {
+		if typed, ok := last.(HistoryLayerProperties); ok && typed.LayerIndex == index && typed.Name == name {
+			typed.Current = current
+			f.replaceLastHistory(typed)
+			return
+		}
+	}
+	f.AppendHistory(HistoryLayerProperties{index, prev, current, name})
+}
+
+// SetLayerClipping sets if the layer is clipped to the layer below
+func (f *File) SetLayerClipping(index int32, clipping bool) {
+	f.setLayerProperties(index, "Clipping", false, func(p *LayerProperties) {
+		p.Clipping = clipping
+	})
+}
+
+// SetLayerHidden hides or shows the layer
+func (f *File) SetLayerHidden(index int32, hidden bool) {
+	name := "Show"
+	if hidden {
+		name = "Hide"
+	}
+	f.setLayerProperties(index, name, false, func(p *LayerProperties) {
+		p.Hidden = hidden
+	})
+}
+
+// SetLayerName renames the layer
+func (f *File) SetLayerName(index int32, name string) {
+	f.setLayerProperties(index, "Rename", true, func(p *LayerProperties) {
+		p.Name = name
+	})
+}
+
+// DrawPixelDataToCanvas redraws the canvas using the pixel data
+// This is useful for removing pixels since DrawPixel is additive, meaning that
+// a pixel can never be erased
+func (f *File) DrawPixelDataToCanvas() {
+	if Headless {
+		return
+	}
+	layer := f.GetCurrentLayer()
+	rl.BeginTextureMode(layer.Canvas)
+	rl.ClearBackground(rl.Blank)
+	for v, color := range layer.PixelData {
+		rl.DrawPixel(v.X, v.Y, color)
+	}
+	rl.EndTextureMode()
 }
 
 // FlipHorizontal flips the layer horizontally, or flips the selection if anything
 // is selected
 func (f *File) FlipHorizontal() {
-	latestHistory := HistoryPixel{make(map[IntVec2]PixelStateData), CurrentFile.CurrentLayer}
-
-	var sx, sy int32 = 0, 0
-	mx, my := f.CanvasWidth, f.CanvasHeight
-
+	// The selection is flipped by its transform so it can still be resized
 	if f.DoingSelection {
-		sx = f.SelectionBounds[0]
-		sy = f.SelectionBounds[1]
-		mx = (f.SelectionBounds[0] + f.SelectionBounds[2]) + 1
-		my = f.SelectionBounds[3] + 1
-	} else {
-		// If selection is modified, it will be added to history on commit
-		CurrentFile.AppendHistory(latestHistory)
+		f.FlipSelectionTransform(true)
+		return
 	}
 
+	latestHistory := HistoryPixel{PixelState: make(map[IntVec2]PixelStateData), LayerIndex: CurrentFile.CurrentLayer, Name: "Flip horizontal"}
+	CurrentFile.AppendHistory(latestHistory)
+
 	// Swap the pixels over
 	cl := f.GetCurrentLayer()
-
-	for y := sy; y < my; y++ {
-		for x := sx; x < mx/2; x++ {
+	mx := f.CanvasWidth
+	for y := int32(0); y < f.CanvasHeight; y++ {
+		for x := int32(0); x < mx/2; x++ {
 			lpos := IntVec2{x, y}
 			rpos := IntVec2{mx - x - 1, y}
 
 			lcur := cl.PixelData[lpos]
 			rcur := cl.PixelData[rpos]
 
-			// Update selection
-			if f.DoingSelection {
-				f.Selection[lpos], f.Selection[rpos] = f.Selection[rpos], f.Selection[lpos]
-			} else {
-				l := latestHistory.PixelState[lpos]
-				l.Prev = lcur
-				l.Current = rcur
-				latestHistory.PixelState[lpos] = l
-
-				r := latestHistory.PixelState[rpos]
-				r.Prev = rcur
-				r.Current = lcur
-				latestHistory.PixelState[rpos] = r
-
-				cl.PixelData[lpos] = rcur
-				cl.PixelData[rpos] = lcur
-			}
+			l := latestHistory.PixelState[lpos]
+			l.Prev = lcur
+			l.Current = rcur
+			latestHistory.PixelState[lpos] = l
 
-		}
-	}
+			r := latestHistory.PixelState[rpos]
+			r.Prev = rcur
+			r.Current = lcur
+			latestHistory.PixelState[rpos] = r
 
-	if f.DoingSelection && !f.SelectionMoving {
-		// Allow CommitSelection to detect a change
-		f.MoveSelection(0, 0)
+			cl.PixelData[lpos] = rcur
+			cl.PixelData[rpos] = lcur
+		}
 	}
 
 	cl.Redraw()
@@ -963,176 +1466,51 @@ func (f *File) FlipHorizontal() {
 // FlipVertical flips the layer vertically, or flips the selection if anything
 // is selected
 func (f *File) FlipVertical() {
-	latestHistory := HistoryPixel{make(map[IntVec2]PixelStateData), CurrentFile.CurrentLayer}
-
-	var sx, sy int32 = 0, 0
-	mx, my := f.CanvasWidth, f.CanvasHeight
-
+	// The selection is flipped by its transform so it can still be resized
 	if f.DoingSelection {
-		sx = f.SelectionBounds[0]
-		sy = f.SelectionBounds[1]
-		mx = f.SelectionBounds[2] + 1
-		my = (f.SelectionBounds[1] + f.SelectionBounds[3]) + 1
-	} else {
-		// If selection is modified, it will be added to history on commit
-		CurrentFile.AppendHistory(latestHistory)
+		f.FlipSelectionTransform(false)
+		return
 	}
 
+	latestHistory := HistoryPixel{PixelState: make(map[IntVec2]PixelStateData), LayerIndex: CurrentFile.CurrentLayer, Name: "Flip vertical"}
+	CurrentFile.AppendHistory(latestHistory)
+
 	// Swap the pixels over
 	cl := f.GetCurrentLayer()
-	for x := sx; x < mx; x++ {
-		for y := sy; y < my/2; y++ {
+	my := f.CanvasHeight
+	for x := int32(0); x < f.CanvasWidth; x++ {
+		for y := int32(0); y < my/2; y++ {
 			lpos := IntVec2{x, y}
 			rpos := IntVec2{x, my - y - 1}
 
 			lcur := cl.PixelData[lpos]
 			rcur := cl.PixelData[rpos]
 
-			// Update selection
-			if f.DoingSelection {
-				f.Selection[lpos], f.Selection[rpos] = f.Selection[rpos], f.Selection[lpos]
-			} else {
-				l := latestHistory.PixelState[lpos]
-				l.Prev = lcur
-				l.Current = rcur
-				latestHistory.PixelState[lpos] = l
-
-				r := latestHistory.PixelState[rpos]
-				r.Prev = rcur
-				r.Current = lcur
-				latestHistory.PixelState[rpos] = r
-
-				cl.PixelData[lpos] = rcur
-				cl.PixelData[rpos] = lcur
-			}
+			l := latestHistory.PixelState[lpos]
+			l.Prev = lcur
+			l.Current = rcur
+			latestHistory.PixelState[lpos] = l
 
-		}
-	}
+			r := latestHistory.PixelState[rpos]
+			r.Prev = rcur
+			r.Current = lcur
+			latestHistory.PixelState[rpos] = r
 
-	if f.DoingSelection && !f.SelectionMoving {
-		// Allow CommitSelection to detect a change
-		f.MoveSelection(0, 0)
+			cl.PixelData[lpos] = rcur
+			cl.PixelData[rpos] = lcur
+		}
 	}
 
 	cl.Redraw()
 	f.RedrawRenderLayer()
 }
 
-// Undo undoes an action
-func (f *File) Undo() {
-	if f.historyOffset < int32(len(f.History)) {
-		f.historyOffset++
-		index := int32(len(f.History)) - f.historyOffset
-		history := f.History[index]
-
-		var process func(historyItem interface{})
-		process = func(historyItem interface{}) {
-			switch typed := historyItem.(type) {
-			case CompoundHistory:
-				for i := 0; i < len(typed.Actions); i++ {
-					process(typed.Actions[i])
-				}
-			case HistoryPixel:
-				if f.DoingSelection {
-					f.Selection = make(map[IntVec2]rl.Color)
-					f.DoingSelection = false
-					f.SelectionMoving = false
-				}
-				current := f.CurrentLayer
-				f.SetCurrentLayer(typed.LayerIndex)
-				layer := f.GetCurrentLayer()
-				for pos, psd := range typed.PixelState {
-					layer.PixelData[pos] = psd.Prev
-				}
-				layer.Redraw()
-				f.SetCurrentLayer(current)
-			case HistoryLayer:
-				switch typed.HistoryLayerAction {
-				case HistoryLayerActionDelete:
-					f.RestoreLayer(typed.LayerIndex)
-				case HistoryLayerActionCreate:
-					f.DeleteLayer(typed.LayerIndex, false)
-				case HistoryLayerActionMoveUp:
-					f.MoveLayerUp(typed.LayerIndex, false)
-				case HistoryLayerActionMoveDown:
-					f.MoveLayerDown(typed.LayerIndex, false)
-				}
-			case HistoryResize:
-				f.CanvasWidthResizePreview = typed.PrevWidth
-				f.CanvasHeightResizePreview = typed.PrevHeight
-				f.CanvasWidth = typed.PrevWidth
-				f.CanvasHeight = typed.PrevHeight
-				for i, layer := range typed.PrevLayerState {
-					f.Layers[i].PixelData = layer
-					f.Layers[i].Resize(typed.PrevWidth, typed.PrevHeight, ResizeTL)
-				}
-			}
-		}
-
-		process(history)
-
-		LayersUIRebuildList()
-		f.RedrawRenderLayer()
-	}
-}
-
-// Redo redoes an action
-func (f *File) Redo() {
-	if f.historyOffset > 0 {
-		index := int32(len(f.History)) - f.historyOffset
-		f.historyOffset--
-		history := f.History[index]
-
-		var process func(historyItem interface{})
-		process = func(historyItem interface{}) {
-			switch typed := historyItem.(type) {
-			case CompoundHistory:
-				for i := len(typed.Actions) - 1; i >= 0; i-- {
-					process(typed.Actions[i])
-				}
-			case HistoryPixel:
-				current := f.CurrentLayer
-				f.SetCurrentLayer(typed.LayerIndex)
-				layer := f.GetCurrentLayer()
-				for pos, psd := range typed.PixelState {
-					layer.PixelData[pos] = psd.Current
-				}
-				layer.Redraw()
-				f.SetCurrentLayer(current)
-			case HistoryLayer:
-				switch typed.HistoryLayerAction {
-				case HistoryLayerActionDelete:
-					f.DeleteLayer(typed.LayerIndex, false)
-				case HistoryLayerActionCreate:
-					f.RestoreLayer(typed.LayerIndex)
-				case HistoryLayerActionMoveUp:
-					f.MoveLayerUp(typed.LayerIndex, false)
-				case HistoryLayerActionMoveDown:
-					f.MoveLayerDown(typed.LayerIndex, false)
-				}
-			case HistoryResize:
-				f.CanvasWidthResizePreview = typed.CurrentWidth
-				f.CanvasHeightResizePreview = typed.CurrentHeight
-				f.CanvasWidth = typed.CurrentWidth
-				f.CanvasHeight = typed.CurrentHeight
-				for i, layer := range typed.CurrentLayerState {
-					f.Layers[i].PixelData = layer
-					f.Layers[i].Resize(typed.CurrentWidth, typed.CurrentHeight, ResizeTL)
-				}
-			}
-		}
-
-		process(history)
-
-		LayersUIRebuildList()
-		f.RedrawRenderLayer()
-	}
-}
-
 // Destroy unloads each layer's canvas
 func (f *File) Destroy() {
 	for _, layer := range f.Layers {
-		rl.UnloadRenderTexture(layer.Canvas)
+		if !Headless {
+			rl.UnloadRenderTexture(layer.Canvas)
+		}
 	}
 
 	for i, file := range Files {
@@ -1143,200 +1521,274 @@ func (f *File) Destroy() {
 	}
 }
 
-// SaveAs saves the file differently depending on the extension
-func (f *File) SaveAs(path string) {
-	file, err := os.Create(path)
-	if err != nil {
-		log.Fatal(err)
+// SaveAs saves the file as a .pix, which becomes where the file is saved to.
+// Other formats are written with Export
+func (f *File) SaveAs(path string) error {
+	if filepath.Ext(path) == "" {
+		path += ".pix"
+	}
+	if err := f.writeFile(path); err != nil {
+		return err
 	}
-	defer file.Close()
+	f.RemoveAutosave()
 
-	ext := filepath.Ext(path)
-	switch ext {
-	case ".png":
-		// Create a colored image of the given width and height.
-		img := image.NewNRGBA(image.Rect(0, 0, int(f.CanvasWidth), int(f.CanvasHeight)))
-
-		for x := int32(0); x < f.CanvasWidth; x++ {
-			for y := int32(0); y < f.CanvasHeight; y++ {
-				col := rl.Blank
-				loc := IntVec2{x, y}
-				for _, layer := range f.Layers[:len(f.Layers)-1] {
-					if !layer.Hidden {
-						if layerColor, ok := layer.PixelData[loc]; ok {
-							col = BlendWithOpacity(col, layerColor, layer.BlendMode)
-						}
-					}
-				}
+	// Change name in the tab
+	spl := strings.Split(path, "/")
+	f.Filename = spl[len(spl)-1]
+	f.PathDir = strings.Join(spl[:len(spl)-1], "/")
+	f.FileDir = path
+	log.Println(f.Filename, f.PathDir, f.FileDir)
+	f.FileChanged = false
+	f.savedPosition = f.HistoryPosition()
+	EditorsUIRebuild()
+	return nil
+}
 
-				img.Set(int(x), int(y), color.NRGBA{
-					col.R,
-					col.G,
-					col.B,
-					col.A,
-				})
-			}
-		}
+// writeFile writes the file to path as a .pix
+func (f *File) writeFile(path string) error {
+	if ext := filepath.Ext(path); ext != ".pix" {
+		return fmt.Errorf("Can't save as \"%s\", use export instead", ext)
+	}
+	return writeFileAtomic(path, f.encodePix)
+}
 
-		file, err := os.Create(path)
-		if err != nil {
-			log.Fatal(err)
-		}
+// writeFileAtomic writes to a temporary file next to path and then renames it
+// over path, so a failed write never leaves a partly written file behind
+func writeFileAtomic(path string, encode func(w io.Writer) error) error {
+	mode := os.FileMode(0644)
+	if fi, err := os.Stat(path); err == nil {
+		mode = fi.Mode().Perm()
+	}
 
-		if err := png.Encode(file, img); err != nil {
-			file.Close()
-			log.Fatal(err)
-		}
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
 
-		if err := file.Close(); err != nil {
-			log.Fatal(err)
-		}
+	if err := encode(tmp); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), mode); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
 
-	case ".pix":
-		enc := gob.NewEncoder(file)
-
-		gob.Register(rl.Color{})
-		gob.Register(IntVec2{})
-
-		fSer := &FileSer{
-			DrawGrid:     f.DrawGrid,
-			CanvasWidth:  f.CanvasWidth,
-			CanvasHeight: f.CanvasHeight,
-			TileWidth:    f.TileWidth,
-			TileHeight:   f.TileHeight,
-			Layers:       make([]*LayerSer, len(f.Layers)),
-			Animations:   make([]*AnimationSer, len(f.Animations)),
-		}
-		for l := range f.Layers {
-			fSer.Layers[l] = &LayerSer{
-				Name:      f.Layers[l].Name,
-				Hidden:    f.Layers[l].Hidden,
-				PixelData: f.Layers[l].PixelData,
-				Width:     f.Layers[l].Width,
-				Height:    f.Layers[l].Height,
-			}
-		}
-		for a := range f.Animations {
-			fSer.Animations[a] = &AnimationSer{
-				Name:       f.Animations[a].Name,
-				FrameStart: f.Animations[a].FrameStart,
-				FrameEnd:   f.Animations[a].FrameEnd,
-				Timing:     f.Animations[a].Timing,
-			}
-		}
+// encodePNG writes the composited layers as a png
+func (f *File) encodePNG(w io.Writer) error {
+	// Create a colored image of the given width and height.
+	img := image.NewNRGBA(image.Rect(0, 0, int(f.CanvasWidth), int(f.CanvasHeight)))
+	children := f.layerChildren()
 
-		if err := enc.Encode(fSer); err != nil {
-			log.Println(err)
+	for x := int32(0); x < f.CanvasWidth; x++ {
+		for y := int32(0); y < f.CanvasHeight; y++ {
+			col := compositePixel(children, nil, IntVec2{x, y})
+
+			img.Set(int(x), int(y), color.NRGBA{
+				col.R,
+				col.G,
+				col.B,
+				col.A,
+			})
 		}
+	}
 
-	default:
-		log.Printf("Can't save: extension \"%s\" not supported\n", ext)
-		return
+	return png.Encode(w, img)
+}
+
+// encodePix writes the file in the .pix format
+func (f *File) encodePix(w io.Writer) error {
+	enc := gob.NewEncoder(w)
+
+	gob.Register(rl.Color{})
+	gob.Register(IntVec2{})
+
+	fSer := &FileSer{
+		DrawGrid:       f.DrawGrid,
+		CanvasWidth:    f.CanvasWidth,
+		CanvasHeight:   f.CanvasHeight,
+		TileWidth:      f.TileWidth,
+		TileHeight:     f.TileHeight,
+		Layers:         make([]*LayerSer, len(f.Layers)),
+		Animations:     make([]*AnimationSer, len(f.Animations)),
+		ExportSettings: f.ExportSettings,
+		Guides:         f.Guides,
+		ReferenceGrid:  f.ReferenceGrid,
+		SelectionMasks: f.SelectionMasks,
+	}
+	for l := range f.Layers {
+		fSer.Layers[l] = &LayerSer{
+			Name:          f.Layers[l].Name,
+			Hidden:        f.Layers[l].Hidden,
+			PixelData:     f.Layers[l].PixelData,
+			Width:         f.Layers[l].Width,
+			Height:        f.Layers[l].Height,
+			Parent:        f.LayerIndex(f.Layers[l].Parent) + 1,
+			IsGroup:       f.Layers[l].IsGroup,
+			Collapsed:     f.Layers[l].Collapsed,
+			Clipping:      f.Layers[l].Clipping,
+			IsMask:        f.Layers[l].IsMask,
+			BlendMode:     f.Layers[l].BlendMode,
+			Effects:       f.Layers[l].Effects,
+			EffectsHidden: f.Layers[l].EffectsHidden,
+		}
+	}
+	for a := range f.Animations {
+		fSer.Animations[a] = &AnimationSer{
+			Name:       f.Animations[a].Name,
+			FrameStart: f.Animations[a].FrameStart,
+			FrameEnd:   f.Animations[a].FrameEnd,
+			Timing:     f.Animations[a].Timing,
+		}
 	}
 
-	// Change name in the tab
-	spl := strings.Split(path, "/")
-	f.Filename = spl[len(spl)-1]
-	f.PathDir = strings.Join(spl[:len(spl)-1], "/")
-	f.FileDir = path
-	log.Println(f.Filename, f.PathDir, f.FileDir)
-	f.FileChanged = false
-	EditorsUIRebuild()
+	return enc.Encode(fSer)
 }
 
 // Open a file
-func Open(openPath string) *File {
+func Open(openPath string) (*File, error) {
 	var f *File
 
 	fi, err := os.Stat(openPath)
 	if err != nil {
-		log.Println(err)
+		return nil, err
+	}
+	if !fi.Mode().IsRegular() {
+		return nil, fmt.Errorf("Can't open %s: not a file", openPath)
 	}
-	if fi.Mode().IsRegular() {
 
-		switch filepath.Ext(openPath) {
-		case ".pix":
-			reader, err := os.Open(openPath)
-			if err != nil {
-				log.Fatal(err)
-			}
-			defer reader.Close()
-			dec := gob.NewDecoder(reader)
-			fileSer := &FileSer{}
-			if err := dec.Decode(&fileSer); err != nil {
-				log.Println(err)
-			}
+	switch ext := filepath.Ext(openPath); ext {
+	case ".pix":
+		reader, err := os.Open(openPath)
+		if err != nil {
+			return nil, err
+		}
+		defer reader.Close()
+		dec := gob.NewDecoder(reader)
+		fileSer := &FileSer{}
+		if err := dec.Decode(&fileSer); err != nil {
+			return nil, fmt.Errorf("Can't open %s: %w", openPath, err)
+		}
+		if len(fileSer.Layers) < 2 {
+			return nil, fmt.Errorf("Can't open %s: file has no layers", openPath)
+		}
+		if err := checkLayerParents(fileSer.Layers); err != nil {
+			return nil, fmt.Errorf("Can't open %s: %w", openPath, err)
+		}
 
-			f = NewFile(fileSer.CanvasWidth, fileSer.CanvasHeight, fileSer.TileWidth, fileSer.TileHeight)
-			f.PathDir = path.Dir(openPath)
-			f.FileDir = openPath
-			f.DrawGrid = fileSer.DrawGrid
-
-			f.Layers = make([]*Layer, len(fileSer.Layers))
-			for i, layer := range fileSer.Layers {
-				f.Layers[i] = &Layer{
-					Name:      layer.Name,
-					Hidden:    layer.Hidden,
-					PixelData: layer.PixelData,
-					Width:     layer.Width,
-					Height:    layer.Height,
-					Canvas:    rl.LoadRenderTexture(layer.Width, layer.Height),
-				}
-				f.Layers[i].Redraw()
+		f = NewFile(fileSer.CanvasWidth, fileSer.CanvasHeight, fileSer.TileWidth, fileSer.TileHeight)
+		f.PathDir = path.Dir(openPath)
+		f.FileDir = openPath
+		f.DrawGrid = fileSer.DrawGrid
+		f.ExportSettings = fileSer.ExportSettings
+		f.Guides = fileSer.Guides
+		f.SelectionMasks = fileSer.SelectionMasks
+		// Files saved before there was a reference grid keep the default
+		if fileSer.ReferenceGrid.Width > 0 {
+			f.ReferenceGrid = fileSer.ReferenceGrid
+		}
+
+		f.Layers = make([]*Layer, len(fileSer.Layers))
+		for i, layer := range fileSer.Layers {
+			f.Layers[i] = &Layer{
+				Name:          layer.Name,
+				Hidden:        layer.Hidden,
+				PixelData:     layer.PixelData,
+				Width:         layer.Width,
+				Height:        layer.Height,
+				Canvas:        rl.LoadRenderTexture(layer.Width, layer.Height),
+				IsGroup:       layer.IsGroup,
+				Collapsed:     layer.Collapsed,
+				Clipping:      layer.Clipping,
+				IsMask:        layer.IsMask,
+				BlendMode:     layer.BlendMode,
+				Effects:       layer.Effects,
+				EffectsHidden: layer.EffectsHidden,
 			}
-			f.RenderLayer = NewLayer(f.CanvasWidth, f.CanvasHeight, "render", rl.Blank, true)
-			f.Animations = make([]*Animation, len(fileSer.Animations))
-			for i, animation := range fileSer.Animations {
-				f.Animations[i] = &Animation{
-					Name:       animation.Name,
-					FrameStart: animation.FrameStart,
-					FrameEnd:   animation.FrameEnd,
-					Timing:     animation.Timing,
-				}
+			f.Layers[i].Redraw()
+		}
+		for i, layer := range fileSer.Layers {
+			if layer.Parent > 0 {
+				f.Layers[i].Parent = f.Layers[layer.Parent-1]
+			}
+		}
+		f.RenderLayer = NewLayer(f.CanvasWidth, f.CanvasHeight, "render", rl.Blank, true)
+		f.Animations = make([]*Animation, len(fileSer.Animations))
+		for i, animation := range fileSer.Animations {
+			f.Animations[i] = &Animation{
+				Name:       animation.Name,
+				FrameStart: animation.FrameStart,
+				FrameEnd:   animation.FrameEnd,
+				Timing:     animation.Timing,
 			}
+		}
 
-			spl := strings.Split(openPath, "/")
-			f.Filename = spl[len(spl)-1]
+		spl := strings.Split(openPath, "/")
+		f.Filename = spl[len(spl)-1]
 
-			CurrentFile = f
+		CurrentFile = f
 
-			AnimationsUIRebuildList()
-			LayersUIRebuildList()
+		AnimationsUIRebuildList()
+		LayersUIRebuildList()
 
-		case ".png":
-			tex := rl.LoadTexture(openPath)
-			pixelColors := rl.LoadImageColors(rl.LoadImageFromTexture(tex))
+	case ".png":
+		tex := rl.LoadTexture(openPath)
+		if tex.ID == 0 {
+			return nil, fmt.Errorf("Can't open %s: couldn't load image", openPath)
+		}
+		pixelColors := rl.LoadImageColors(rl.LoadImageFromTexture(tex))
 
-			f = NewFile(tex.Width, tex.Height, 8, 8)
-			f.PathDir = path.Dir(openPath)
-			f.FileDir = openPath
+		f = NewFile(tex.Width, tex.Height, 8, 8)
+		f.PathDir = path.Dir(openPath)
+		// The png is only exported to, it isn't where the document is saved
+		f.ExportSettings.Path = openPath
 
-			editedLayer := NewLayer(f.CanvasWidth, f.CanvasHeight, "background", rl.Blank, false)
+		editedLayer := NewLayer(f.CanvasWidth, f.CanvasHeight, "background", rl.Blank, false)
 
-			rl.BeginTextureMode(editedLayer.Canvas)
-			for y := int32(0); y < f.CanvasHeight; y++ {
-				for x := int32(0); x < f.CanvasWidth; x++ {
-					color := pixelColors[x+y*f.CanvasWidth]
-					editedLayer.PixelData[IntVec2{x, y}] = color
-				}
+		rl.BeginTextureMode(editedLayer.Canvas)
+		for y := int32(0); y < f.CanvasHeight; y++ {
+			for x := int32(0); x < f.CanvasWidth; x++ {
+				color := pixelColors[x+y*f.CanvasWidth]
+				editedLayer.PixelData[IntVec2{x, y}] = color
 			}
-			f.RenderLayer = NewLayer(f.CanvasWidth, f.CanvasHeight, "render", rl.Blank, true)
-			rl.EndTextureMode()
-			editedLayer.Redraw()
+		}
+		f.RenderLayer = NewLayer(f.CanvasWidth, f.CanvasHeight, "render", rl.Blank, true)
+		rl.EndTextureMode()
+		editedLayer.Redraw()
 
-			f.Layers = []*Layer{
-				editedLayer,
-				NewLayer(f.CanvasWidth, f.CanvasHeight, "hidden", rl.Blank, true),
-			}
+		f.Layers = []*Layer{
+			editedLayer,
+			NewLayer(f.CanvasWidth, f.CanvasHeight, "hidden", rl.Blank, true),
+		}
 
-			spl := strings.Split(openPath, "/")
-			f.Filename = spl[len(spl)-1]
+		spl := strings.Split(openPath, "/")
+		f.Filename = spl[len(spl)-1]
+	case ".ora":
+		if f, err = openORA(openPath); err != nil {
+			return nil, err
 		}
+		// Like pngs, ora files are exported to rather than saved
+		f.ExportSettings.Path = openPath
+	case ".psd":
+		if f, err = openPSD(openPath); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("Can't open: extension \"%s\" not supported", ext)
 	}
 
 	CurrentFile = f
 	f.RedrawRenderLayer()
 	EditorsUIRebuild()
 
-	return f
+	return f, nil
 }
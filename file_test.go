@@ -0,0 +1,116 @@
+package main
+
+import (
+	"testing"
+
+	rl "github.com/gen2brain/raylib-go/raylib"
+)
+
+// TestLayerMaskBlock checks that a mask stays directly above its layer when
+// the layer is moved, deleted or merged
+func TestLayerMaskBlock(t *testing.T) {
+	f := newTestFile(t)
+	f.AddNewLayer()
+	f.AddNewLayer()
+	layer := f.Layers[1]
+	f.AddLayerMask(1)
+	mask := f.Layers[2]
+
+	if err := f.MoveLayerUp(1, true); err != nil {
+		t.Fatal(err)
+	}
+	if f.Layers[2] != layer || f.Layers[3] != mask {
+		t.Fatal("The mask didn't move up with its layer")
+	}
+	if err := f.MoveLayerDown(3, true); err != nil {
+		t.Fatal(err)
+	}
+	if f.Layers[1] != layer || f.Layers[2] != mask {
+		t.Fatal("The mask didn't move down with its layer")
+	}
+
+	f.DeleteLayer(1, true)
+	if f.LayerIndex(layer) >= 0 || f.LayerIndex(mask) >= 0 {
+		t.Fatal("The mask wasn't deleted with its layer")
+	}
+	f.Undo()
+	if f.Layers[1] != layer || f.Layers[2] != mask {
+		t.Fatal("Undoing didn't restore the layer and its mask")
+	}
+
+	loc := IntVec2{0, 0}
+	layer.PixelData[loc] = rl.White
+	mask.PixelData[loc] = rl.Black
+	if err := f.MergeLayerDown(1); err != nil {
+		t.Fatal(err)
+	}
+	if f.LayerIndex(mask) >= 0 {
+		t.Fatal("The mask wasn't removed by merging")
+	}
+	if got, want := f.Layers[0].PixelData[loc], rl.NewColor(0, 0, 128, 255); got != want {
+		t.Fatalf("The merged pixel is %v, want %v hidden by the mask", got, want)
+	}
+}
+
+// TestCheckLayerParents checks that files with groups which could loop, or
+// aren't directly above their layers, can't be opened
+func TestCheckLayerParents(t *testing.T) {
+	layer := func(parent int32, isGroup bool) *LayerSer {
+		return &LayerSer{Parent: parent, IsGroup: isGroup}
+	}
+	preview := layer(0, false)
+	for _, test := range []struct {
+		name   string
+		layers []*LayerSer
+		valid  bool
+	}{
+		{"nested groups", []*LayerSer{layer(2, false), layer(3, true), layer(0, true), layer(0, false), preview}, true},
+		{"own group", []*LayerSer{layer(1, true), preview}, false},
+		{"loop", []*LayerSer{layer(2, true), layer(1, true), preview}, false},
+		{"group below", []*LayerSer{layer(0, true), layer(1, false), preview}, false},
+		{"not a group", []*LayerSer{layer(2, false), layer(0, false), preview}, false},
+		{"preview group", []*LayerSer{layer(2, false), layer(0, true)}, false},
+		{"layer between", []*LayerSer{layer(3, false), layer(0, false), layer(0, true), preview}, false},
+	} {
+		if err := checkLayerParents(test.layers); (err == nil) != test.valid {
+			t.Errorf("%s: got %v, want valid %t", test.name, err, test.valid)
+		}
+	}
+}
+
+// TestDuplicateLayerMask checks that a duplicated or copied layer keeps its
+// mask, with the copy above the original's mask
+func TestDuplicateLayerMask(t *testing.T) {
+	f := newTestFile(t)
+	f.AddNewLayer()
+	f.AddLayerMask(1)
+	mask := f.Layers[2]
+	mask.PixelData[IntVec2{0, 0}] = rl.Black
+
+	f.DuplicateLayer(2)
+	if len(f.Layers) != 6 || f.Layers[2] != mask {
+		t.Fatal("The copy wasn't put above the mask")
+	}
+	if f.CurrentLayer != 3 || !f.isLayerMask(4) || f.Layers[4].PixelData[IntVec2{0, 0}] != rl.Black {
+		t.Fatal("The copy doesn't have a copy of the mask above it")
+	}
+
+	target := newTestFile(t)
+	f.CopyLayerToFile(1, target)
+	if len(target.Layers) != 4 || target.CurrentLayer != 1 || !target.isLayerMask(2) {
+		t.Fatal("The layer was copied to the other file without its mask")
+	}
+}
+
+// TestGroupLayerMask checks that grouping a mask groups its layer with it
+func TestGroupLayerMask(t *testing.T) {
+	f := newTestFile(t)
+	f.AddLayerMask(0)
+	layer, mask := f.Layers[0], f.Layers[1]
+
+	f.AddNewGroup(1)
+	group := f.Layers[2]
+	if !group.IsGroup || layer.Parent != group || mask.Parent != group || !f.isLayerMask(1) {
+		t.Fatal("The mask wasn't grouped together with its layer")
+	}
+}
@@ -0,0 +1,321 @@
+package main
+
+import (
+	"math"
+
+	rl "github.com/gen2brain/raylib-go/raylib"
+)
+
+// Filter changes the color of a single pixel. Filters only look at the one
+// color so they can be run over any PixelData
+type Filter func(color rl.Color) rl.Color
+
+// FilterPixels returns a copy of the pixels with the filter run over every
+// pixel for which inside returns true, or every pixel if inside is nil.
+// Transparent pixels are left alone
+func FilterPixels(pixels map[IntVec2]rl.Color, filter Filter, inside func(loc IntVec2) bool) map[IntVec2]rl.Color {
+	filtered := make(map[IntVec2]rl.Color, len(pixels))
+	for loc, color := range pixels {
+		if color.A > 0 && (inside == nil || inside(loc)) {
+			color = filter(color)
+		}
+		filtered[loc] = color
+	}
+	return filtered
+}
+
+// HueSaturationLightness rotates the hue by degrees and scales the
+// saturation. Lightness goes from -1 (black) through 0 (unchanged) to 1
+// (white)
+func HueSaturationLightness(hue, saturation, lightness float64) Filter {
+	return func(color rl.Color) rl.Color {
+		h, s, l := ColorToHSL(color)
+		if lightness < 0 {
+			l *= 1 + lightness
+		} else {
+			l += (1 - l) * lightness
+		}
+		return HSLToColor(h+hue, s*saturation, l, color.A)
+	}
+}
+
+// BrightnessContrast adds brightness (-1 to 1) to each channel, then scales
+// the channels away from or towards the middle by contrast
+func BrightnessContrast(brightness, contrast float64) Filter {
+	channel := func(c uint8) uint8 {
+		v := float64(c)/255 + brightness
+		return floatToUint8((v-0.5)*contrast + 0.5)
+	}
+	return func(color rl.Color) rl.Color {
+		return rl.NewColor(channel(color.R), channel(color.G), channel(color.B), color.A)
+	}
+}
+
+// Invert inverts the red, green and blue channels
+func Invert() Filter {
+	return func(color rl.Color) rl.Color {
+		return rl.NewColor(255-color.R, 255-color.G, 255-color.B, color.A)
+	}
+}
+
+// Desaturate turns colors gray, keeping how light they look
+func Desaturate() Filter {
+	return func(color rl.Color) rl.Color {
+		l, _, h := ColorToOKLCH(color)
+		return OKLCHToColor(l, 0, h, color.A)
+	}
+}
+
+// Posterize rounds each channel to one of levels evenly spaced values
+func Posterize(levels int32) Filter {
+	steps := float64(MaxInt32(levels, 2) - 1)
+	channel := func(c uint8) uint8 {
+		return floatToUint8(math.Round(float64(c)/255*steps) / steps)
+	}
+	return func(color rl.Color) rl.Color {
+		return rl.NewColor(channel(color.R), channel(color.G), channel(color.B), color.A)
+	}
+}
+
+// ColorBalance shifts the red, green and blue channels (-1 to 1). The
+// midtones are shifted the most so that black and white stay as they are
+func ColorBalance(red, green, blue float64) Filter {
+	return func(color rl.Color) rl.Color {
+		_, _, l := ColorToHSL(color)
+		weight := 4 * l * (1 - l)
+		channel := func(c uint8, shift float64) uint8 {
+			return floatToUint8(float64(c)/255 + shift*weight)
+		}
+		return rl.NewColor(channel(color.R, red), channel(color.G, green), channel(color.B, blue), color.A)
+	}
+}
+
+// ReplaceColor replaces colors which are within tolerance of from on every
+// channel with to
+func ReplaceColor(from, to rl.Color, tolerance uint8) Filter {
+	near := func(a, b uint8) bool {
+		return MaxUint8(a, b)-MinUint8(a, b) <= tolerance
+	}
+	return func(color rl.Color) rl.Color {
+		if near(color.R, from.R) && near(color.G, from.G) && near(color.B, from.B) && near(color.A, from.A) {
+			return to
+		}
+		return color
+	}
+}
+
+// FilterKind is one of the filters which can be picked in the editor
+type FilterKind int32
+
+// Filter kinds
+const (
+	FilterHueSaturationLightness FilterKind = iota
+	FilterBrightnessContrast
+	FilterInvert
+	FilterDesaturate
+	FilterPosterize
+	FilterColorBalance
+	FilterReplaceColor
+)
+
+func (k FilterKind) String() string {
+	switch k {
+	case FilterHueSaturationLightness:
+		return "hue/saturation"
+	case FilterBrightnessContrast:
+		return "brightness/contrast"
+	case FilterInvert:
+		return "invert"
+	case FilterDesaturate:
+		return "desaturate"
+	case FilterPosterize:
+		return "posterize"
+	case FilterColorBalance:
+		return "color balance"
+	case FilterReplaceColor:
+		return "replace color"
+	}
+	return "unknown"
+}
+
+// Labels returns the names of the values the filter uses
+func (k FilterKind) Labels() []string {
+	switch k {
+	case FilterHueSaturationLightness:
+		return []string{"hue", "saturation %", "lightness %"}
+	case FilterBrightnessContrast:
+		return []string{"brightness %", "contrast %"}
+	case FilterPosterize:
+		return []string{"levels"}
+	case FilterColorBalance:
+		return []string{"red %", "green %", "blue %"}
+	case FilterReplaceColor:
+		return []string{"tolerance"}
+	}
+	return nil
+}
+
+// Defaults returns the values which leave the colors as they are, apart from
+// posterize which has to reduce them
+func (k FilterKind) Defaults() [3]int32 {
+	switch k {
+	case FilterHueSaturationLightness:
+		return [3]int32{0, 100, 100}
+	case FilterBrightnessContrast:
+		return [3]int32{100, 100}
+	case FilterPosterize:
+		return [3]int32{4}
+	case FilterColorBalance:
+		return [3]int32{100, 100, 100}
+	}
+	return [3]int32{}
+}
+
+// FilterParams are a filter and its values as they're typed in. Values which
+// are percentages leave the colors unchanged at 100
+type FilterParams struct {
+	Kind   FilterKind
+	Values [3]int32
+	// From and To are the colors for FilterReplaceColor
+	From, To rl.Color
+}
+
+// Filter returns the filter for the params
+func (p FilterParams) Filter() Filter {
+	percent := func(i int) float64 {
+		return float64(p.Values[i]-100) / 100
+	}
+	switch p.Kind {
+	case FilterHueSaturationLightness:
+		return HueSaturationLightness(float64(p.Values[0]), float64(p.Values[1])/100, percent(2))
+	case FilterBrightnessContrast:
+		return BrightnessContrast(percent(0), float64(p.Values[1])/100)
+	case FilterInvert:
+		return Invert()
+	case FilterDesaturate:
+		return Desaturate()
+	case FilterPosterize:
+		return Posterize(p.Values[0])
+	case FilterColorBalance:
+		return ColorBalance(percent(0), percent(1), percent(2))
+	case FilterReplaceColor:
+		return ReplaceColor(p.From, p.To, uint8(MinInt32(MaxInt32(p.Values[0], 0), 255)))
+	}
+	return func(color rl.Color) rl.Color { return color }
+}
+
+// FilterPreview is a filter shown over the current layer, or the selection
+// on it, before it's applied
+type FilterPreview struct {
+	Params  FilterParams
+	applied FilterParams
+	// history is the history position when the preview was made, so it can
+	// be remade after the layer changes
+	history int32
+	// pixels are the changed pixels as they'd be shown, with every layer
+	// blended
+	pixels map[IntVec2]rl.Color
+}
+
+// filterLayer returns the layer filters are run on, or nil if it has no
+// pixels of its own
+func (f *File) filterLayer() *Layer {
+	layer := f.GetCurrentLayer()
+	if layer.IsGroup {
+		return nil
+	}
+	return layer
+}
+
+// BeginFilterPreview starts showing the filter over the current layer
+func (f *File) BeginFilterPreview(params FilterParams) *FilterPreview {
+	f.PlaceSelection()
+	// -2 is never a history position, so the preview is always made
+	f.FilterPreview = &FilterPreview{Params: params, history: -2}
+	f.UpdateFilterPreview()
+	return f.FilterPreview
+}
+
+// EndFilterPreview stops showing the filter without applying it
+func (f *File) EndFilterPreview() {
+	f.FilterPreview = nil
+	rl.BeginTextureMode(f.Layers[len(f.Layers)-1].Canvas)
+	rl.ClearBackground(rl.Blank)
+	rl.EndTextureMode()
+}
+
+// UpdateFilterPreview remakes the preview if the filter or the layer has
+// changed since it was last made
+func (f *File) UpdateFilterPreview() {
+	p := f.FilterPreview
+	if p == nil || (p.Params == p.applied && p.history == f.HistoryPosition()) {
+		return
+	}
+	p.applied = p.Params
+	p.history = f.HistoryPosition()
+	p.pixels = make(map[IntVec2]rl.Color)
+
+	layer := f.filterLayer()
+	if layer == nil {
+		return
+	}
+	filtered := FilterPixels(layer.PixelData, p.Params.Filter(), f.InSelection)
+
+	// The composite is found with the filtered pixels swapped in
+	original := layer.PixelData
+	layer.PixelData = filtered
+	children := f.layerChildren()
+	for loc, color := range filtered {
+		if original[loc] != color {
+			p.pixels[loc] = compositePixel(children, nil, loc)
+		}
+	}
+	layer.PixelData = original
+}
+
+// DrawFilterPreview draws the filtered pixels over the preview layer, it's
+// called while drawing to the preview layer
+func (f *File) DrawFilterPreview() {
+	if f.FilterPreview == nil {
+		return
+	}
+	for loc, color := range f.FilterPreview.pixels {
+		rl.DrawPixel(loc.X, loc.Y, rl.Black)
+		rl.DrawPixel(loc.X, loc.Y, color)
+	}
+}
+
+// ApplyFilter runs the filter over the current layer, or the selection on
+// it, as a single undo
+func (f *File) ApplyFilter(name string, filter Filter) {
+	layer := f.filterLayer()
+	if layer == nil {
+		return
+	}
+	f.PlaceSelection()
+
+	history := HistoryPixel{PixelState: make(map[IntVec2]PixelStateData), LayerIndex: f.CurrentLayer, Name: name}
+	for loc, color := range FilterPixels(layer.PixelData, filter, f.InSelection) {
+		if prev := layer.PixelData[loc]; prev != color {
+			history.PixelState[loc] = PixelStateData{Prev: prev, Current: color}
+			layer.PixelData[loc] = color
+			f.syncSelectedPixel(loc, color)
+		}
+	}
+	if len(history.PixelState) == 0 {
+		return
+	}
+	f.AppendHistory(history)
+	layer.Redraw()
+	f.RedrawRenderLayer()
+}
+
+// ApplyFilterPreview applies the filter being previewed and stops showing it
+func (f *File) ApplyFilterPreview() {
+	if f.FilterPreview == nil {
+		return
+	}
+	params := f.FilterPreview.Params
+	f.EndFilterPreview()
+	f.ApplyFilter(params.Kind.String(), params.Filter())
+}
@@ -5,13 +5,13 @@ go 1.19
 require (
 	github.com/gen2brain/raylib-go/raylib v0.0.0-20230119163414-8344ddbee9ac
 	github.com/gotk3/gotk3 v0.6.1
+	github.com/ncruces/zenity v0.10.5
 )
 
 require (
 	github.com/akavel/rsrc v0.10.2 // indirect
 	github.com/dchest/jsmin v0.0.0-20220218165748-59f39799265f // indirect
 	github.com/josephspurrier/goversioninfo v1.4.0 // indirect
-	github.com/ncruces/zenity v0.10.5 // indirect
 	github.com/randall77/makefat v0.0.0-20210315173500-7ddd0e42c844 // indirect
 	golang.org/x/image v0.2.0 // indirect
 	golang.org/x/sys v0.3.0 // indirect
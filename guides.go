@@ -0,0 +1,275 @@
+package main
+
+import (
+	"math"
+
+	rl "github.com/gen2brain/raylib-go/raylib"
+)
+
+// Guide is a line across the canvas for lining things up
+type Guide struct {
+	Vertical bool
+	// Position is the pixel the guide is to the left of or above
+	Position int32
+}
+
+// ReferenceGrid is drawn on top of the tile grid to help with layouts
+type ReferenceGrid struct {
+	// Show draws lines Width and Height apart, starting at the offset
+	Show                            bool
+	Width, Height, OffsetX, OffsetY int32
+	// Isometric draws 2:1 diagonal lines making tiles IsoWidth wide
+	Isometric bool
+	IsoWidth  int32
+}
+
+var (
+	guideColor         = rl.NewColor(0, 200, 255, 200)
+	referenceGridColor = rl.NewColor(255, 0, 255, 96)
+	isometricGridColor = rl.NewColor(255, 200, 0, 96)
+
+	// guideGrabDistance is how close in screen pixels the cursor has to be
+	// to a guide to drag it
+	guideGrabDistance float32 = 3
+	// draggedGuide is the index of the guide being dragged, or -1
+	draggedGuide = -1
+	// guidesBeforeDrag is used for the history once the drag is done
+	guidesBeforeDrag []Guide
+)
+
+// NewReferenceGrid returns a grid at half the tile size with isometric tiles
+// as wide as a tile
+func NewReferenceGrid(tileWidth, tileHeight int32) ReferenceGrid {
+	return ReferenceGrid{
+		Width:    MaxInt32(tileWidth/2, 1),
+		Height:   MaxInt32(tileHeight/2, 1),
+		IsoWidth: MaxInt32(tileWidth, 2),
+	}
+}
+
+// SetGuides changes the guides, adding it to the history
+func (f *File) SetGuides(guides []Guide) {
+	f.AppendHistory(HistoryGuides{f.Guides, guides})
+	f.Guides = guides
+}
+
+// guideScreenPos returns where the guide is on the screen, x for vertical
+// guides and y for horizontal ones
+func (f *File) guideScreenPos(guide Guide) float32 {
+	origin := rl.GetWorldToScreen2D(
+		rl.NewVector2(-float32(f.CanvasWidth)/2, -float32(f.CanvasHeight)/2),
+		f.FileCamera)
+	if guide.Vertical {
+		return origin.X + float32(guide.Position)*f.FileCamera.Zoom
+	}
+	return origin.Y + float32(guide.Position)*f.FileCamera.Zoom
+}
+
+// guideAt returns the index of the guide under the screen position, or -1
+func (f *File) guideAt(screen rl.Vector2) int {
+	for i := len(f.Guides) - 1; i >= 0; i-- {
+		pos := screen.Y
+		if f.Guides[i].Vertical {
+			pos = screen.X
+		}
+		if math.Abs(float64(f.guideScreenPos(f.Guides[i])-pos)) <= float64(guideGrabDistance) {
+			return i
+		}
+	}
+	return -1
+}
+
+// onRulers returns which ruler the screen position is over, if any
+func onRulers(screen rl.Vector2) (top, left bool) {
+	if !Settings.ViewData.Rulers {
+		return false, false
+	}
+	area := ViewArea()
+	if !rl.CheckCollisionPointRec(screen, area) {
+		return false, false
+	}
+	return screen.Y < area.Y+rulerSize, screen.X < area.X+rulerSize
+}
+
+// UpdateGuideDrag creates guides dragged out of the rulers and moves guides
+// dragged with the selector, keeping them on the canvas. Guides dropped back
+// on the rulers are removed. It returns true while a guide is being dragged
+func (f *File) UpdateGuideDrag(screen, cursor rl.Vector2) bool {
+	if draggedGuide < 0 {
+		if !rl.IsMouseButtonPressed(rl.MouseLeftButton) {
+			return false
+		}
+		// Edited on a copy so that the history isn't changed
+		guidesBeforeDrag = f.Guides
+		f.Guides = append([]Guide{}, f.Guides...)
+		top, left := onRulers(screen)
+		index := -1
+		if top != left {
+			f.Guides = append(f.Guides, Guide{Vertical: left})
+			index = len(f.Guides) - 1
+		} else if _, ok := LeftTool.(*SelectorTool); ok {
+			index = f.guideAt(screen)
+		}
+		if index < 0 {
+			f.Guides = guidesBeforeDrag
+			return false
+		}
+		draggedGuide = index
+	}
+
+	guide := &f.Guides[draggedGuide]
+	position, size := cursor.Y, f.CanvasHeight
+	if guide.Vertical {
+		position, size = cursor.X, f.CanvasWidth
+	}
+	guide.Position = MinInt32(MaxInt32(int32(math.Round(float64(position))), 0), size)
+
+	if rl.IsMouseButtonDown(rl.MouseLeftButton) {
+		return true
+	}
+
+	// Dropped
+	top, left := onRulers(screen)
+	guides := f.Guides
+	if top || left {
+		guides = append(guides[:draggedGuide], guides[draggedGuide+1:]...)
+	}
+	f.Guides = guidesBeforeDrag
+	if !guidesEqual(guides, guidesBeforeDrag) {
+		f.SetGuides(guides)
+	}
+	draggedGuide = -1
+	return true
+}
+
+// guidesEqual returns true if both have the same guides in the same order
+func guidesEqual(a, b []Guide) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// ClearGuides removes all of the guides
+func (f *File) ClearGuides() {
+	if len(f.Guides) > 0 {
+		f.SetGuides(nil)
+	}
+}
+
+// DrawGuides draws the guides across the view area. It's drawn in screen
+// space
+func DrawGuides(f *File) {
+	area := ViewArea()
+	for _, guide := range f.Guides {
+		pos := f.guideScreenPos(guide)
+		if guide.Vertical {
+			rl.DrawLineV(rl.NewVector2(pos, area.Y), rl.NewVector2(pos, area.Y+area.Height), guideColor)
+		} else {
+			rl.DrawLineV(rl.NewVector2(area.X, pos), rl.NewVector2(area.X+area.Width, pos), guideColor)
+		}
+	}
+}
+
+// DrawReferenceGrid draws the reference grid and the isometric grid over the
+// canvas. It's drawn in world space
+func DrawReferenceGrid(f *File) {
+	grid := f.ReferenceGrid
+	left, top := -f.CanvasWidth/2, -f.CanvasHeight/2
+
+	if grid.Show && grid.Width > 0 && grid.Height > 0 {
+		for x := (grid.OffsetX%grid.Width + grid.Width) % grid.Width; x < f.CanvasWidth; x += grid.Width {
+			if x > 0 {
+				rl.DrawLine(left+x, top, left+x, top+f.CanvasHeight, referenceGridColor)
+			}
+		}
+		for y := (grid.OffsetY%grid.Height + grid.Height) % grid.Height; y < f.CanvasHeight; y += grid.Height {
+			if y > 0 {
+				rl.DrawLine(left, top+y, left+f.CanvasWidth, top+y, referenceGridColor)
+			}
+		}
+	}
+
+	// Lines going down and up to the right with a slope of 1/2, a tile height
+	// apart where they cross the left edge
+	if grid.Isometric && grid.IsoWidth >= 2 {
+		width, height := float64(f.CanvasWidth), float64(f.CanvasHeight)
+		spacing := float64(grid.IsoWidth) / 2
+		drawClipped := func(c, slope float64) {
+			// y = c + x*slope, clipped to the canvas
+			x0, x1 := 0.0, width
+			if slope > 0 {
+				x0, x1 = math.Max(x0, -c/slope), math.Min(x1, (height-c)/slope)
+			} else {
+				x0, x1 = math.Max(x0, (height-c)/slope), math.Min(x1, -c/slope)
+			}
+			if x0 >= x1 {
+				return
+			}
+			rl.DrawLineV(
+				rl.NewVector2(float32(left)+float32(x0), float32(top)+float32(c+x0*slope)),
+				rl.NewVector2(float32(left)+float32(x1), float32(top)+float32(c+x1*slope)),
+				isometricGridColor)
+		}
+		for c := -math.Ceil(width/2/spacing) * spacing; c <= height; c += spacing {
+			drawClipped(c, 0.5)
+		}
+		for c := 0.0; c <= height+width/2; c += spacing {
+			drawClipped(c, -0.5)
+		}
+	}
+}
+
+// snapLines returns the nearest line to v on each of the things which can be
+// snapped to. vertical is for lines going down, which x positions snap to
+func (f *File) snapLines(v int32, vertical bool) []int32 {
+	lines := make([]int32, 0, 3)
+	nearest := func(offset, size int32) int32 {
+		return int32(math.Round(float64(v-offset)/float64(size)))*size + offset
+	}
+
+	for _, guide := range f.Guides {
+		if guide.Vertical == vertical {
+			lines = append(lines, guide.Position)
+		}
+	}
+	tileSize, canvasSize := f.TileHeight, f.CanvasHeight
+	gridSize, gridOffset := f.ReferenceGrid.Height, f.ReferenceGrid.OffsetY
+	if vertical {
+		tileSize, canvasSize = f.TileWidth, f.CanvasWidth
+		gridSize, gridOffset = f.ReferenceGrid.Width, f.ReferenceGrid.OffsetX
+	}
+	if f.DrawGrid && tileSize > 0 {
+		lines = append(lines, MinInt32(MaxInt32(nearest(0, tileSize), 0), canvasSize))
+	}
+	if f.ReferenceGrid.Show && gridSize > 0 {
+		lines = append(lines, nearest(gridOffset, gridSize))
+	}
+	return lines
+}
+
+// SnapSpan returns start moved so that one of the edges of the span of pixels
+// is on the nearest guide or grid line, if it's within the snap distance.
+// Isometric lines aren't snapped to
+func (f *File) SnapSpan(start, length int32, vertical bool) int32 {
+	if !Settings.ViewData.Snap {
+		return start
+	}
+	best := start
+	bestDistance := Settings.ViewData.SnapDistance / f.FileCamera.Zoom
+	for _, edge := range []int32{start, start + length} {
+		for _, line := range f.snapLines(edge, vertical) {
+			distance := float32(math.Abs(float64(line - edge)))
+			if distance <= bestDistance {
+				best = start + line - edge
+				bestDistance = distance
+			}
+		}
+	}
+	return best
+}
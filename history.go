@@ -0,0 +1,891 @@
+package main
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"log"
+	"sort"
+
+	rl "github.com/gen2brain/raylib-go/raylib"
+)
+
+// HistoryAction is an action which can be undone and redone
+type HistoryAction interface {
+	// Apply does the action again after it has been reverted
+	Apply(f *File)
+	// Revert undoes the action
+	Revert(f *File)
+	// String is the name shown in the history panel
+	String() string
+	// Size is roughly how many bytes of memory the action uses
+	Size() int
+}
+
+// historyCompressor is implemented by actions which can be stored in a
+// smaller form once they can no longer be added to
+type historyCompressor interface {
+	compress() HistoryAction
+}
+
+// HistoryLayerAction specifies the action which has been called upon the layer
+type HistoryLayerAction int32
+
+// What HistoryLayer action has happened
+const (
+	HistoryLayerActionDelete HistoryLayerAction = iota
+	HistoryLayerActionCreate
+)
+
+// CompoundHistory is a group of history actions
+type CompoundHistory struct {
+	Name    string
+	Actions []HistoryAction
+}
+
+// Apply applies the actions from last to first
+func (h CompoundHistory) Apply(f *File) {
+	for i := len(h.Actions) - 1; i >= 0; i-- {
+		h.Actions[i].Apply(f)
+	}
+}
+
+// Revert reverts the actions from first to last
+func (h CompoundHistory) Revert(f *File) {
+	for _, action := range h.Actions {
+		action.Revert(f)
+	}
+}
+
+func (h CompoundHistory) String() string {
+	return h.Name
+}
+
+// Size returns the size of all of the actions
+func (h CompoundHistory) Size() int {
+	size := 0
+	for _, action := range h.Actions {
+		size += action.Size()
+	}
+	return size
+}
+
+func (h CompoundHistory) compress() HistoryAction {
+	for i, action := range h.Actions {
+		if c, ok := action.(historyCompressor); ok {
+			h.Actions[i] = c.compress()
+		}
+	}
+	return h
+}
+
+// HistoryLayer is for layer operations
+type HistoryLayer struct {
+	HistoryLayerAction
+	LayerIndex int32
+	// Layer is kept so it can be put back after being deleted
+	Layer *Layer
+}
+
+// Apply deletes or restores the layer again
+func (h HistoryLayer) Apply(f *File) {
+	switch h.HistoryLayerAction {
+	case HistoryLayerActionDelete:
+		f.DeleteLayer(h.LayerIndex, false)
+	case HistoryLayerActionCreate:
+		f.RestoreLayer(h.LayerIndex, h.Layer)
+	}
+}
+
+// Revert restores a deleted layer or deletes a created one
+func (h HistoryLayer) Revert(f *File) {
+	switch h.HistoryLayerAction {
+	case HistoryLayerActionDelete:
+		f.RestoreLayer(h.LayerIndex, h.Layer)
+	case HistoryLayerActionCreate:
+		f.DeleteLayer(h.LayerIndex, false)
+	}
+}
+
+func (h HistoryLayer) String() string {
+	switch h.HistoryLayerAction {
+	case HistoryLayerActionDelete:
+		return fmt.Sprintf("Delete layer %d", h.LayerIndex)
+	case HistoryLayerActionCreate:
+		return fmt.Sprintf("New layer %d", h.LayerIndex)
+	}
+	return "Layer"
+}
+
+// Size returns the size of the layer's pixels
+func (h HistoryLayer) Size() int {
+	return 16 + len(h.Layer.PixelData)*24
+}
+
+// LayerStructure is the order and nesting of a file's layers
+type LayerStructure struct {
+	Layers  []*Layer
+	Parents []*Layer
+}
+
+// layerIndex returns the index of the layer in the structure, or -1
+func (s LayerStructure) layerIndex(layer *Layer) int32 {
+	for i, l := range s.Layers {
+		if l == layer {
+			return int32(i)
+		}
+	}
+	return -1
+}
+
+// HistoryLayerStructure is for operations which reorder or regroup layers
+type HistoryLayerStructure struct {
+	Prev, Current LayerStructure
+	Name          string
+}
+
+// Apply sets the structure after the action
+func (h HistoryLayerStructure) Apply(f *File) {
+	f.SetLayerStructure(h.Current)
+}
+
+// Revert sets the structure before the action
+func (h HistoryLayerStructure) Revert(f *File) {
+	f.SetLayerStructure(h.Prev)
+}
+
+func (h HistoryLayerStructure) String() string {
+	return h.Name
+}
+
+// Size returns the size of both structures, pixels are only counted for
+// layers which aren't in the current structure
+func (h HistoryLayerStructure) Size() int {
+	size := (len(h.Prev.Layers) + len(h.Current.Layers)) * 16
+	for _, layer := range h.Prev.Layers {
+		if h.Current.layerIndex(layer) < 0 {
+			size += len(layer.PixelData) * 24
+		}
+	}
+	return size
+}
+
+// HistoryLayerProperties is for changes to a layer's name, visibility, etc
+type HistoryLayerProperties struct {
+	LayerIndex    int32
+	Prev, Current LayerProperties
+	Name          string
+}
+
+// Apply sets the properties after the change
+func (h HistoryLayerProperties) Apply(f *File) {
+	f.Layers[h.LayerIndex].SetProperties(h.Current)
+}
+
+// Revert sets the properties before the change
+func (h HistoryLayerProperties) Revert(f *File) {
+	f.Layers[h.LayerIndex].SetProperties(h.Prev)
+}
+
+func (h HistoryLayerProperties) String() string {
+	return fmt.Sprintf("%s on layer %d", h.Name, h.LayerIndex)
+}
+
+// Size returns the size of the properties
+func (h HistoryLayerProperties) Size() int {
+	return 64 + len(h.Prev.Name) + len(h.Current.Name)
+}
+
+// HistoryLayerEffects is for changes to a layer's effects. Applying the
+// effects also changes the pixels
+type HistoryLayerEffects struct {
+	LayerIndex    int32
+	Prev, Current []LayerEffect
+	// PixelState is nil unless the pixels were changed
+	PixelState map[IntVec2]PixelStateData
+	Name       string
+}
+
+// set sets the effects and pixels before or after the change
+func (h HistoryLayerEffects) set(f *File, prev bool) {
+	layer := f.Layers[h.LayerIndex]
+	effects := h.Current
+	if prev {
+		effects = h.Prev
+	}
+	layer.Effects = copyLayerEffects(effects)
+
+	if h.PixelState == nil {
+		return
+	}
+	for pos, psd := range h.PixelState {
+		if prev {
+			layer.PixelData[pos] = psd.Prev
+		} else {
+			layer.PixelData[pos] = psd.Current
+		}
+		if h.LayerIndex == f.CurrentLayer {
+			f.syncSelectedPixel(pos, layer.PixelData[pos])
+		}
+	}
+	layer.Redraw()
+}
+
+// Apply sets the effects after the change
+func (h HistoryLayerEffects) Apply(f *File) {
+	h.set(f, false)
+}
+
+// Revert sets the effects before the change
+func (h HistoryLayerEffects) Revert(f *File) {
+	h.set(f, true)
+}
+
+func (h HistoryLayerEffects) String() string {
+	return fmt.Sprintf("%s on layer %d", h.Name, h.LayerIndex)
+}
+
+// Size returns the size of the effects and pixels
+func (h HistoryLayerEffects) Size() int {
+	size := 64 + len(h.PixelState)*48
+	for _, effects := range [][]LayerEffect{h.Prev, h.Current} {
+		for _, effect := range effects {
+			size += 64 + len(effect.Palette)*4
+		}
+	}
+	return size
+}
+
+// AnimationState is every animation in a file and the current one
+type AnimationState struct {
+	Animations []Animation
+	Current    int32
+}
+
+// HistoryAnimations is for adding, removing and changing animations
+type HistoryAnimations struct {
+	Prev, Current AnimationState
+	Name          string
+}
+
+// Apply sets the animations after the change
+func (h HistoryAnimations) Apply(f *File) {
+	f.SetAnimationState(h.Current)
+}
+
+// Revert sets the animations before the change
+func (h HistoryAnimations) Revert(f *File) {
+	f.SetAnimationState(h.Prev)
+}
+
+func (h HistoryAnimations) String() string {
+	return h.Name
+}
+
+// Size returns the size of both states
+func (h HistoryAnimations) Size() int {
+	return (len(h.Prev.Animations) + len(h.Current.Animations)) * 48
+}
+
+// HistoryTileSize is for changes to the tile size
+type HistoryTileSize struct {
+	PrevWidth, PrevHeight       int32
+	CurrentWidth, CurrentHeight int32
+}
+
+// Apply sets the new tile size
+func (h HistoryTileSize) Apply(f *File) {
+	f.setTileSize(h.CurrentWidth, h.CurrentHeight)
+}
+
+// Revert sets the previous tile size
+func (h HistoryTileSize) Revert(f *File) {
+	f.setTileSize(h.PrevWidth, h.PrevHeight)
+}
+
+func (h HistoryTileSize) String() string {
+	return fmt.Sprintf("Tile size %dx%d", h.CurrentWidth, h.CurrentHeight)
+}
+
+// Size returns the size of the action
+func (h HistoryTileSize) Size() int {
+	return 16
+}
+
+// HistoryGuides is for guides being added, moved or removed
+type HistoryGuides struct {
+	Prev, Current []Guide
+}
+
+// Apply sets the new guides
+func (h HistoryGuides) Apply(f *File) {
+	f.Guides = h.Current
+}
+
+// Revert sets the previous guides
+func (h HistoryGuides) Revert(f *File) {
+	f.Guides = h.Prev
+}
+
+func (h HistoryGuides) String() string {
+	return "Guides"
+}
+
+// Size returns the size of both states
+func (h HistoryGuides) Size() int {
+	return (len(h.Prev) + len(h.Current)) * 8
+}
+
+// PixelStateData stores what the state was previously and currently
+// Prev is used by undo and Current is used by redo
+type PixelStateData struct {
+	Prev, Current rl.Color
+}
+
+// HistoryPixel is for pixel operations
+type HistoryPixel struct {
+	PixelState map[IntVec2]PixelStateData
+	LayerIndex int32
+	// Name is what made the change, such as the tool
+	Name string
+	// selectionID is set when the action records picking up a selection,
+	// which CommitSelection adds to after other actions may have been added
+	selectionID int32
+}
+
+// setPixels sets the layer's pixels to the Prev or Current state
+func (h HistoryPixel) setPixels(f *File, prev bool) {
+	if h.LayerIndex >= int32(len(f.Layers)) {
+		log.Println("History refers to a layer which doesn't exist")
+		return
+	}
+
+	layer := f.Layers[h.LayerIndex]
+	for pos, psd := range h.PixelState {
+		if prev {
+			layer.PixelData[pos] = psd.Prev
+		} else {
+			layer.PixelData[pos] = psd.Current
+		}
+		if h.LayerIndex == f.CurrentLayer {
+			f.syncSelectedPixel(pos, layer.PixelData[pos])
+		}
+	}
+	layer.Redraw()
+}
+
+// Apply sets the pixels to their current state
+func (h HistoryPixel) Apply(f *File) {
+	h.setPixels(f, false)
+}
+
+// Revert sets the pixels to their previous state
+func (h HistoryPixel) Revert(f *File) {
+	if f.DoingSelection {
+		f.Selection = make(map[IntVec2]rl.Color)
+		f.DoingSelection = false
+		f.SelectionMoving = false
+		f.SelectionTransform = nil
+	}
+	h.setPixels(f, true)
+}
+
+func (h HistoryPixel) String() string {
+	name := h.Name
+	if name == "" {
+		name = "Pixels"
+	}
+	return fmt.Sprintf("%s on layer %d", name, h.LayerIndex)
+}
+
+// Size returns the size of the pixel map
+func (h HistoryPixel) Size() int {
+	return len(h.PixelState) * 48
+}
+
+func (h HistoryPixel) compress() HistoryAction {
+	return historyPixelCompressed{
+		Data:       encodePixelStates(h.PixelState),
+		LayerIndex: h.LayerIndex,
+		Name:       h.Name,
+	}
+}
+
+// historyPixelCompressed is a HistoryPixel which has been compressed
+type historyPixelCompressed struct {
+	Data       []byte
+	LayerIndex int32
+	Name       string
+}
+
+func (h historyPixelCompressed) decompress() HistoryPixel {
+	return HistoryPixel{
+		PixelState: decodePixelStates(h.Data),
+		LayerIndex: h.LayerIndex,
+		Name:       h.Name,
+	}
+}
+
+// Apply sets the pixels to their current state
+func (h historyPixelCompressed) Apply(f *File) {
+	h.decompress().Apply(f)
+}
+
+// Revert sets the pixels to their previous state
+func (h historyPixelCompressed) Revert(f *File) {
+	h.decompress().Revert(f)
+}
+
+func (h historyPixelCompressed) String() string {
+	return HistoryPixel{LayerIndex: h.LayerIndex, Name: h.Name}.String()
+}
+
+// Size returns the size of the compressed data
+func (h historyPixelCompressed) Size() int {
+	return len(h.Data)
+}
+
+// HistoryResize is for resize operations
+type HistoryResize struct {
+	// LayerStates are the pixels which changed on each layer, compressed
+	// with encodePixelStates. It's nil for layers which didn't change
+	LayerStates [][]byte
+	// Used for calling Layer.Resize. ResizeDirection doesn't matter
+	PrevWidth, PrevHeight       int32
+	CurrentWidth, CurrentHeight int32
+}
+
+// diffPixels returns the pixels which are different in current, compressed
+// with encodePixelStates, or nil if none are. Missing pixels are blank
+func diffPixels(prev, current map[IntVec2]rl.Color) []byte {
+	states := make(map[IntVec2]PixelStateData)
+	for loc, color := range prev {
+		if current[loc] != color {
+			states[loc] = PixelStateData{Prev: color, Current: current[loc]}
+		}
+	}
+	for loc, color := range current {
+		if _, ok := prev[loc]; !ok && color != rl.Blank {
+			states[loc] = PixelStateData{Prev: rl.Blank, Current: color}
+		}
+	}
+	if len(states) == 0 {
+		return nil
+	}
+	return encodePixelStates(states)
+}
+
+// resize sets the canvas size and the layers' changed pixels
+func (h HistoryResize) resize(f *File, prev bool) {
+	width, height := h.CurrentWidth, h.CurrentHeight
+	if prev {
+		width, height = h.PrevWidth, h.PrevHeight
+	}
+	f.CanvasWidthResizePreview = width
+	f.CanvasHeightResizePreview = height
+	f.CanvasWidth = width
+	f.CanvasHeight = height
+	for i, data := range h.LayerStates {
+		layer := f.Layers[i]
+		if data != nil {
+			for loc, state := range decodePixelStates(data) {
+				if prev {
+					layer.PixelData[loc] = state.Prev
+				} else {
+					layer.PixelData[loc] = state.Current
+				}
+			}
+		}
+		// Drops the pixels outside of the canvas
+		layer.Resize(width, height, ResizeTL)
+	}
+	f.RenderLayer.Resize(width, height, ResizeTL)
+}
+
+// Apply resizes to the new size
+func (h HistoryResize) Apply(f *File) {
+	h.resize(f, false)
+}
+
+// Revert resizes to the previous size
+func (h HistoryResize) Revert(f *File) {
+	h.resize(f, true)
+}
+
+func (h HistoryResize) String() string {
+	return fmt.Sprintf("Resize to %dx%d", h.CurrentWidth, h.CurrentHeight)
+}
+
+// Size returns the size of the compressed changes
+func (h HistoryResize) Size() int {
+	size := 0
+	for i := range h.LayerStates {
+		size += len(h.LayerStates[i])
+	}
+	return size
+}
+
+// HistoryImage is for operations on the whole image. Along with resizing, it
+// sets the tile size, animations, guides, grid and selection masks which
+// change to match
+type HistoryImage struct {
+	HistoryResize
+	Name string
+
+	PrevTileWidth, PrevTileHeight       int32
+	CurrentTileWidth, CurrentTileHeight int32
+	PrevAnimations, CurrentAnimations   AnimationState
+	PrevGuides, CurrentGuides           []Guide
+	PrevGrid, CurrentGrid               ReferenceGrid
+	PrevMasks, CurrentMasks             []SelectionMask
+}
+
+// Apply changes the image again
+func (h HistoryImage) Apply(f *File) {
+	h.HistoryResize.Apply(f)
+	f.setTileSize(h.CurrentTileWidth, h.CurrentTileHeight)
+	f.SetAnimationState(h.CurrentAnimations)
+	f.Guides = h.CurrentGuides
+	f.ReferenceGrid = h.CurrentGrid
+	f.SelectionMasks = h.CurrentMasks
+}
+
+// Revert sets the image back to how it was
+func (h HistoryImage) Revert(f *File) {
+	h.HistoryResize.Revert(f)
+	f.setTileSize(h.PrevTileWidth, h.PrevTileHeight)
+	f.SetAnimationState(h.PrevAnimations)
+	f.Guides = h.PrevGuides
+	f.ReferenceGrid = h.PrevGrid
+	f.SelectionMasks = h.PrevMasks
+}
+
+func (h HistoryImage) String() string {
+	return h.Name
+}
+
+// Size returns the size of the compressed layers and the other states
+func (h HistoryImage) Size() int {
+	size := h.HistoryResize.Size() +
+		(len(h.PrevAnimations.Animations)+len(h.CurrentAnimations.Animations))*48 +
+		(len(h.PrevGuides)+len(h.CurrentGuides))*8
+	for _, masks := range [][]SelectionMask{h.PrevMasks, h.CurrentMasks} {
+		for _, mask := range masks {
+			size += len(mask.Pixels) * 16
+		}
+	}
+	return size
+}
+
+// sortedLocations returns the keys of a pixel map ordered by row, which
+// compresses better than the random map order
+func sortedLocations(n int, each func(func(loc IntVec2))) []IntVec2 {
+	locs := make([]IntVec2, 0, n)
+	each(func(loc IntVec2) {
+		locs = append(locs, loc)
+	})
+	sort.Slice(locs, func(i, j int) bool {
+		if locs[i].Y == locs[j].Y {
+			return locs[i].X < locs[j].X
+		}
+		return locs[i].Y < locs[j].Y
+	})
+	return locs
+}
+
+// compressRecords writes the records with zlib
+func compressRecords(write func(w io.Writer)) []byte {
+	var buf bytes.Buffer
+	zw := zlib.NewWriter(&buf)
+	write(zw)
+	if err := zw.Close(); err != nil {
+		log.Println(err)
+	}
+	return buf.Bytes()
+}
+
+// decompressRecords reads records written by compressRecords until there
+// aren't any left
+func decompressRecords(data []byte, record interface{}, each func()) {
+	zr, err := zlib.NewReader(bytes.NewReader(data))
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	defer zr.Close()
+
+	for {
+		if err := binary.Read(zr, binary.LittleEndian, record); err != nil {
+			if err != io.EOF {
+				log.Println(err)
+			}
+			return
+		}
+		each()
+	}
+}
+
+// pixelRecord is how a pixel is stored by encodePixels
+type pixelRecord struct {
+	X, Y       int32
+	R, G, B, A uint8
+}
+
+// encodePixels compresses a layer's pixels
+func encodePixels(pixels map[IntVec2]rl.Color) []byte {
+	locs := sortedLocations(len(pixels), func(add func(IntVec2)) {
+		for loc := range pixels {
+			add(loc)
+		}
+	})
+	return compressRecords(func(w io.Writer) {
+		for _, loc := range locs {
+			c := pixels[loc]
+			binary.Write(w, binary.LittleEndian, pixelRecord{loc.X, loc.Y, c.R, c.G, c.B, c.A})
+		}
+	})
+}
+
+// decodePixels decompresses pixels compressed by encodePixels
+func decodePixels(data []byte) map[IntVec2]rl.Color {
+	pixels := make(map[IntVec2]rl.Color)
+	var r pixelRecord
+	decompressRecords(data, &r, func() {
+		pixels[IntVec2{r.X, r.Y}] = rl.NewColor(r.R, r.G, r.B, r.A)
+	})
+	return pixels
+}
+
+// pixelStateRecord is how a pixel's state is stored by encodePixelStates
+type pixelStateRecord struct {
+	X, Y    int32
+	Prev    [4]uint8
+	Current [4]uint8
+}
+
+// encodePixelStates compresses the pixel states of a HistoryPixel
+func encodePixelStates(states map[IntVec2]PixelStateData) []byte {
+	locs := sortedLocations(len(states), func(add func(IntVec2)) {
+		for loc := range states {
+			add(loc)
+		}
+	})
+	return compressRecords(func(w io.Writer) {
+		for _, loc := range locs {
+			p := states[loc].Prev
+			c := states[loc].Current
+			binary.Write(w, binary.LittleEndian, pixelStateRecord{
+				loc.X, loc.Y,
+				[4]uint8{p.R, p.G, p.B, p.A},
+				[4]uint8{c.R, c.G, c.B, c.A},
+			})
+		}
+	})
+}
+
+// decodePixelStates decompresses pixel states compressed by
+// encodePixelStates
+func decodePixelStates(data []byte) map[IntVec2]PixelStateData {
+	states := make(map[IntVec2]PixelStateData)
+	var r pixelStateRecord
+	decompressRecords(data, &r, func() {
+		states[IntVec2{r.X, r.Y}] = PixelStateData{
+			Prev:    rl.NewColor(r.Prev[0], r.Prev[1], r.Prev[2], r.Prev[3]),
+			Current: rl.NewColor(r.Current[0], r.Current[1], r.Current[2], r.Current[3]),
+		}
+	})
+	return states
+}
+
+// AppendHistory inserts a new action to f.History depending on the
+// historyOffset
+func (f *File) AppendHistory(action HistoryAction) {
+	f.FileChanged = true
+	// Clear everything past the offset if a change has been made after undoing
+	f.History = f.History[0 : int32(len(f.History))-f.historyOffset]
+	f.historyOffset = 0
+	if f.savedPosition >= int32(len(f.History)) {
+		f.savedPosition = unsavedPosition
+	}
+
+	// Nothing else will be added to the last action, so it can be compressed.
+	// A moving selection is added to when it's committed
+	if len(f.History) > 0 && f.floatingHistory() != len(f.History)-1 {
+		if c, ok := f.History[len(f.History)-1].(historyCompressor); ok {
+			f.History[len(f.History)-1] = c.compress()
+		}
+	}
+
+	f.History = append(f.History, action)
+	f.trimHistory()
+
+	EditorsUIRebuild()
+	HistoryUIRebuild()
+}
+
+// floatingHistory returns the index of the action recording the moving
+// selection, or -1 if nothing is moving or it has been undone or removed
+func (f *File) floatingHistory() int {
+	if !f.SelectionMoving {
+		return -1
+	}
+	for i := len(f.History) - 1 - int(f.historyOffset); i >= 0; i-- {
+		if h, ok := f.History[i].(HistoryPixel); ok && h.selectionID == f.selectionID {
+			return i
+		}
+	}
+	return -1
+}
+
+// floatingLayer returns the layer which the moving selection will be placed
+// on, the one it was picked up from if that's still known
+func (f *File) floatingLayer() *Layer {
+	if index := f.floatingHistory(); index >= 0 {
+		if i := f.History[index].(HistoryPixel).LayerIndex; i < int32(len(f.Layers)-1) {
+			return f.Layers[i]
+		}
+	}
+	return f.GetCurrentLayer()
+}
+
+// lastHistory returns the last action if nothing has been undone since it
+// was added, so that it can be extended instead of adding another action
+func (f *File) lastHistory() (HistoryAction, bool) {
+	if f.historyOffset != 0 || len(f.History) == 0 {
+		return nil, false
+	}
+	return f.History[len(f.History)-1], true
+}
+
+// replaceLastHistory replaces the action returned by lastHistory
+func (f *File) replaceLastHistory(action HistoryAction) {
+	f.FileChanged = true
+	if f.savedPosition == f.HistoryPosition() {
+		f.savedPosition = unsavedPosition
+	}
+	f.History[len(f.History)-1] = action
+	EditorsUIRebuild()
+	HistoryUIRebuild()
+}
+
+// HistorySize returns roughly how many bytes the history is using
+func (f *File) HistorySize() int {
+	size := 0
+	for _, action := range f.History {
+		size += action.Size()
+	}
+	return size
+}
+
+// trimHistory removes the oldest actions until the history is within
+// f.HistoryMaxActions and f.HistoryMaxBytes. The newest action is always
+// kept
+func (f *File) trimHistory() {
+	remove := 0
+	if over := int32(len(f.History)) - f.HistoryMaxActions; over > 0 {
+		remove = int(over)
+	}
+
+	size := f.HistorySize()
+	for _, action := range f.History[:remove] {
+		size -= action.Size()
+	}
+	for f.HistoryMaxBytes > 0 && size > f.HistoryMaxBytes && remove < len(f.History)-1 {
+		size -= f.History[remove].Size()
+		remove++
+	}
+
+	if remove > 0 {
+		f.History = append(f.History[:0], f.History[remove:]...)
+		if f.savedPosition != unsavedPosition {
+			f.savedPosition -= int32(remove)
+			if f.savedPosition < -1 {
+				f.savedPosition = unsavedPosition
+			}
+		}
+	}
+}
+
+// undo reverts the last applied action, returning false if there isn't one
+func (f *File) undo() bool {
+	if f.historyOffset >= int32(len(f.History)) {
+		return false
+	}
+	f.historyOffset++
+	f.History[int32(len(f.History))-f.historyOffset].Revert(f)
+	return true
+}
+
+// redo applies the last reverted action, returning false if there isn't one
+func (f *File) redo() bool {
+	if f.historyOffset <= 0 {
+		return false
+	}
+	index := int32(len(f.History)) - f.historyOffset
+	f.historyOffset--
+	f.History[index].Apply(f)
+	return true
+}
+
+// unsavedPosition is the savedPosition of a file whose saved state can't be
+// reached by undoing or redoing
+const unsavedPosition int32 = -2
+
+// markChanged marks the file as changed by something which isn't in the
+// history, so undoing can't take it back to how it was saved
+func (f *File) markChanged() {
+	f.FileChanged = true
+	f.savedPosition = unsavedPosition
+}
+
+// historyChanged updates everything which depends on the history position
+func (f *File) historyChanged() {
+	f.FileChanged = f.HistoryPosition() != f.savedPosition
+	if f.CurrentLayer > int32(len(f.Layers)-2) {
+		f.SetCurrentLayer(int32(len(f.Layers) - 2))
+	}
+	f.RedrawRenderLayer()
+	if f == CurrentFile {
+		LayersUIRebuildList()
+		AnimationsUIRebuildList()
+		EditorsUIRebuild()
+		HistoryUIRebuild()
+	}
+}
+
+// Undo undoes an action
+func (f *File) Undo() {
+	if f.undo() {
+		f.historyChanged()
+	}
+}
+
+// Redo redoes an action
+func (f *File) Redo() {
+	if f.redo() {
+		f.historyChanged()
+	}
+}
+
+// HistoryPosition returns the index of the last applied action, -1 if
+// everything has been undone
+func (f *File) HistoryPosition() int32 {
+	return int32(len(f.History)) - f.historyOffset - 1
+}
+
+// JumpToHistory undoes or redoes actions until the action at index is the
+// last one applied. An index of -1 undoes everything
+func (f *File) JumpToHistory(index int32) {
+	changed := false
+	for f.HistoryPosition() > index && f.undo() {
+		changed = true
+	}
+	for f.HistoryPosition() < index && f.redo() {
+		changed = true
+	}
+	if changed {
+		f.historyChanged()
+	}
+}
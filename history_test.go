@@ -0,0 +1,330 @@
+package main
+
+import (
+	"bytes"
+	"encoding/gob"
+	"fmt"
+	"math"
+	"math/rand"
+	"reflect"
+	"testing"
+
+	rl "github.com/gen2brain/raylib-go/raylib"
+)
+
+// newTestFile returns a file with a pattern on its first layer, with
+// unlimited history, as the current file. Nothing is drawn to textures
+func newTestFile(t *testing.T) *File {
+	Headless = true
+	if Settings == nil {
+		Settings = &SettingsData{HistoryData: defaultHistory}
+	}
+	prevFile := CurrentFile
+	f := NewFile(32, 32, 8, 8)
+	f.HistoryMaxActions = math.MaxInt32
+	f.HistoryMaxBytes = 0
+	CurrentFile = f
+	t.Cleanup(func() {
+		f.Destroy()
+		CurrentFile = prevFile
+	})
+
+	for x := int32(0); x < f.CanvasWidth; x++ {
+		for y := int32(0); y < f.CanvasHeight; y++ {
+			f.Layers[0].PixelData[IntVec2{x, y}] = rl.NewColor(uint8(x*8), uint8(y*8), 128, 255)
+		}
+	}
+	f.RedrawRenderLayer()
+	return f
+}
+
+// savedState returns the file as it's written to a .pix, read back again.
+// Maps are written in a random order so the bytes can't be compared. Blank
+// pixels are dropped since a missing pixel is also blank
+func savedState(t *testing.T, f *File) FileSer {
+	var buf bytes.Buffer
+	if err := f.encodePix(&buf); err != nil {
+		t.Fatal(err)
+	}
+	var ser FileSer
+	if err := gob.NewDecoder(&buf).Decode(&ser); err != nil {
+		t.Fatal(err)
+	}
+	for _, layer := range ser.Layers {
+		pixels := make(map[IntVec2]rl.Color)
+		for loc, color := range layer.PixelData {
+			if color != rl.Blank {
+				pixels[loc] = color
+			}
+		}
+		layer.PixelData = pixels
+	}
+	return ser
+}
+
+// stateDifference describes the first difference between the saved files
+func stateDifference(want, got FileSer) string {
+	if len(want.Layers) != len(got.Layers) {
+		return fmt.Sprintf("%d layers, want %d", len(got.Layers), len(want.Layers))
+	}
+	for i := range want.Layers {
+		w, g := *want.Layers[i], *got.Layers[i]
+		for loc, color := range w.PixelData {
+			if g.PixelData[loc] != color {
+				return fmt.Sprintf("layer %d pixel %v is %v, want %v", i, loc, g.PixelData[loc], color)
+			}
+		}
+		for loc, color := range g.PixelData {
+			if _, ok := w.PixelData[loc]; !ok {
+				return fmt.Sprintf("layer %d pixel %v is %v, want blank", i, loc, color)
+			}
+		}
+		w.PixelData, g.PixelData = nil, nil
+		if !reflect.DeepEqual(w, g) {
+			return fmt.Sprintf("layer %d is %+v, want %+v", i, g, w)
+		}
+	}
+	if len(want.Animations) != len(got.Animations) {
+		return fmt.Sprintf("%d animations, want %d", len(got.Animations), len(want.Animations))
+	}
+	for i := range want.Animations {
+		if *want.Animations[i] != *got.Animations[i] {
+			return fmt.Sprintf("animation %d is %+v, want %+v", i, *got.Animations[i], *want.Animations[i])
+		}
+	}
+	want.Layers, got.Layers = nil, nil
+	want.Animations, got.Animations = nil, nil
+	return fmt.Sprintf("%+v, want %+v", got, want)
+}
+
+// historyTestEdits returns the edits which TestHistoryReplay picks from.
+// Edits are allowed to fail, such as deleting the only layer
+func historyTestEdits(f *File, r *rand.Rand) []func() {
+	randomLayer := func() int32 {
+		return r.Int31n(int32(len(f.Layers) - 1))
+	}
+	randomColor := func() rl.Color {
+		return rl.NewColor(uint8(r.Intn(256)), uint8(r.Intn(256)), uint8(r.Intn(256)), uint8(r.Intn(256)))
+	}
+	randomPixels := func() map[IntVec2]bool {
+		locs := make(map[IntVec2]bool)
+		x, y := r.Int31n(f.CanvasWidth), r.Int31n(f.CanvasHeight)
+		for i := int32(0); i < 16; i++ {
+			locs[IntVec2{x + i%4, y + i/4}] = true
+		}
+		return locs
+	}
+
+	return []func(){
+		func() {
+			f.SetCurrentLayer(randomLayer())
+			f.AppendHistory(HistoryPixel{PixelState: make(map[IntVec2]PixelStateData), LayerIndex: f.CurrentLayer, Name: "Test"})
+			children := f.layerChildren()
+			for i := 0; i < 20; i++ {
+				x, y := r.Int31n(f.CanvasWidth), r.Int31n(f.CanvasHeight)
+				color := randomColor()
+				if r.Intn(4) == 0 {
+					color = rl.Blank
+				}
+				f.DrawPixel(x, y, color, f.GetCurrentLayer(), children)
+			}
+		},
+		func() {
+			// Other actions are added while the selection is moving
+			f.SetCurrentLayer(randomLayer())
+			f.SelectPixels(randomPixels(), SelectOpReplace)
+			f.MoveSelection(r.Int31n(5)-2, r.Int31n(5)-2)
+			f.SetLayerHidden(randomLayer(), r.Intn(2) == 0)
+			f.SetGuides([]Guide{{Vertical: r.Intn(2) == 0, Position: r.Int31n(f.CanvasWidth)}})
+			f.MoveSelection(r.Int31n(5)-2, r.Int31n(5)-2)
+			f.CommitSelection()
+		},
+		func() { f.AddNewLayer() },
+		func() { f.DeleteLayer(randomLayer(), true) },
+		func() { f.MergeLayerDown(randomLayer()) },
+		func() { f.MoveLayerUp(randomLayer(), true) },
+		func() { f.MoveLayerDown(randomLayer(), true) },
+		func() { f.MoveLayer(randomLayer(), randomLayer()) },
+		func() { f.AddNewGroup(randomLayer()) },
+		func() { f.Ungroup(randomLayer()) },
+		func() { f.AddLayerMask(randomLayer()) },
+		func() { f.ScaleImage(2, ScaleModeNearest) },
+		func() { f.RotateImage(r.Int31n(3) + 1) },
+		func() { f.TrimImage() },
+		func() {
+			f.SelectPixels(randomPixels(), SelectOpReplace)
+			f.CropToSelection()
+		},
+		func() { f.RegridImage(r.Int31n(8)+4, r.Int31n(8)+4, r.Int31n(4)+1, ResizeBC) },
+		func() { f.MoveTiles(r.Int31n(4), r.Int31n(4)+4, r.Int31n(8)) },
+		func() { f.SwapTiles(r.Int31n(8), r.Int31n(8)) },
+		func() { f.InsertTile(r.Int31n(8)) },
+		func() { f.DeleteTiles(r.Int31n(4), r.Int31n(4)+4) },
+		func() { f.DuplicateTiles(r.Int31n(4), r.Int31n(4)+4) },
+		func() { f.DuplicateLayer(randomLayer()) },
+		func() {
+			effect := NewLayerEffect(LayerEffectKind(r.Int31n(int32(LayerEffectOpacity) + 1)))
+			effect.Color = randomColor()
+			effect.Palette = []rl.Color{randomColor(), randomColor()}
+			f.AddLayerEffect(randomLayer(), effect)
+		},
+		func() {
+			effect := NewLayerEffect(LayerEffectDropShadow)
+			effect.OffsetX, effect.OffsetY, effect.PerTile = r.Int31n(5)-2, r.Int31n(5)-2, r.Intn(2) == 0
+			f.SetLayerEffect(randomLayer(), 0, effect)
+		},
+		func() { f.DeleteLayerEffect(randomLayer(), 0) },
+		func() { f.SetLayerEffectsHidden(randomLayer(), r.Intn(2) == 0) },
+		func() { f.ApplyLayerEffects(randomLayer()) },
+		func() { f.SetLayerClipping(randomLayer(), r.Intn(2) == 0) },
+		func() { f.SetLayerHidden(randomLayer(), r.Intn(2) == 0) },
+		func() { f.SetLayerName(randomLayer(), fmt.Sprintf("layer %d", r.Intn(100))) },
+		func() { f.AddNewAnimation() },
+		func() { f.DeleteAnimation(r.Int31n(int32(len(f.Animations) + 1))) },
+		func() { f.SetAnimationFrames(r.Int31n(int32(len(f.Animations)+1)), r.Int31n(16), r.Int31n(16)) },
+		func() { f.SetAnimationName(r.Int31n(int32(len(f.Animations)+1)), fmt.Sprintf("anim %d", r.Intn(100))) },
+		func() {
+			if len(f.Animations) > 0 {
+				f.SetCurrentAnimation(r.Int31n(int32(len(f.Animations))))
+				f.SetCurrentAnimationTiming(float32(r.Intn(30)))
+			}
+		},
+		func() {
+			f.SetGuides([]Guide{{Vertical: true, Position: r.Int31n(f.CanvasWidth)}, {Position: r.Int31n(f.CanvasHeight)}})
+		},
+		func() { f.ResizeTileSize(r.Int31n(16)+1, r.Int31n(16)+1) },
+		func() { f.ResizeCanvas(r.Int31n(24)+8, r.Int31n(24)+8, ResizeTL) },
+		func() { f.FlipHorizontal() },
+		func() { f.FlipVertical() },
+		func() {
+			f.Outline(OutlineOptions{Color: rl.White, Inside: r.Intn(2) == 0, Diagonal: r.Intn(2) == 0, Merged: r.Intn(2) == 0, PerTile: r.Intn(2) == 0})
+		},
+		func() {
+			f.DropShadow(ShadowOptions{OffsetX: r.Int31n(5) - 2, OffsetY: r.Int31n(5) - 2, Color: rl.Black, Merged: r.Intn(2) == 0, PerTile: r.Intn(2) == 0})
+		},
+	}
+}
+
+// TestHistoryReplay makes random edits, then undoes them one at a time
+// checking that each undo restores the saved file exactly as it was before
+// the edit. Everything is then redone and checked the same way
+func TestHistoryReplay(t *testing.T) {
+	for seed := int64(1); seed <= 20; seed++ {
+		t.Run(fmt.Sprintf("seed %d", seed), func(t *testing.T) {
+			testHistoryReplay(t, 200, seed)
+		})
+	}
+}
+
+func testHistoryReplay(t *testing.T, steps int, seed int64) {
+	f := newTestFile(t)
+	r := rand.New(rand.NewSource(seed))
+
+	// Everything which is saved starts out set
+	f.Animations = append(f.Animations, &Animation{Name: "start", FrameEnd: 3, Timing: 5})
+	f.Guides = []Guide{{Vertical: true, Position: 8}, {Position: 16}}
+	f.ReferenceGrid = NewReferenceGrid(4, 4)
+	f.SelectionMasks = []SelectionMask{{Name: "mask", Pixels: map[IntVec2]bool{{1, 1}: true, {2, 3}: true}}}
+	effect := NewLayerEffect(LayerEffectPaletteRemap)
+	effect.Palette = []rl.Color{rl.Black, rl.White}
+	f.Layers[0].Effects = []LayerEffect{effect}
+	f.RedrawRenderLayer()
+
+	// states[n] is the file with the first n actions applied. Edits which
+	// add several actions only know the state after the last one
+	states := map[int32]FileSer{0: savedState(t, f)}
+	edits := historyTestEdits(f, r)
+	for i := 0; i < steps; i++ {
+		edits[r.Intn(len(edits))]()
+		// The edit may have failed, done nothing or been merged into the
+		// last action
+		states[int32(len(f.History))] = savedState(t, f)
+	}
+
+	check := func(action HistoryAction, verb string) {
+		want, ok := states[f.HistoryPosition()+1]
+		if !ok {
+			return
+		}
+		if got := savedState(t, f); !reflect.DeepEqual(want, got) {
+			t.Fatalf("%s \"%s\" didn't restore the file: %s", verb, action, stateDifference(want, got))
+		}
+	}
+	for f.HistoryPosition() >= 0 {
+		action := f.History[f.HistoryPosition()]
+		f.Undo()
+		check(action, "Undoing")
+	}
+	for f.HistoryPosition() < int32(len(f.History)-1) {
+		f.Redo()
+		check(f.History[f.HistoryPosition()], "Redoing")
+	}
+}
+
+// TestMovingSelectionKept checks that a moving selection is placed when
+// other actions are added before it's committed
+func TestMovingSelectionKept(t *testing.T) {
+	f := newTestFile(t)
+	layer := f.Layers[0]
+	color := layer.PixelData[IntVec2{1, 1}]
+
+	f.SelectPixels(map[IntVec2]bool{{1, 1}: true}, SelectOpReplace)
+	f.MoveSelection(2, 0)
+	f.SetLayerName(0, "renamed")
+	f.SetGuides([]Guide{{Vertical: true, Position: 4}})
+	f.CommitSelection()
+
+	if got := layer.PixelData[IntVec2{3, 1}]; got != color {
+		t.Fatalf("The selection wasn't placed, got %v want %v", got, color)
+	}
+	if got := layer.PixelData[IntVec2{1, 1}]; got != rl.Blank {
+		t.Fatalf("The selection wasn't lifted, got %v", got)
+	}
+
+	for f.HistoryPosition() >= 0 {
+		f.Undo()
+	}
+	if got := layer.PixelData[IntVec2{3, 1}]; got == color {
+		t.Fatal("Undoing didn't remove the placed selection")
+	}
+	if got := layer.PixelData[IntVec2{1, 1}]; got != color {
+		t.Fatalf("Undoing didn't restore the lifted pixels, got %v want %v", got, color)
+	}
+}
+
+// TestHistorySavedPosition checks that undoing back to where the file was
+// saved leaves it unchanged
+func TestHistorySavedPosition(t *testing.T) {
+	f := newTestFile(t)
+	f.SetLayerName(0, "a")
+	f.FileChanged = false
+	f.savedPosition = f.HistoryPosition()
+
+	f.SetLayerHidden(0, true)
+	if !f.FileChanged {
+		t.Fatal("The file isn't changed after an edit")
+	}
+	f.Undo()
+	if f.FileChanged {
+		t.Fatal("The file is changed after undoing back to where it was saved")
+	}
+	f.Undo()
+	if !f.FileChanged {
+		t.Fatal("The file isn't changed after undoing past where it was saved")
+	}
+	f.Redo()
+	f.AddNewLayer()
+	f.Undo()
+	if f.FileChanged {
+		t.Fatal("The file is changed after undoing back to where it was saved")
+	}
+
+	f.Undo()
+	f.AddNewLayer()
+	for f.HistoryPosition() < int32(len(f.History)-1) {
+		f.Redo()
+	}
+	if !f.FileChanged {
+		t.Fatal("The file isn't changed after the saved state was replaced")
+	}
+}
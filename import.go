@@ -0,0 +1,293 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"image/draw"
+	"image/png"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	rl "github.com/gen2brain/raylib-go/raylib"
+)
+
+// ImportMode is what an imported png becomes
+type ImportMode int32
+
+// Ways a png can be imported
+const (
+	ImportModeSheet    ImportMode = iota // a new file, cut into tiles
+	ImportModeSequence                   // numbered pngs as frames of an animation
+	ImportModeLayer                      // a new layer in the current file
+)
+
+func (m ImportMode) String() string {
+	switch m {
+	case ImportModeSheet:
+		return "sprite sheet"
+	case ImportModeSequence:
+		return "sequence"
+	case ImportModeLayer:
+		return "new layer"
+	}
+	return ""
+}
+
+// ImportSettings describe how a png is imported
+type ImportSettings struct {
+	Path string
+	Mode ImportMode
+
+	// TileWidth and TileHeight are the size of each sprite in a sheet
+	TileWidth, TileHeight int32
+	// MarginX and MarginY are the space around the edges of a sheet
+	MarginX, MarginY int32
+	// SpacingX and SpacingY are the space between sprites in a sheet
+	SpacingX, SpacingY int32
+	// Frames is how many sprites are used, 0 for all of them
+	Frames int32
+	// FPS is the speed of the animation which is created
+	FPS int32
+}
+
+// NewImportSettings returns the default settings
+func NewImportSettings() ImportSettings {
+	return ImportSettings{
+		TileWidth:  8,
+		TileHeight: 8,
+		FPS:        5,
+	}
+}
+
+// sequencePattern splits a filename like walk_07.png into walk_, 07 and .png
+var sequencePattern = regexp.MustCompile(`(?i)^(.*?)(\d+)(\.png)$`)
+
+// Import imports the png as settings.Mode describes. The new file is returned
+// for sheets and sequences, nil is returned for layers
+func Import(settings ImportSettings) (*File, error) {
+	switch settings.Mode {
+	case ImportModeSheet:
+		return ImportSheet(settings)
+	case ImportModeSequence:
+		return ImportSequence(settings)
+	case ImportModeLayer:
+		return nil, CurrentFile.ImportLayer(settings.Path)
+	}
+	return nil, fmt.Errorf("Can't import: unknown mode %d", settings.Mode)
+}
+
+// loadPNG reads a png
+func loadPNG(openPath string) (*image.NRGBA, error) {
+	reader, err := os.Open(openPath)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	img, err := png.Decode(reader)
+	if err != nil {
+		return nil, fmt.Errorf("Can't import %s: %w", openPath, err)
+	}
+
+	return toNRGBA(img), nil
+}
+
+// toNRGBA converts an image to NRGBA with its top left at 0, 0
+func toNRGBA(img image.Image) *image.NRGBA {
+	b := img.Bounds()
+	nrgba := image.NewNRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Draw(nrgba, nrgba.Bounds(), img, b.Min, draw.Src)
+	return nrgba
+}
+
+// drawImage copies the non transparent pixels of img to the layer with its
+// top left at origin. Pixels outside of the layer are dropped
+func drawImage(layer *Layer, img *image.NRGBA, origin IntVec2) {
+	b := img.Bounds()
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			loc := IntVec2{origin.X + int32(x-b.Min.X), origin.Y + int32(y-b.Min.Y)}
+			if loc.X < 0 || loc.Y < 0 || loc.X >= layer.Width || loc.Y >= layer.Height {
+				continue
+			}
+			c := img.NRGBAAt(x, y)
+			if c.A > 0 {
+				layer.PixelData[loc] = rl.NewColor(c.R, c.G, c.B, c.A)
+			}
+		}
+	}
+}
+
+// newImportedFile lays the frames out as tiles, columns to a row, and makes
+// an animation of them if there's more than one
+func newImportedFile(name string, frames []*image.NRGBA, tileWidth, tileHeight, columns int32, fps int32) *File {
+	columns = MinInt32(columns, int32(len(frames)))
+	rows := (int32(len(frames)) + columns - 1) / columns
+
+	f := NewFile(columns*tileWidth, rows*tileHeight, tileWidth, tileHeight)
+	f.Filename = name
+	layer := f.Layers[0]
+	for i, frame := range frames {
+		drawImage(layer, frame, f.TileOrigin(int32(i)))
+	}
+	layer.Redraw()
+
+	if len(frames) > 1 {
+		if fps < 1 {
+			fps = 5
+		}
+		f.Animations = append(f.Animations, &Animation{
+			Name:       name,
+			FrameStart: 0,
+			FrameEnd:   int32(len(frames) - 1),
+			Timing:     float32(fps),
+		})
+	}
+
+	CurrentFile = f
+	f.RedrawRenderLayer()
+	AnimationsUIRebuildList()
+	LayersUIRebuildList()
+	EditorsUIRebuild()
+	return f
+}
+
+// ImportSheet cuts a sprite sheet into tiles, skipping its margins and the
+// spacing between sprites, and returns it as a new file
+func ImportSheet(settings ImportSettings) (*File, error) {
+	img, err := loadPNG(settings.Path)
+	if err != nil {
+		return nil, err
+	}
+	tw, th := settings.TileWidth, settings.TileHeight
+	if tw < 1 || th < 1 {
+		return nil, fmt.Errorf("Can't import: tile size %dx%d is too small", tw, th)
+	}
+
+	b := img.Bounds()
+	columns := (int32(b.Dx()) - settings.MarginX*2 + settings.SpacingX) / (tw + settings.SpacingX)
+	rows := (int32(b.Dy()) - settings.MarginY*2 + settings.SpacingY) / (th + settings.SpacingY)
+	if columns < 1 || rows < 1 {
+		return nil, fmt.Errorf("Can't import: no %dx%d tiles fit in %s", tw, th, filepath.Base(settings.Path))
+	}
+
+	count := columns * rows
+	if settings.Frames > 0 && settings.Frames < count {
+		count = settings.Frames
+	}
+	frames := make([]*image.NRGBA, 0, count)
+	for i := int32(0); i < count; i++ {
+		x := settings.MarginX + (i%columns)*(tw+settings.SpacingX)
+		y := settings.MarginY + (i/columns)*(th+settings.SpacingY)
+		frames = append(frames, img.SubImage(image.Rect(int(x), int(y), int(x+tw), int(y+th))).(*image.NRGBA))
+	}
+
+	name := strings.TrimSuffix(filepath.Base(settings.Path), filepath.Ext(settings.Path))
+	f := newImportedFile(name, frames, tw, th, columns, settings.FPS)
+	f.PathDir = filepath.Dir(settings.Path)
+	return f, nil
+}
+
+// sequencePaths finds the other numbered pngs in the same sequence as
+// openPath, in order. The name of the sequence is also returned
+func sequencePaths(openPath string) ([]string, string, error) {
+	match := sequencePattern.FindStringSubmatch(filepath.Base(openPath))
+	if match == nil {
+		return nil, "", fmt.Errorf("Can't import: %s isn't numbered like walk_00.png", filepath.Base(openPath))
+	}
+	prefix, suffix := match[1], match[3]
+
+	entries, err := os.ReadDir(filepath.Dir(openPath))
+	if err != nil {
+		return nil, "", err
+	}
+	numbers := make(map[string]int)
+	paths := make([]string, 0)
+	for _, entry := range entries {
+		m := sequencePattern.FindStringSubmatch(entry.Name())
+		if m == nil || m[1] != prefix || !strings.EqualFold(m[3], suffix) {
+			continue
+		}
+		n, err := strconv.Atoi(m[2])
+		if err != nil {
+			continue
+		}
+		p := filepath.Join(filepath.Dir(openPath), entry.Name())
+		numbers[p] = n
+		paths = append(paths, p)
+	}
+	sort.Slice(paths, func(i, j int) bool {
+		return numbers[paths[i]] < numbers[paths[j]]
+	})
+
+	name := strings.TrimRight(prefix, "_- .")
+	if name == "" {
+		name = "sequence"
+	}
+	return paths, name, nil
+}
+
+// ImportSequence loads every png numbered like settings.Path, such as
+// walk_00.png to walk_07.png, as the frames of an animation in a new file.
+// The tiles are the size of the largest frame
+func ImportSequence(settings ImportSettings) (*File, error) {
+	paths, name, err := sequencePaths(settings.Path)
+	if err != nil {
+		return nil, err
+	}
+	if settings.Frames > 0 && int(settings.Frames) < len(paths) {
+		paths = paths[:settings.Frames]
+	}
+
+	frames := make([]*image.NRGBA, 0, len(paths))
+	var tw, th int32
+	for _, p := range paths {
+		img, err := loadPNG(p)
+		if err != nil {
+			return nil, err
+		}
+		frames = append(frames, img)
+		tw = MaxInt32(tw, int32(img.Bounds().Dx()))
+		th = MaxInt32(th, int32(img.Bounds().Dy()))
+	}
+
+	f := newImportedFile(name, frames, tw, th, int32(len(frames)), settings.FPS)
+	f.PathDir = filepath.Dir(settings.Path)
+	return f, nil
+}
+
+// ImportLayer adds the png as a new layer above the current layer and its
+// mask. Anything outside of the canvas is cut off
+func (f *File) ImportLayer(openPath string) error {
+	img, err := loadPNG(openPath)
+	if err != nil {
+		return err
+	}
+
+	name := strings.TrimSuffix(filepath.Base(openPath), filepath.Ext(openPath))
+	layer := NewLayer(f.CanvasWidth, f.CanvasHeight, name, rl.Blank, true)
+	drawImage(layer, img, IntVec2{})
+	layer.Redraw()
+
+	at := f.layerBlockEnd(f.CurrentLayer) + 1
+	f.insertLayerBlock(at, f.GetCurrentLayer().Parent, []*Layer{layer}, "Import layer")
+	f.SetCurrentLayer(at)
+	LayersUIRebuildList()
+	return nil
+}
+
+// newFileFromLayers makes a file from layers which are ordered from the
+// bottom to the top, with each group above its children
+func newFileFromLayers(width, height int32, layers []*Layer) *File {
+	f := NewFile(width, height, 8, 8)
+	f.Layers = append(layers, NewLayer(width, height, "hidden", rl.Blank, true))
+	f.RenderLayer = NewLayer(width, height, "render", rl.Blank, true)
+	for _, layer := range f.Layers {
+		layer.Redraw()
+	}
+	return f
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"image"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestImportLayerAboveMask checks that an imported layer isn't put between a
+// layer and its mask
+func TestImportLayerAboveMask(t *testing.T) {
+	f := newTestFile(t)
+	f.AddLayerMask(0)
+	mask := f.Layers[1]
+	f.SetCurrentLayer(0)
+
+	path := filepath.Join(t.TempDir(), "x.png")
+	file, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := png.Encode(file, image.NewNRGBA(image.Rect(0, 0, 4, 4))); err != nil {
+		t.Fatal(err)
+	}
+	file.Close()
+
+	if err := f.ImportLayer(path); err != nil {
+		t.Fatal(err)
+	}
+	if f.Layers[1] != mask || f.Layers[2].Name != "x" {
+		t.Fatal("The imported layer wasn't put above the mask")
+	}
+}
@@ -14,10 +14,93 @@ type Layer struct {
 
 	// PixelData is the "raw" pixels map
 	PixelData map[IntVec2]rl.Color
+
+	// Parent is the group the layer belongs to, nil for the top level.
+	// Children always sit directly below their group in File.Layers
+	Parent *Layer
+	// IsGroup is true if the layer only holds other layers
+	IsGroup bool
+	// Collapsed hides the group's children in the layers panel
+	Collapsed bool
+	// Clipping layers only draw where the layer below is opaque
+	Clipping bool
+	// IsMask layers hide the pixels of the layer directly below them instead
+	// of drawing. Painted pixels hide, erasing reveals them again
+	IsMask bool
+	// Effects are drawn from the pixels in order, without changing them
+	Effects []LayerEffect
+	// EffectsHidden turns every effect off without removing them
+	EffectsHidden bool
+
+	// effectPixels are the pixels with the effects drawn, nil if there are
+	// none. They're made by File.updateLayerEffects
+	effectPixels map[IntVec2]rl.Color
+}
+
+// LayerProperties are the settings of a layer which aren't its pixels or its
+// place in the layer structure
+type LayerProperties struct {
+	Name          string
+	Hidden        bool
+	BlendMode     rl.BlendMode
+	Clipping      bool
+	EffectsHidden bool
+}
+
+// Properties returns the layer's properties
+func (l *Layer) Properties() LayerProperties {
+	return LayerProperties{
+		Name:          l.Name,
+		Hidden:        l.Hidden,
+		BlendMode:     l.BlendMode,
+		Clipping:      l.Clipping,
+		EffectsHidden: l.EffectsHidden,
+	}
+}
+
+// SetProperties sets the layer's properties
+func (l *Layer) SetProperties(p LayerProperties) {
+	l.Name = p.Name
+	l.Hidden = p.Hidden
+	l.BlendMode = p.BlendMode
+	l.Clipping = p.Clipping
+	l.EffectsHidden = p.EffectsHidden
+}
+
+// HasAncestor returns true if ancestor is one of the layer's parent groups
+func (l *Layer) HasAncestor(ancestor *Layer) bool {
+	for p := l.Parent; p != nil; p = p.Parent {
+		if p == ancestor {
+			return true
+		}
+	}
+	return false
+}
+
+// Visible returns whether the layer and every group it's in are shown
+func (l *Layer) Visible() bool {
+	for p := l; p != nil; p = p.Parent {
+		if p.Hidden {
+			return false
+		}
+	}
+	return true
+}
+
+// Depth returns how many groups the layer is nested in
+func (l *Layer) Depth() int32 {
+	var depth int32
+	for p := l.Parent; p != nil; p = p.Parent {
+		depth++
+	}
+	return depth
 }
 
 // Redraw redraws the layer
 func (l *Layer) Redraw() {
+	if Headless {
+		return
+	}
 	rl.BeginTextureMode(l.Canvas)
 	rl.ClearBackground(rl.Blank)
 	// rl.BeginBlendMode(l.BlendMode)
@@ -30,7 +113,9 @@ func (l *Layer) Redraw() {
 
 // Resize the layer to the specified width, height and direction
 func (l *Layer) Resize(width, height int32, direction ResizeDirection) {
-	l.Canvas = rl.LoadRenderTexture(width, height)
+	if !Headless {
+		l.Canvas = rl.LoadRenderTexture(width, height)
+	}
 
 	w := CurrentFile.CanvasWidth
 	h := CurrentFile.CanvasHeight
@@ -42,7 +127,7 @@ func (l *Layer) Resize(width, height int32, direction ResizeDirection) {
 	var dx int32
 	var dy int32
 
-	switch CurrentFile.CanvasDirectionResizePreview {
+	switch direction {
 	case ResizeTL:
 		dx = 0
 		dy = 0
@@ -73,26 +158,48 @@ func (l *Layer) Resize(width, height int32, direction ResizeDirection) {
 	}
 
 	newPixelData := make(map[IntVec2]rl.Color)
-	rl.BeginTextureMode(l.Canvas)
-	rl.ClearBackground(rl.Blank)
-	for x := dx; x < w; x++ {
-		for y := dy; y < h; y++ {
+	// Pixels which end up outside of the new size are dropped
+	for x := MaxInt32(dx, 0); x < MinInt32(w, dx+nw); x++ {
+		for y := MaxInt32(dy, 0); y < MinInt32(h, dy+nh); y++ {
 			if color, ok := l.PixelData[IntVec2{x, y}]; ok {
-				rl.DrawPixel(x-dx, y-dy, color)
 				newPixelData[IntVec2{x - dx, y - dy}] = color
 			}
 		}
 	}
-	rl.EndTextureMode()
 	l.PixelData = newPixelData
 	l.Width = width
 	l.Height = height
+	l.Redraw()
+}
+
+// Copy returns a copy of the layer with the given size. Pixels outside of the
+// size are dropped and the copy isn't in any group
+func (l *Layer) Copy(width, height int32) *Layer {
+	c := NewLayer(width, height, l.Name, rl.Blank, true)
+	c.Hidden = l.Hidden
+	c.BlendMode = l.BlendMode
+	c.IsGroup = l.IsGroup
+	c.Collapsed = l.Collapsed
+	c.Clipping = l.Clipping
+	c.IsMask = l.IsMask
+	c.Effects = copyLayerEffects(l.Effects)
+	c.EffectsHidden = l.EffectsHidden
+	for loc, color := range l.PixelData {
+		if loc.X >= 0 && loc.Y >= 0 && loc.X < width && loc.Y < height {
+			c.PixelData[loc] = color
+		}
+	}
+	c.Redraw()
+	return c
 }
 
+// Headless is true when there's no window, such as in tests. Layers keep
+// their pixels but nothing is drawn to their textures
+var Headless bool
+
 // NewLayer returns a pointer to a new Layer
 func NewLayer(width, height int32, name string, fillColor rl.Color, shouldFill bool) *Layer {
-	return &Layer{
-		Canvas:    rl.LoadRenderTexture(width, height),
+	l := &Layer{
 		PixelData: make(map[IntVec2]rl.Color),
 		Name:      name,
 		Hidden:    false,
@@ -100,4 +207,8 @@ func NewLayer(width, height int32, name string, fillColor rl.Color, shouldFill b
 		Height:    height,
 		BlendMode: rl.BlendAlpha,
 	}
+	if !Headless {
+		l.Canvas = rl.LoadRenderTexture(width, height)
+	}
+	return l
 }
@@ -79,7 +79,11 @@ func main() {
 			fi, err := os.Stat(argPath)
 			if err == nil {
 				if fi.Mode().IsRegular() {
-					newFile := Open(argPath)
+					newFile, err := Open(argPath)
+					if err != nil {
+						log.Println(err)
+						continue
+					}
 					Files = append(Files, newFile)
 					continue
 				}
@@ -88,12 +92,23 @@ func main() {
 				return
 			}
 		}
+
+		// Nothing could be opened, keep the starting file
+		if len(Files) == 0 {
+			Files = append(Files, CurrentFile)
+		}
 	}
 
+	OfferRecovery()
+
 	// shows filename(s) in tab
 	EditorsUIRebuild()
 
-	for !rl.WindowShouldClose() {
+	for !ShouldQuit {
+		if rl.WindowShouldClose() {
+			UIQuit()
+		}
+
 		if rl.IsWindowFocused() {
 			rl.SetTargetFPS(60)
 		} else {
@@ -101,6 +116,7 @@ func main() {
 		}
 
 		UpdateUI()
+		AutosaveFiles()
 
 		rl.BeginDrawing()
 		rl.ClearBackground(rl.Black)
@@ -113,6 +129,7 @@ func main() {
 		file.Destroy()
 	}
 	DestroyUI()
+	RemoveLock()
 	UIControlSystemCmds <- UIControlChanData{CommandType: CommandTypeQuit}
 
 	rl.CloseWindow()
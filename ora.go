@@ -0,0 +1,357 @@
+package main
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"log"
+	"path/filepath"
+	"strconv"
+
+	rl "github.com/gen2brain/raylib-go/raylib"
+)
+
+// oraCompositeOps maps OpenRaster composite ops to the blend mode. Anything
+// else is drawn normally
+var oraCompositeOps = map[string]rl.BlendMode{
+	"svg:src-over": rl.BlendAlpha,
+	"svg:plus":     rl.BlendAddColors,
+	"svg:multiply": rl.BlendMultiplied,
+}
+
+// oraBlendModes is the reverse of oraCompositeOps
+var oraBlendModes = map[rl.BlendMode]string{
+	rl.BlendAlpha:      "svg:src-over",
+	rl.BlendAdditive:   "svg:plus",
+	rl.BlendAddColors:  "svg:plus",
+	rl.BlendMultiplied: "svg:multiply",
+}
+
+// oraElement is a <stack> or a <layer> in stack.xml
+type oraElement struct {
+	XMLName     xml.Name
+	Name        string       `xml:"name,attr,omitempty"`
+	Src         string       `xml:"src,attr,omitempty"`
+	X           int          `xml:"x,attr,omitempty"`
+	Y           int          `xml:"y,attr,omitempty"`
+	Opacity     string       `xml:"opacity,attr,omitempty"`
+	Visibility  string       `xml:"visibility,attr,omitempty"`
+	CompositeOp string       `xml:"composite-op,attr,omitempty"`
+	Children    []oraElement `xml:",any"`
+}
+
+// oraImage is the root of stack.xml
+type oraImage struct {
+	XMLName xml.Name   `xml:"image"`
+	Version string     `xml:"version,attr"`
+	Width   int        `xml:"w,attr"`
+	Height  int        `xml:"h,attr"`
+	Stack   oraElement `xml:"stack"`
+}
+
+// opacity returns the element's opacity from 0 to 255
+func (e oraElement) opacity() uint8 {
+	if e.Opacity == "" {
+		return 255
+	}
+	opacity, err := strconv.ParseFloat(e.Opacity, 64)
+	if err != nil {
+		return 255
+	}
+	return floatToUint8(opacity)
+}
+
+// blendMode returns the element's blend mode
+func (e oraElement) blendMode() rl.BlendMode {
+	if e.CompositeOp == "" {
+		return rl.BlendAlpha
+	}
+	blendMode, ok := oraCompositeOps[e.CompositeOp]
+	if !ok {
+		log.Printf("Layer \"%s\" uses composite op \"%s\" which isn't supported, it's drawn normally\n", e.Name, e.CompositeOp)
+		return rl.BlendAlpha
+	}
+	return blendMode
+}
+
+// openORA opens an OpenRaster file. Layer opacity is applied to the pixels
+// since layers don't have an opacity of their own. Stacks are moved by their
+// offset, but their opacity is dropped
+func openORA(openPath string) (*File, error) {
+	zr, err := zip.OpenReader(openPath)
+	if err != nil {
+		return nil, fmt.Errorf("Can't open %s: %w", openPath, err)
+	}
+	defer zr.Close()
+
+	files := make(map[string]*zip.File, len(zr.File))
+	for _, file := range zr.File {
+		files[file.Name] = file
+	}
+
+	readPNG := func(name string) (*image.NRGBA, error) {
+		file, ok := files[name]
+		if !ok {
+			return nil, fmt.Errorf("Can't open %s: %s is missing", openPath, name)
+		}
+		reader, err := file.Open()
+		if err != nil {
+			return nil, err
+		}
+		defer reader.Close()
+		img, err := png.Decode(reader)
+		if err != nil {
+			return nil, fmt.Errorf("Can't open %s: %s: %w", openPath, name, err)
+		}
+		return toNRGBA(img), nil
+	}
+
+	stackFile, ok := files["stack.xml"]
+	if !ok {
+		return nil, fmt.Errorf("Can't open %s: stack.xml is missing", openPath)
+	}
+	reader, err := stackFile.Open()
+	if err != nil {
+		return nil, err
+	}
+	var stack oraImage
+	err = xml.NewDecoder(reader).Decode(&stack)
+	reader.Close()
+	if err != nil {
+		return nil, fmt.Errorf("Can't open %s: %w", openPath, err)
+	}
+	width, height := int32(stack.Width), int32(stack.Height)
+
+	// Elements are listed from the top to the bottom
+	layers := make([]*Layer, 0)
+	var addStack func(elements []oraElement, parent *Layer, offset IntVec2) error
+	addStack = func(elements []oraElement, parent *Layer, offset IntVec2) error {
+		for i := len(elements) - 1; i >= 0; i-- {
+			e := elements[i]
+			layer := NewLayer(width, height, e.Name, rl.Blank, true)
+			layer.Hidden = e.Visibility == "hidden"
+			layer.BlendMode = e.blendMode()
+			layer.Parent = parent
+			at := IntVec2{offset.X + int32(e.X), offset.Y + int32(e.Y)}
+
+			switch e.XMLName.Local {
+			case "stack":
+				layer.IsGroup = true
+				if e.opacity() < 255 {
+					log.Printf("Stack \"%s\" has an opacity which isn't supported, it's drawn opaque\n", e.Name)
+				}
+				if err := addStack(e.Children, layer, at); err != nil {
+					return err
+				}
+			case "layer":
+				img, err := readPNG(e.Src)
+				if err != nil {
+					return err
+				}
+				drawImage(layer, img, at)
+				if opacity := e.opacity(); opacity < 255 {
+					for loc, c := range layer.PixelData {
+						c.A = scaleAlpha(c.A, opacity)
+						layer.PixelData[loc] = c
+					}
+				}
+			default:
+				continue
+			}
+			layers = append(layers, layer)
+		}
+		return nil
+	}
+	if err := addStack(stack.Stack.Children, nil, IntVec2{int32(stack.Stack.X), int32(stack.Stack.Y)}); err != nil {
+		return nil, err
+	}
+	if len(layers) == 0 {
+		return nil, fmt.Errorf("Can't open %s: file has no layers", openPath)
+	}
+
+	f := newFileFromLayers(width, height, layers)
+	f.PathDir = filepath.Dir(openPath)
+	f.Filename = filepath.Base(openPath)
+	return f, nil
+}
+
+// oraLayerImages returns the pixels of each of the siblings. OpenRaster
+// doesn't have masks or clipping, so they're applied to the pixels
+func oraLayerImages(children map[*Layer][]*Layer, siblings []*Layer, width, height int32) []*image.NRGBA {
+	images := make([]*image.NRGBA, len(siblings))
+	for i := range siblings {
+		images[i] = image.NewNRGBA(image.Rect(0, 0, int(width), int(height)))
+	}
+
+	for y := int32(0); y < height; y++ {
+		for x := int32(0); x < width; x++ {
+			for i, layer := range siblings {
+				if layer.IsMask {
+					continue
+				}
+				c := siblingColor(children, siblings, i, IntVec2{x, y})
+				images[i].SetNRGBA(int(x), int(y), color.NRGBA{c.R, c.G, c.B, c.A})
+			}
+		}
+	}
+	return images
+}
+
+// siblingColor returns the color of the sibling at i on its own, with its
+// mask and clipping applied as compositePixel does. It has a color even if
+// it's hidden
+func siblingColor(children map[*Layer][]*Layer, siblings []*Layer, i int, loc IntVec2) rl.Color {
+	layer := siblings[i]
+	var c rl.Color
+	if layer.IsGroup {
+		c = compositePixel(children, layer, loc)
+	} else {
+		c = layer.DrawnPixels()[loc]
+	}
+	if i+1 < len(siblings) && siblings[i+1].IsMask && !siblings[i+1].Hidden {
+		c.A = scaleAlpha(c.A, 255-siblings[i+1].PixelData[loc].A)
+	}
+
+	if layer.Clipping {
+		// Clipped to the closest layer below which isn't clipped
+		base := rl.Blank
+		for j := i - 1; j >= 0; j-- {
+			if below := siblings[j]; !below.IsMask && !below.Clipping {
+				if !below.Hidden {
+					base = siblingColor(children, siblings, j, loc)
+				}
+				break
+			}
+		}
+		c.A = scaleAlpha(c.A, base.A)
+	}
+	return c
+}
+
+// encodeORA writes the file as an OpenRaster zip which Krita and other
+// painting programs can open
+func (f *File) encodeORA(w io.Writer) error {
+	f.updateLayerEffects()
+	zw := zip.NewWriter(w)
+
+	// The mimetype must come first and can't be compressed
+	mimetype, err := zw.CreateHeader(&zip.FileHeader{Name: "mimetype", Method: zip.Store})
+	if err != nil {
+		return err
+	}
+	if _, err := io.WriteString(mimetype, "image/openraster"); err != nil {
+		return err
+	}
+
+	writePNG := func(name string, img image.Image) error {
+		pw, err := zw.Create(name)
+		if err != nil {
+			return err
+		}
+		return png.Encode(pw, img)
+	}
+
+	children := f.layerChildren()
+	count := 0
+	var makeStack func(parent *Layer) ([]oraElement, error)
+	makeStack = func(parent *Layer) ([]oraElement, error) {
+		siblings := children[parent]
+		images := oraLayerImages(children, siblings, f.CanvasWidth, f.CanvasHeight)
+		elements := make([]oraElement, 0, len(siblings))
+		for i, layer := range siblings {
+			if layer.IsMask {
+				continue
+			}
+
+			e := oraElement{
+				Name:        layer.Name,
+				Visibility:  "visible",
+				CompositeOp: "svg:src-over",
+			}
+			if layer.Hidden {
+				e.Visibility = "hidden"
+			}
+			if op, ok := oraBlendModes[layer.BlendMode]; ok {
+				e.CompositeOp = op
+			} else {
+				log.Printf("Layer \"%s\" uses a blend mode which OpenRaster doesn't support, it's saved as normal\n", layer.Name)
+			}
+
+			if layer.IsGroup {
+				e.XMLName.Local = "stack"
+				stack, err := makeStack(layer)
+				if err != nil {
+					return nil, err
+				}
+				e.Children = stack
+			} else {
+				e.XMLName.Local = "layer"
+				e.Src = fmt.Sprintf("data/layer%d.png", count)
+				count++
+				if err := writePNG(e.Src, images[i]); err != nil {
+					return nil, err
+				}
+			}
+
+			// Listed from the top to the bottom
+			elements = append([]oraElement{e}, elements...)
+		}
+		return elements, nil
+	}
+
+	elements, err := makeStack(nil)
+	if err != nil {
+		return err
+	}
+	stack, err := zw.Create("stack.xml")
+	if err != nil {
+		return err
+	}
+	io.WriteString(stack, xml.Header)
+	err = xml.NewEncoder(stack).Encode(oraImage{
+		Version: "0.0.3",
+		Width:   int(f.CanvasWidth),
+		Height:  int(f.CanvasHeight),
+		Stack:   oraElement{Children: elements},
+	})
+	if err != nil {
+		return err
+	}
+
+	merged := image.NewNRGBA(image.Rect(0, 0, int(f.CanvasWidth), int(f.CanvasHeight)))
+	for y := int32(0); y < f.CanvasHeight; y++ {
+		for x := int32(0); x < f.CanvasWidth; x++ {
+			c := compositePixel(children, nil, IntVec2{x, y})
+			merged.SetNRGBA(int(x), int(y), color.NRGBA{c.R, c.G, c.B, c.A})
+		}
+	}
+	if err := writePNG("mergedimage.png", merged); err != nil {
+		return err
+	}
+
+	// Thumbnails can't be larger than 256x256
+	thumbnail := merged
+	if step := int(MaxInt32(f.CanvasWidth, f.CanvasHeight)+255) / 256; step > 1 {
+		thumbnail = image.NewNRGBA(image.Rect(0, 0, (int(f.CanvasWidth)+step-1)/step, (int(f.CanvasHeight)+step-1)/step))
+		for y := 0; y < thumbnail.Bounds().Dy(); y++ {
+			for x := 0; x < thumbnail.Bounds().Dx(); x++ {
+				thumbnail.SetNRGBA(x, y, merged.NRGBAAt(x*step, y*step))
+			}
+		}
+	}
+	if err := writePNG("Thumbnails/thumbnail.png", thumbnail); err != nil {
+		return err
+	}
+
+	return zw.Close()
+}
+
+// exportORA writes the whole document to settings.Path. Only the path is
+// used, the other settings are for pngs
+func (f *File) exportORA(settings ExportSettings) error {
+	return writeFileAtomic(settings.Path, f.encodeORA)
+}
@@ -0,0 +1,214 @@
+package main
+
+import (
+	rl "github.com/gen2brain/raylib-go/raylib"
+)
+
+// OutlineOptions are how File.Outline draws
+type OutlineOptions struct {
+	Color rl.Color
+	// Inside recolors the edge pixels of the shape instead of adding pixels
+	// around it
+	Inside bool
+	// Diagonal counts diagonal pixels as touching, so the outline is
+	// 8-connected instead of 4-connected
+	Diagonal bool
+	// Merged outlines every visible layer blended together, onto a new layer
+	Merged bool
+	// PerTile keeps each tile's outline inside the tile
+	PerTile bool
+}
+
+// ShadowOptions are how File.DropShadow draws
+type ShadowOptions struct {
+	OffsetX, OffsetY int32
+	Color            rl.Color
+	// Merged and PerTile are the same as for OutlineOptions
+	Merged, PerTile bool
+}
+
+// effectRegion is where an effect can draw. Pixels in different tiles don't
+// touch when the tile size is set
+type effectRegion struct {
+	width, height         int32
+	tileWidth, tileHeight int32
+}
+
+// effectRegion returns the region of the canvas, split into tiles if perTile
+// is set
+func (f *File) effectRegion(perTile bool) effectRegion {
+	region := effectRegion{width: f.CanvasWidth, height: f.CanvasHeight}
+	if perTile {
+		region.tileWidth, region.tileHeight = f.TileWidth, f.TileHeight
+	}
+	return region
+}
+
+// touches returns true if both pixels are on the canvas and in the same tile
+func (r effectRegion) touches(a, b IntVec2) bool {
+	for _, loc := range []IntVec2{a, b} {
+		if loc.X < 0 || loc.Y < 0 || loc.X >= r.width || loc.Y >= r.height {
+			return false
+		}
+	}
+	if r.tileWidth > 0 && r.tileHeight > 0 {
+		return a.X/r.tileWidth == b.X/r.tileWidth && a.Y/r.tileHeight == b.Y/r.tileHeight
+	}
+	return true
+}
+
+var (
+	// neighbours4 are the pixels which touch a pixel's sides
+	neighbours4 = []IntVec2{{-1, 0}, {1, 0}, {0, -1}, {0, 1}}
+	// neighbours8 also have the pixels which touch its corners
+	neighbours8 = []IntVec2{{-1, 0}, {1, 0}, {0, -1}, {0, 1}, {-1, -1}, {1, -1}, {-1, 1}, {1, 1}}
+)
+
+// outlinePixels returns the outline of the non-transparent pixels
+func outlinePixels(pixels map[IntVec2]rl.Color, options OutlineOptions, region effectRegion) map[IntVec2]rl.Color {
+	neighbours := neighbours4
+	if options.Diagonal {
+		neighbours = neighbours8
+	}
+
+	outline := make(map[IntVec2]rl.Color)
+	for loc, color := range pixels {
+		if color.A == 0 {
+			continue
+		}
+		for _, offset := range neighbours {
+			next := IntVec2{loc.X + offset.X, loc.Y + offset.Y}
+			touches := region.touches(loc, next)
+			if options.Inside {
+				// The edge of the canvas or the tile is an edge of the shape
+				if !touches || pixels[next].A == 0 {
+					outline[loc] = options.Color
+					break
+				}
+			} else if touches && pixels[next].A == 0 {
+				outline[next] = options.Color
+			}
+		}
+	}
+	return outline
+}
+
+// shadowPixels returns the shadow of the non-transparent pixels, where it
+// falls on transparent pixels
+func shadowPixels(pixels map[IntVec2]rl.Color, options ShadowOptions, region effectRegion) map[IntVec2]rl.Color {
+	shadow := make(map[IntVec2]rl.Color)
+	for loc, color := range pixels {
+		if color.A == 0 {
+			continue
+		}
+		to := IntVec2{loc.X + options.OffsetX, loc.Y + options.OffsetY}
+		if region.touches(loc, to) && pixels[to].A == 0 {
+			shadow[to] = options.Color
+		}
+	}
+	return shadow
+}
+
+// visiblePixels returns every visible layer blended together, only where
+// it's selected if there's a selection
+func (f *File) visiblePixels() map[IntVec2]rl.Color {
+	children := f.layerChildren()
+	pixels := make(map[IntVec2]rl.Color)
+	for y := int32(0); y < f.CanvasHeight; y++ {
+		for x := int32(0); x < f.CanvasWidth; x++ {
+			loc := IntVec2{x, y}
+			if _, ok := f.Selection[loc]; f.DoingSelection && !ok {
+				continue
+			}
+			pixels[loc] = compositePixel(children, nil, loc)
+		}
+	}
+	return pixels
+}
+
+// addEffectLayer adds a layer with the pixels to the top of the file
+func (f *File) addEffectLayer(name string, pixels map[IntVec2]rl.Color) {
+	if len(pixels) == 0 {
+		return
+	}
+	layer := NewLayer(f.CanvasWidth, f.CanvasHeight, name, rl.Blank, true)
+	for loc, color := range pixels {
+		layer.PixelData[loc] = color
+	}
+	layer.Redraw()
+
+	at := int32(len(f.Layers) - 1)
+	f.insertLayerBlock(at, nil, []*Layer{layer}, name)
+	f.SetCurrentLayer(at)
+}
+
+// drawEffect draws the pixels made by effect from the current layer, or from
+// the selection if there is one, onto it
+func (f *File) drawEffect(name string, effect func(pixels map[IntVec2]rl.Color) map[IntVec2]rl.Color) {
+	cl := f.GetCurrentLayer()
+	latestHistory := HistoryPixel{PixelState: make(map[IntVec2]PixelStateData), LayerIndex: f.CurrentLayer, Name: name}
+
+	// Change where the pixels are sampled from if there is a selection
+	pixelSource := cl.PixelData
+	if f.DoingSelection {
+		// The effect is drawn from the transformed pixels
+		f.EndSelectionTransform()
+		// latestHistory is essentially ignored and whatever is in the
+		// selection is accounted for by f.MoveSelection
+		pixelSource = f.Selection
+	} else {
+		f.AppendHistory(latestHistory)
+	}
+
+	for loc, color := range effect(pixelSource) {
+		// Pixels outside of the selection are left alone unless they're
+		// transparent
+		if _, ok := f.Selection[loc]; f.DoingSelection && !ok && cl.PixelData[loc].A > 0 {
+			continue
+		}
+
+		if f.DoingSelection {
+			f.Selection[loc] = color
+		} else {
+			latestHistory.PixelState[loc] = PixelStateData{Prev: cl.PixelData[loc], Current: color}
+			cl.PixelData[loc] = color
+		}
+	}
+
+	if f.DoingSelection && !f.SelectionMoving {
+		// Allow CommitSelection to detect a change
+		f.MoveSelection(0, 0)
+		f.CommitSelection()
+	}
+
+	cl.Redraw()
+	f.RedrawRenderLayer()
+}
+
+// Outline draws an outline around, or along the inside of, the
+// non-transparent pixels of the current layer or the selection. Merged
+// outlines are of every visible layer and go on a new layer
+func (f *File) Outline(options OutlineOptions) {
+	region := f.effectRegion(options.PerTile)
+	if options.Merged {
+		f.addEffectLayer("outline", outlinePixels(f.visiblePixels(), options, region))
+		return
+	}
+	f.drawEffect("Outline", func(pixels map[IntVec2]rl.Color) map[IntVec2]rl.Color {
+		return outlinePixels(pixels, options, region)
+	})
+}
+
+// DropShadow draws the shape of the non-transparent pixels of the current
+// layer or the selection, offset, where it falls on transparent pixels.
+// Merged shadows are of every visible layer and go on a new layer
+func (f *File) DropShadow(options ShadowOptions) {
+	region := f.effectRegion(options.PerTile)
+	if options.Merged {
+		f.addEffectLayer("drop shadow", shadowPixels(f.visiblePixels(), options, region))
+		return
+	}
+	f.drawEffect("Drop shadow", func(pixels map[IntVec2]rl.Color) map[IntVec2]rl.Color {
+		return shadowPixels(pixels, options, region)
+	})
+}
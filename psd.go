@@ -0,0 +1,324 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode/utf16"
+
+	rl "github.com/gen2brain/raylib-go/raylib"
+)
+
+// psdBlendModes maps photoshop's blend mode keys to the blend mode. Anything
+// else is drawn normally
+var psdBlendModes = map[string]rl.BlendMode{
+	"norm": rl.BlendAlpha,
+	"pass": rl.BlendAlpha,
+	"lddg": rl.BlendAddColors,
+	"mul ": rl.BlendMultiplied,
+	"fsub": rl.BlendSubtractColors,
+}
+
+// psdSection is the kind of layer, from the lsct block
+type psdSection uint32
+
+const (
+	psdSectionLayer psdSection = iota
+	psdSectionOpenFolder
+	psdSectionClosedFolder
+	psdSectionDivider // marks the bottom of a folder
+)
+
+// psdChannel is where a channel's image data is and what it's for
+type psdChannel struct {
+	ID     int16
+	Length uint32
+}
+
+// psdLayer is a layer record
+type psdLayer struct {
+	Top, Left, Bottom, Right int32
+	Channels                 []psdChannel
+	BlendKey                 string
+	Opacity                  uint8
+	Clipping                 bool
+	Hidden                   bool
+	Name                     string
+	Section                  psdSection
+}
+
+// psdReader reads big endian values, keeping the first error
+type psdReader struct {
+	r   *bufio.Reader
+	err error
+}
+
+func (p *psdReader) read(data interface{}) {
+	if p.err == nil {
+		p.err = binary.Read(p.r, binary.BigEndian, data)
+	}
+}
+
+func (p *psdReader) u8() (v uint8)   { p.read(&v); return }
+func (p *psdReader) u16() (v uint16) { p.read(&v); return }
+func (p *psdReader) i16() (v int16)  { p.read(&v); return }
+func (p *psdReader) u32() (v uint32) { p.read(&v); return }
+func (p *psdReader) i32() (v int32)  { p.read(&v); return }
+
+func (p *psdReader) bytes(n uint32) []byte {
+	b := make([]byte, n)
+	if p.err == nil {
+		_, p.err = io.ReadFull(p.r, b)
+	}
+	return b
+}
+
+func (p *psdReader) skip(n uint32) {
+	if p.err == nil {
+		_, p.err = p.r.Discard(int(n))
+	}
+}
+
+// readLayer reads a layer record
+func (p *psdReader) readLayer() psdLayer {
+	var l psdLayer
+	l.Top, l.Left, l.Bottom, l.Right = p.i32(), p.i32(), p.i32(), p.i32()
+	l.Channels = make([]psdChannel, p.u16())
+	for i := range l.Channels {
+		l.Channels[i] = psdChannel{ID: p.i16(), Length: p.u32()}
+	}
+	p.skip(4) // 8BIM
+	l.BlendKey = string(p.bytes(4))
+	l.Opacity = p.u8()
+	l.Clipping = p.u8() != 0
+	l.Hidden = p.u8()&2 != 0
+	p.skip(1)
+
+	extra := bytes.NewReader(p.bytes(p.u32()))
+	e := &psdReader{r: bufio.NewReader(extra)}
+	e.skip(e.u32()) // layer mask
+	e.skip(e.u32()) // blending ranges
+	nameLength := e.u8()
+	l.Name = string(e.bytes(uint32(nameLength)))
+	// The name is padded to a multiple of 4, including the length
+	e.skip((4 - (uint32(nameLength)+1)%4) % 4)
+
+	// Additional layer information
+	for e.err == nil {
+		e.skip(4) // 8BIM
+		key := string(e.bytes(4))
+		data := e.bytes(e.u32())
+		if e.err != nil {
+			break
+		}
+		switch key {
+		case "lsct":
+			if len(data) >= 4 {
+				l.Section = psdSection(binary.BigEndian.Uint32(data))
+			}
+		case "luni":
+			if len(data) >= 4 {
+				n := binary.BigEndian.Uint32(data)
+				chars := make([]uint16, 0, n)
+				for i := uint32(0); i < n && int(6+i*2) <= len(data); i++ {
+					chars = append(chars, binary.BigEndian.Uint16(data[4+i*2:]))
+				}
+				l.Name = strings.TrimRight(string(utf16.Decode(chars)), "\x00")
+			}
+		}
+	}
+	return l
+}
+
+// decodeChannel decodes a raw or packbits compressed channel
+func decodeChannel(data []byte, width, height int) ([]byte, error) {
+	if len(data) < 2 {
+		return nil, fmt.Errorf("channel is too short")
+	}
+	compression := binary.BigEndian.Uint16(data)
+	data = data[2:]
+
+	switch compression {
+	case 0:
+		if len(data) < width*height {
+			return nil, fmt.Errorf("channel is too short")
+		}
+		return data[:width*height], nil
+	case 1:
+		// The byte counts of each row come first
+		if len(data) < height*2 {
+			return nil, fmt.Errorf("channel is too short")
+		}
+		data = data[height*2:]
+		out := make([]byte, 0, width*height)
+		for len(data) > 0 && len(out) < width*height {
+			n := int(int8(data[0]))
+			data = data[1:]
+			switch {
+			case n >= 0:
+				if len(data) < n+1 {
+					return nil, fmt.Errorf("channel is too short")
+				}
+				out = append(out, data[:n+1]...)
+				data = data[n+1:]
+			case n > -128:
+				if len(data) < 1 {
+					return nil, fmt.Errorf("channel is too short")
+				}
+				out = append(out, bytes.Repeat(data[:1], 1-n)...)
+				data = data[1:]
+			}
+		}
+		if len(out) < width*height {
+			return nil, fmt.Errorf("channel is too short")
+		}
+		return out[:width*height], nil
+	}
+	return nil, fmt.Errorf("compression %d not supported", compression)
+}
+
+// openPSD opens an 8 bit RGB photoshop file. Raster layers and folders are
+// kept with their names, visibility and blend modes. Layer opacity is applied
+// to the pixels since layers don't have an opacity of their own, folder
+// opacity is dropped
+func openPSD(openPath string) (*File, error) {
+	reader, err := os.Open(openPath)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	p := &psdReader{r: bufio.NewReader(reader)}
+	fail := func(reason string) (*File, error) {
+		return nil, fmt.Errorf("Can't open %s: %s", openPath, reason)
+	}
+
+	if string(p.bytes(4)) != "8BPS" || p.u16() != 1 {
+		return fail("not a photoshop file")
+	}
+	p.skip(6)
+	p.u16() // channels
+	height, width := p.u32(), p.u32()
+	depth, mode := p.u16(), p.u16()
+	if p.err != nil {
+		return fail(p.err.Error())
+	}
+	if depth != 8 || mode != 3 {
+		return fail("only 8 bit RGB is supported")
+	}
+
+	p.skip(p.u32()) // color mode data
+	p.skip(p.u32()) // image resources
+	p.u32()         // layer and mask information length
+	p.u32()         // layer info length
+	count := p.i16()
+	if count < 0 {
+		// The first alpha channel is the transparency of the merged image
+		count = -count
+	}
+
+	records := make([]psdLayer, count)
+	for i := range records {
+		records[i] = p.readLayer()
+	}
+	if p.err != nil {
+		return fail(p.err.Error())
+	}
+
+	// Records and their image data go from the bottom to the top, with a
+	// divider below each folder's children and the folder above them
+	layers := make([]*Layer, 0, count)
+	groups := make([]*Layer, 0)
+	parent := func() *Layer {
+		if len(groups) == 0 {
+			return nil
+		}
+		return groups[len(groups)-1]
+	}
+	for _, record := range records {
+		channels := make(map[int16][]byte)
+		w, h := int(record.Right-record.Left), int(record.Bottom-record.Top)
+		for _, channel := range record.Channels {
+			data := p.bytes(channel.Length)
+			if p.err != nil {
+				return fail(p.err.Error())
+			}
+			// Masks are a different size and aren't used
+			if channel.ID < -1 || w <= 0 || h <= 0 {
+				continue
+			}
+			if channels[channel.ID], err = decodeChannel(data, w, h); err != nil {
+				return fail(fmt.Sprintf("layer \"%s\": %s", record.Name, err))
+			}
+		}
+
+		blendMode, ok := psdBlendModes[record.BlendKey]
+		if !ok {
+			log.Printf("Layer \"%s\" uses blend mode \"%s\" which isn't supported, it's drawn normally\n", record.Name, record.BlendKey)
+			blendMode = rl.BlendAlpha
+		}
+
+		switch record.Section {
+		case psdSectionDivider:
+			group := NewLayer(int32(width), int32(height), "group", rl.Blank, true)
+			group.IsGroup = true
+			group.Parent = parent()
+			groups = append(groups, group)
+			continue
+		case psdSectionOpenFolder, psdSectionClosedFolder:
+			if len(groups) == 0 {
+				return fail("folder without a divider")
+			}
+			group := parent()
+			groups = groups[:len(groups)-1]
+			group.Name = record.Name
+			group.Hidden = record.Hidden
+			group.BlendMode = blendMode
+			group.Collapsed = record.Section == psdSectionClosedFolder
+			if record.Opacity < 255 {
+				log.Printf("Folder \"%s\" has an opacity which isn't supported, it's drawn opaque\n", record.Name)
+			}
+			layers = append(layers, group)
+			continue
+		}
+
+		layer := NewLayer(int32(width), int32(height), record.Name, rl.Blank, true)
+		layer.Hidden = record.Hidden
+		layer.BlendMode = blendMode
+		layer.Clipping = record.Clipping
+		layer.Parent = parent()
+		r, g, b, a := channels[0], channels[1], channels[2], channels[-1]
+		for i := 0; i < w*h && r != nil && g != nil && b != nil; i++ {
+			loc := IntVec2{record.Left + int32(i%w), record.Top + int32(i/w)}
+			if loc.X < 0 || loc.Y < 0 || loc.X >= int32(width) || loc.Y >= int32(height) {
+				continue
+			}
+			alpha := uint8(255)
+			if a != nil {
+				alpha = a[i]
+			}
+			alpha = scaleAlpha(alpha, record.Opacity)
+			if alpha > 0 {
+				layer.PixelData[loc] = rl.NewColor(r[i], g[i], b[i], alpha)
+			}
+		}
+		layers = append(layers, layer)
+	}
+	if len(groups) > 0 {
+		return fail("divider without a folder")
+	}
+	if len(layers) == 0 {
+		return fail("file has no layers")
+	}
+
+	f := newFileFromLayers(int32(width), int32(height), layers)
+	f.PathDir = filepath.Dir(openPath)
+	f.Filename = filepath.Base(openPath)
+	return f, nil
+}
@@ -0,0 +1,453 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+
+	rl "github.com/gen2brain/raylib-go/raylib"
+)
+
+// SelectOp is how a new selection is combined with the existing one
+type SelectOp int32
+
+// Select ops
+const (
+	SelectOpReplace SelectOp = iota
+	SelectOpAdd
+	SelectOpSubtract
+	// SelectOpIntersect keeps only what's in both
+	SelectOpIntersect
+)
+
+// SelectorMode is the kind of selection made by the selector tool button
+type SelectorMode int32
+
+// Selector modes
+const (
+	// SelectorModeRect drags out a rectangle
+	SelectorModeRect SelectorMode = iota
+	// SelectorModeWand selects pixels of the same color
+	SelectorModeWand
+	// SelectorModeLasso selects inside a line drawn freehand
+	SelectorModeLasso
+	// SelectorModePolygon selects inside straight lines between clicks
+	SelectorModePolygon
+)
+
+func (m SelectorMode) String() string {
+	switch m {
+	case SelectorModeRect:
+		return "rect"
+	case SelectorModeWand:
+		return "wand"
+	case SelectorModeLasso:
+		return "lasso"
+	case SelectorModePolygon:
+		return "polygon"
+	}
+	return "unknown"
+}
+
+// NewSelectionTool returns the tool for the selector mode
+func NewSelectionTool(mode SelectorMode) Tool {
+	switch mode {
+	case SelectorModeWand:
+		return NewMagicWandTool("Magic Wand")
+	case SelectorModeLasso:
+		return NewLassoTool("Lasso")
+	case SelectorModePolygon:
+		return NewPolygonTool("Polygon Selector")
+	}
+	return NewSelectorTool("Selector")
+}
+
+// IsSelectionTool returns true if the tool makes selections, which the arrow
+// keys move instead of the cursor
+func IsSelectionTool(tool Tool) bool {
+	switch tool.(type) {
+	case *SelectorTool, *MagicWandTool, *LassoTool, *PolygonTool:
+		return true
+	}
+	return false
+}
+
+// isBindingDown returns true if all of the keys of one of the bindings for the
+// keymap action are held
+func isBindingDown(name string) bool {
+	for _, keys := range Settings.KeymapData[name] {
+		allDown := true
+		for _, key := range keys {
+			if !rl.IsKeyDown(int32(key)) {
+				allDown = false
+			}
+		}
+
+		if allDown {
+			return true
+		}
+	}
+	return false
+}
+
+// CurrentSelectOp returns the op chosen by the modifier keys being held
+func CurrentSelectOp() SelectOp {
+	switch {
+	case isBindingDown("selectionIntersect"):
+		return SelectOpIntersect
+	case isBindingDown("selectionSubtract"):
+		return SelectOpSubtract
+	case isBindingDown("selectionAdd"):
+		return SelectOpAdd
+	}
+	return SelectOpReplace
+}
+
+// SelectPixels selects the pixels at locs on the current layer, combining
+// them with the existing selection. A moving selection is committed first
+func (f *File) SelectPixels(locs map[IntVec2]bool, op SelectOp) {
+	selected := make(map[IntVec2]bool, len(locs))
+	if op != SelectOpReplace {
+		for loc := range f.Selection {
+			selected[loc] = true
+		}
+	}
+	f.CommitSelection()
+
+	switch op {
+	case SelectOpSubtract:
+		for loc := range locs {
+			delete(selected, loc)
+		}
+	case SelectOpIntersect:
+		for loc := range selected {
+			if !locs[loc] {
+				delete(selected, loc)
+			}
+		}
+	default:
+		for loc := range locs {
+			if loc.X >= 0 && loc.X < f.CanvasWidth && loc.Y >= 0 && loc.Y < f.CanvasHeight {
+				selected[loc] = true
+			}
+		}
+	}
+	if len(selected) == 0 {
+		return
+	}
+
+	cl := f.GetCurrentLayer()
+	bounds := [4]int32{f.CanvasWidth, f.CanvasHeight, -1, -1}
+	for loc := range selected {
+		bounds[0] = MinInt32(bounds[0], loc.X)
+		bounds[1] = MinInt32(bounds[1], loc.Y)
+		bounds[2] = MaxInt32(bounds[2], loc.X)
+		bounds[3] = MaxInt32(bounds[3], loc.Y)
+		f.Selection[loc] = cl.PixelData[loc]
+	}
+
+	// Pixels are ordered across the bounds, with unselected ones left blank
+	for y := bounds[1]; y <= bounds[3]; y++ {
+		for x := bounds[0]; x <= bounds[2]; x++ {
+			f.SelectionPixels = append(f.SelectionPixels, f.Selection[IntVec2{x, y}])
+		}
+	}
+
+	f.SelectionBounds = bounds
+	f.OrigSelectionBounds = bounds
+	f.DoingSelection = true
+}
+
+// IsSelectionRect returns true if the selection fills its bounds
+func (f *File) IsSelectionRect() bool {
+	width := f.SelectionBounds[2] - f.SelectionBounds[0] + 1
+	height := f.SelectionBounds[3] - f.SelectionBounds[1] + 1
+	return int(width*height) == len(f.Selection)
+}
+
+// WandArea is which pixels the magic wand can reach
+type WandArea int32
+
+// Wand areas
+const (
+	// WandArea4Way selects pixels touching on a side
+	WandArea4Way WandArea = iota
+	// WandArea8Way also selects pixels touching on a corner
+	WandArea8Way
+	// WandAreaGlobal selects matching pixels anywhere on the canvas
+	WandAreaGlobal
+)
+
+func (a WandArea) String() string {
+	switch a {
+	case WandArea4Way:
+		return "4-way"
+	case WandArea8Way:
+		return "8-way"
+	case WandAreaGlobal:
+		return "global"
+	}
+	return "unknown"
+}
+
+// colorsWithin returns true if no channel differs by more than tolerance
+func colorsWithin(a, b rl.Color, tolerance int32) bool {
+	within := func(x, y uint8) bool {
+		d := int32(x) - int32(y)
+		return d <= tolerance && -d <= tolerance
+	}
+	return within(a.R, b.R) && within(a.G, b.G) && within(a.B, b.B) && within(a.A, b.A)
+}
+
+// WandRegion returns the pixels matching the color at start. Colors are taken
+// from the current layer, or from all visible layers if sampleMerged is true
+func (f *File) WandRegion(start IntVec2, tolerance int32, area WandArea, sampleMerged bool) map[IntVec2]bool {
+	region := make(map[IntVec2]bool)
+	inCanvas := func(loc IntVec2) bool {
+		return loc.X >= 0 && loc.X < f.CanvasWidth && loc.Y >= 0 && loc.Y < f.CanvasHeight
+	}
+	if !inCanvas(start) {
+		return region
+	}
+
+	cl := f.GetCurrentLayer()
+	children := f.layerChildren()
+	colorAt := func(loc IntVec2) rl.Color {
+		if sampleMerged {
+			return compositePixel(children, nil, loc)
+		}
+		return cl.PixelData[loc]
+	}
+	target := colorAt(start)
+
+	if area == WandAreaGlobal {
+		for y := int32(0); y < f.CanvasHeight; y++ {
+			for x := int32(0); x < f.CanvasWidth; x++ {
+				if colorsWithin(colorAt(IntVec2{x, y}), target, tolerance) {
+					region[IntVec2{x, y}] = true
+				}
+			}
+		}
+		return region
+	}
+
+	neighbours := []IntVec2{{1, 0}, {-1, 0}, {0, 1}, {0, -1}}
+	if area == WandArea8Way {
+		neighbours = append(neighbours, IntVec2{1, 1}, IntVec2{-1, 1}, IntVec2{1, -1}, IntVec2{-1, -1})
+	}
+
+	visited := map[IntVec2]bool{start: true}
+	stack := []IntVec2{start}
+	for len(stack) > 0 {
+		loc := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if !colorsWithin(colorAt(loc), target, tolerance) {
+			continue
+		}
+		region[loc] = true
+
+		for _, n := range neighbours {
+			next := IntVec2{loc.X + n.X, loc.Y + n.Y}
+			if inCanvas(next) && !visited[next] {
+				visited[next] = true
+				stack = append(stack, next)
+			}
+		}
+	}
+	return region
+}
+
+// PolygonPixels returns the pixels inside the polygon going through the
+// middle of each point, including the pixels its edges go through
+func PolygonPixels(points []IntVec2) map[IntVec2]bool {
+	pixels := make(map[IntVec2]bool)
+	if len(points) == 0 {
+		return pixels
+	}
+
+	minY, maxY := points[0].Y, points[0].Y
+	for i, a := range points {
+		b := points[(i+1)%len(points)]
+		Line(a.X, a.Y, b.X, b.Y, func(x, y int32) {
+			pixels[IntVec2{x, y}] = true
+		})
+		minY = MinInt32(minY, a.Y)
+		maxY = MaxInt32(maxY, a.Y)
+	}
+
+	// Fill between pairs of edge crossings on each row
+	for y := minY; y <= maxY; y++ {
+		crossings := make([]float64, 0, 4)
+		for i, a := range points {
+			b := points[(i+1)%len(points)]
+			if (a.Y <= y) != (b.Y <= y) {
+				crossings = append(crossings, float64(a.X)+float64(y-a.Y)*float64(b.X-a.X)/float64(b.Y-a.Y))
+			}
+		}
+		sort.Float64s(crossings)
+		for i := 0; i+1 < len(crossings); i += 2 {
+			for x := int32(math.Ceil(crossings[i])); x <= int32(math.Floor(crossings[i+1])); x++ {
+				pixels[IntVec2{x, y}] = true
+			}
+		}
+	}
+	return pixels
+}
+
+// pixelCenterOnScreen returns where the middle of the pixel is on the screen
+func pixelCenterOnScreen(loc IntVec2, camera rl.Camera2D) rl.Vector2 {
+	return rl.GetWorldToScreen2D(rl.NewVector2(
+		float32(loc.X)+0.5-float32(CurrentFile.CanvasWidth)/2,
+		float32(loc.Y)+0.5-float32(CurrentFile.CanvasHeight)/2,
+	), camera)
+}
+
+// drawPolyline draws lines between the middles of the pixels. It's drawn in
+// screen space
+func drawPolyline(points []IntVec2, camera rl.Camera2D) {
+	for i := 1; i < len(points); i++ {
+		a := pixelCenterOnScreen(points[i-1], camera)
+		b := pixelCenterOnScreen(points[i], camera)
+		rl.DrawLineEx(a, b, 3, rl.Black)
+		rl.DrawLineEx(a, b, 1, rl.White)
+	}
+}
+
+// drawSelectionOutline draws a line around the edges of the selected pixels.
+// It's drawn in screen space
+func drawSelectionOutline(camera rl.Camera2D, color rl.Color) {
+	p := camera.Zoom // pixel size
+	for loc := range CurrentFile.Selection {
+		pos := rl.GetWorldToScreen2D(rl.NewVector2(
+			float32(loc.X)-float32(CurrentFile.CanvasWidth)/2,
+			float32(loc.Y)-float32(CurrentFile.CanvasHeight)/2,
+		), camera)
+
+		if _, ok := CurrentFile.Selection[IntVec2{loc.X - 1, loc.Y}]; !ok {
+			rl.DrawLineEx(pos, rl.NewVector2(pos.X, pos.Y+p), 2, color)
+		}
+		if _, ok := CurrentFile.Selection[IntVec2{loc.X + 1, loc.Y}]; !ok {
+			rl.DrawLineEx(rl.NewVector2(pos.X+p, pos.Y), rl.NewVector2(pos.X+p, pos.Y+p), 2, color)
+		}
+		if _, ok := CurrentFile.Selection[IntVec2{loc.X, loc.Y - 1}]; !ok {
+			rl.DrawLineEx(pos, rl.NewVector2(pos.X+p, pos.Y), 2, color)
+		}
+		if _, ok := CurrentFile.Selection[IntVec2{loc.X, loc.Y + 1}]; !ok {
+			rl.DrawLineEx(rl.NewVector2(pos.X, pos.Y+p), rl.NewVector2(pos.X+p, pos.Y+p), 2, color)
+		}
+	}
+}
+
+// InSelection returns true if the pixel can be painted. While something is
+// selected, only the selected pixels can be painted
+func (f *File) InSelection(loc IntVec2) bool {
+	if !f.DoingSelection || len(f.Selection) == 0 {
+		return true
+	}
+	_, ok := f.Selection[loc]
+	return ok
+}
+
+// syncSelectedPixel keeps the selection's copy of a pixel the same as the
+// layer after it's painted, so moving the selection later lifts the new color
+func (f *File) syncSelectedPixel(loc IntVec2, color rl.Color) {
+	if f.SelectionMoving {
+		return
+	}
+	if _, ok := f.Selection[loc]; !ok {
+		return
+	}
+	f.Selection[loc] = color
+
+	width := f.SelectionBounds[2] - f.SelectionBounds[0] + 1
+	i := int((loc.Y-f.SelectionBounds[1])*width + loc.X - f.SelectionBounds[0])
+	if i >= 0 && i < len(f.SelectionPixels) {
+		f.SelectionPixels[i] = color
+	}
+}
+
+// PlaceSelection stamps a moving selection but keeps the same pixels
+// selected, so that painting tools can use it as a mask
+func (f *File) PlaceSelection() {
+	if !f.SelectionMoving {
+		return
+	}
+	locs := make(map[IntVec2]bool, len(f.Selection))
+	for loc := range f.Selection {
+		locs[loc] = true
+	}
+	f.SelectPixels(locs, SelectOpReplace)
+}
+
+// SelectInverse selects every pixel on the canvas which isn't selected
+func (f *File) SelectInverse() {
+	locs := make(map[IntVec2]bool)
+	for y := int32(0); y < f.CanvasHeight; y++ {
+		for x := int32(0); x < f.CanvasWidth; x++ {
+			if _, ok := f.Selection[IntVec2{x, y}]; !ok || !f.DoingSelection {
+				locs[IntVec2{x, y}] = true
+			}
+		}
+	}
+	if len(locs) == 0 {
+		f.CommitSelection()
+		return
+	}
+	f.SelectPixels(locs, SelectOpReplace)
+}
+
+// SelectionMask is a saved selection which can be selected again later
+type SelectionMask struct {
+	Name   string
+	Pixels map[IntVec2]bool
+}
+
+// SaveSelectionMask saves the selected pixels as a mask, replacing any mask
+// with the same name
+func (f *File) SaveSelectionMask(name string) error {
+	if !f.DoingSelection || len(f.Selection) == 0 {
+		return fmt.Errorf("Nothing is selected")
+	}
+	if name == "" {
+		return fmt.Errorf("The mask needs a name")
+	}
+	mask := SelectionMask{Name: name, Pixels: make(map[IntVec2]bool, len(f.Selection))}
+	for loc := range f.Selection {
+		mask.Pixels[loc] = true
+	}
+
+	// The masks are copied since history can refer to them
+	f.markChanged()
+	masks := append([]SelectionMask(nil), f.SelectionMasks...)
+	for i := range masks {
+		if masks[i].Name == name {
+			masks[i] = mask
+			f.SelectionMasks = masks
+			return nil
+		}
+	}
+	f.SelectionMasks = append(masks, mask)
+	return nil
+}
+
+// LoadSelectionMask selects the pixels in the mask, combined with the selection by op
+func (f *File) LoadSelectionMask(index int, op SelectOp) {
+	if index < 0 || index >= len(f.SelectionMasks) {
+		return
+	}
+	locs := make(map[IntVec2]bool, len(f.SelectionMasks[index].Pixels))
+	for loc := range f.SelectionMasks[index].Pixels {
+		locs[loc] = true
+	}
+	f.SelectPixels(locs, op)
+}
+
+// DeleteSelectionMask removes the mask
+func (f *File) DeleteSelectionMask(index int) {
+	if index < 0 || index >= len(f.SelectionMasks) {
+		return
+	}
+	masks := append([]SelectionMask(nil), f.SelectionMasks[:index]...)
+	f.SelectionMasks = append(masks, f.SelectionMasks[index+1:]...)
+	f.markChanged()
+}
@@ -6,6 +6,7 @@ import (
 	"log"
 	"os"
 	"path"
+	"sort"
 
 	rl "github.com/gen2brain/raylib-go/raylib"
 )
@@ -14,6 +15,34 @@ import (
 type SettingsData struct {
 	KeymapData  KeymapData  `binding:"required"`
 	PaletteData PaletteData `binding:"required"`
+	HistoryData HistoryData
+	ViewData    ViewData
+}
+
+// ViewData configures how the canvas is shown
+type ViewData struct {
+	// ZoomSteps are the zoom levels which scrolling moves between, where 1 is
+	// 100%
+	ZoomSteps []float32
+	// PixelGrid draws a line between every pixel once the canvas is zoomed in
+	// to at least PixelGridMinZoom
+	PixelGrid        bool
+	PixelGridMinZoom float32
+	PixelGridColor   string
+	// Rulers show pixel coordinates along the top and left of the canvas
+	Rulers bool
+	// LimitPan stops the canvas from being moved off the screen
+	LimitPan bool
+	// Snap moves selections onto guides and grid lines within SnapDistance
+	// screen pixels
+	Snap         bool
+	SnapDistance float32
+}
+
+// HistoryData limits how much undo history each file keeps
+type HistoryData struct {
+	MaxActions  int32
+	MaxMemoryMB int32
 }
 
 // KeymapData stores the action name as the key and a 2d slice of the keys
@@ -79,14 +108,45 @@ var (
 	// Settings is the global settings object
 	Settings *SettingsData
 
+	defaultHistory = HistoryData{
+		MaxActions:  500,
+		MaxMemoryMB: 64,
+	}
+
+	defaultView = ViewData{
+		ZoomSteps:        []float32{0.25, 0.5, 1, 2, 3, 4, 6, 8, 12, 16, 24, 32, 48, 64},
+		PixelGrid:        true,
+		PixelGridMinZoom: 8,
+		PixelGridColor:   "ffffff30",
+		Rulers:           true,
+		LimitPan:         true,
+		Snap:             true,
+		SnapDistance:     8,
+	}
+
 	defaultKeymap = KeymapData{
 		// Handled by tools
-		"drawLine": {{rl.KeyLeftShift}, {rl.KeyRightShift}},
+		"drawLine":           {{rl.KeyLeftShift}, {rl.KeyRightShift}},
+		"selectionAdd":       {{rl.KeyLeftShift}, {rl.KeyRightShift}},
+		"selectionSubtract":  {{rl.KeyLeftAlt}, {rl.KeyRightAlt}},
+		"selectionIntersect": {{rl.KeyLeftShift, rl.KeyLeftAlt}, {rl.KeyRightShift, rl.KeyRightAlt}},
+		"transformLockRatio": {{rl.KeyLeftShift}, {rl.KeyRightShift}},
+		"swapTiles":          {{rl.KeyLeftControl}, {rl.KeyRightControl}},
 
 		// Handled by system controls
-		"toggleGrid": {{rl.KeyG}},
-		"showDebug":  {{rl.KeyD}},
-		"resize":     {{rl.KeyLeftControl, rl.KeyR}},
+		"toggleGrid":  {{rl.KeyG}},
+		"pixelGrid":   {{rl.KeyLeftShift, rl.KeyG}},
+		"rulers":      {{rl.KeyLeftControl, rl.KeyLeftShift, rl.KeyR}},
+		"snap":        {{rl.KeyLeftShift, rl.KeyS}},
+		"clearGuides": {{rl.KeyLeftControl, rl.KeyLeftShift, rl.KeyG}},
+		"gridOptions": {{rl.KeyLeftControl, rl.KeyG}},
+		"zoomIn":      {{rl.KeyLeftControl, rl.KeyEqual}},
+		"zoomOut":     {{rl.KeyLeftControl, rl.KeyMinus}},
+		"zoomFit":     {{rl.KeyLeftControl, rl.KeyZero}},
+		"zoomActual":  {{rl.KeyLeftControl, rl.KeyOne}},
+		"showDebug":   {{rl.KeyD}},
+		"resize":      {{rl.KeyLeftControl, rl.KeyR}},
+		"transform":   {{rl.KeyLeftControl, rl.KeyT}},
 
 		"pixelBrush": {{rl.KeyB}},
 		"eraser":     {{rl.KeyE}},
@@ -108,20 +168,24 @@ var (
 		"toolUp":    {{rl.KeyC}, {rl.KeyUp}},
 		"toolDown":  {{rl.KeyT}, {rl.KeyDown}},
 
-		"cancel":    {{rl.KeyEscape}},
-		"copy":      {{rl.KeyLeftControl, rl.KeyC}},
-		"paste":     {{rl.KeyLeftControl, rl.KeyV}},
-		"delete":    {{rl.KeyDelete}},
-		"selectAll": {{rl.KeyLeftControl, rl.KeyA}},
-
-		"new":    {{rl.KeyLeftControl, rl.KeyN}},
-		"open":   {{rl.KeyLeftControl, rl.KeyO}},
-		"close":  {{rl.KeyLeftControl, rl.KeyW}},
-		"save":   {{rl.KeyLeftControl, rl.KeyS}},
-		"saveAs": {{rl.KeyLeftControl, rl.KeyLeftShift, rl.KeyS}},
-		"export": {{rl.KeyLeftControl, rl.KeyE}},
-		"undo":   {{rl.KeyLeftControl, rl.KeyZ}},
-		"redo":   {{rl.KeyLeftControl, rl.KeyLeftShift, rl.KeyZ}, {rl.KeyLeftControl, rl.KeyY}},
+		"cancel":        {{rl.KeyEscape}},
+		"copy":          {{rl.KeyLeftControl, rl.KeyC}},
+		"paste":         {{rl.KeyLeftControl, rl.KeyV}},
+		"delete":        {{rl.KeyDelete}},
+		"selectAll":     {{rl.KeyLeftControl, rl.KeyA}},
+		"selectInverse": {{rl.KeyLeftControl, rl.KeyLeftShift, rl.KeyI}},
+
+		"new":      {{rl.KeyLeftControl, rl.KeyN}},
+		"open":     {{rl.KeyLeftControl, rl.KeyO}},
+		"close":    {{rl.KeyLeftControl, rl.KeyW}},
+		"save":     {{rl.KeyLeftControl, rl.KeyS}},
+		"saveAs":   {{rl.KeyLeftControl, rl.KeyLeftShift, rl.KeyS}},
+		"saveAll":  {{rl.KeyLeftControl, rl.KeyLeftAlt, rl.KeyS}},
+		"export":   {{rl.KeyLeftControl, rl.KeyE}},
+		"reExport": {{rl.KeyLeftControl, rl.KeyLeftShift, rl.KeyE}},
+		"import":   {{rl.KeyLeftControl, rl.KeyI}},
+		"undo":     {{rl.KeyLeftControl, rl.KeyZ}},
+		"redo":     {{rl.KeyLeftControl, rl.KeyLeftShift, rl.KeyZ}, {rl.KeyLeftControl, rl.KeyY}},
 	}
 
 	// Using the Lospec500 palette as default
@@ -225,6 +289,8 @@ func LoadSettings() error {
 		// Make a default settings file using the default data
 		Settings.KeymapData = defaultKeymap
 		Settings.PaletteData = defaultPalettes
+		Settings.HistoryData = defaultHistory
+		Settings.ViewData = defaultView
 		for _, color := range Settings.PaletteData[0].Strings {
 			parsedColor, err := HexToColor(color)
 			if err != nil {
@@ -260,6 +326,37 @@ func LoadSettings() error {
 			Settings.PaletteData = defaultPalettes
 			log.Println("🎨 Palettes were missing from settings, default added")
 		}
+		if history := Settings.HistoryData; history.MaxActions <= 0 || history.MaxMemoryMB <= 0 {
+			Settings.HistoryData = defaultHistory
+			log.Println("⏪ History limits were missing from settings, default added")
+		}
+		if view := Settings.ViewData; len(view.ZoomSteps) == 0 {
+			Settings.ViewData = defaultView
+			log.Println("🔍 View settings were missing from settings, default added")
+		}
+		if Settings.ViewData.SnapDistance <= 0 {
+			Settings.ViewData.SnapDistance = defaultView.SnapDistance
+		}
+		// Zooming to 0 or below would hide the canvas
+		zoomSteps := Settings.ViewData.ZoomSteps[:0]
+		for _, step := range Settings.ViewData.ZoomSteps {
+			if step > 0 {
+				zoomSteps = append(zoomSteps, step)
+			}
+		}
+		if len(zoomSteps) == 0 {
+			zoomSteps = append(zoomSteps, defaultView.ZoomSteps...)
+		}
+		Settings.ViewData.ZoomSteps = zoomSteps
+		sort.Slice(Settings.ViewData.ZoomSteps, func(i, j int) bool {
+			return Settings.ViewData.ZoomSteps[i] < Settings.ViewData.ZoomSteps[j]
+		})
+		// Bindings added since the settings were written
+		for name, keys := range defaultKeymap {
+			if _, ok := Settings.KeymapData[name]; !ok {
+				Settings.KeymapData[name] = keys
+			}
+		}
 		// Convert hex to rl.Color
 		for pi, palette := range Settings.PaletteData {
 			palette.data = make([]rl.Color, 0)
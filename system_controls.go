@@ -47,6 +47,8 @@ type CommandType int
 const (
 	CommandTypeOpen CommandType = iota
 	CommandTypeSave
+	CommandTypeExport
+	CommandTypeImport
 	CommandTypeFail
 	CommandTypeQuit
 )
@@ -74,8 +76,8 @@ func NewUIControlSystem(keymap Keymap) *UIControlSystem {
 						zenity.Filename(CurrentFile.PathDir),
 						zenity.FileFilters{
 							{
-								Name:     ".png, .pix",
-								Patterns: []string{"*.png", "*.pix"},
+								Name:     ".png, .pix, .ora, .psd",
+								Patterns: []string{"*.png", "*.pix", "*.ora", "*.psd"},
 								CaseFold: true},
 						})
 
@@ -96,10 +98,6 @@ func NewUIControlSystem(keymap Keymap) *UIControlSystem {
 						zenity.Title("Save File"),
 						zenity.Filename(CurrentFile.PathDir),
 						zenity.FileFilters{
-							{
-								Name:     ".png",
-								Patterns: []string{"*.png"},
-								CaseFold: true},
 							{
 								Name:     ".pix",
 								Patterns: []string{"*.pix"},
@@ -113,6 +111,47 @@ func NewUIControlSystem(keymap Keymap) *UIControlSystem {
 						log.Println("Saved file: ", name)
 						returns <- UIControlChanData{CommandType: CommandTypeSave, Name: name}
 					}
+
+				case CommandTypeExport:
+					name, err := zenity.SelectFileSave(
+						zenity.Title("Export File"),
+						zenity.Filename(CurrentFile.PathDir),
+						zenity.ConfirmOverwrite(),
+						zenity.FileFilters{
+							{
+								Name:     ".png",
+								Patterns: []string{"*.png"},
+								CaseFold: true},
+							{
+								Name:     ".ora",
+								Patterns: []string{"*.ora"},
+								CaseFold: true},
+						})
+
+					if err != nil {
+						log.Println(err)
+						returns <- UIControlChanData{CommandType: CommandTypeFail}
+					} else {
+						returns <- UIControlChanData{CommandType: CommandTypeExport, Name: name}
+					}
+
+				case CommandTypeImport:
+					name, err := zenity.SelectFile(
+						zenity.Title("Import File"),
+						zenity.Filename(CurrentFile.PathDir),
+						zenity.FileFilters{
+							{
+								Name:     ".png",
+								Patterns: []string{"*.png"},
+								CaseFold: true},
+						})
+
+					if err != nil {
+						log.Println(err)
+						returns <- UIControlChanData{CommandType: CommandTypeFail}
+					} else {
+						returns <- UIControlChanData{CommandType: CommandTypeImport, Name: name}
+					}
 				}
 			default:
 				time.Sleep(time.Millisecond * 100)
@@ -240,12 +279,16 @@ func UINew() {
 	EditorsUIRebuild()
 }
 
-// UIClose closes a file
+// UIClose closes a file, asking to save it first if it has unsaved changes
 func UIClose() {
 	if len(Files) > 1 {
-		CurrentFile.Destroy()
-		CurrentFile = Files[len(Files)-1]
-		EditorsUIRebuild()
+		file := CurrentFile
+		UnsavedUIConfirm([]*File{file}, func() {
+			file.RemoveAutosave()
+			file.Destroy()
+			CurrentFile = Files[len(Files)-1]
+			EditorsUIRebuild()
+		})
 	}
 }
 
@@ -259,6 +302,18 @@ func UISaveAs() {
 	UIControlSystemCmds <- UIControlChanData{CommandType: CommandTypeSave}
 }
 
+// UISave saves the file to where it was last saved or opened from, asking
+// where to save it if it hasn't been saved yet
+func UISave() {
+	saveFile(CurrentFile, func(saved bool) {})
+}
+
+// UIShowError logs the error and shows it in a dialog without blocking
+func UIShowError(err error) {
+	log.Println(err)
+	go zenity.Error(err.Error(), zenity.Title("Error"))
+}
+
 // HandleKeyboardEvents handles keyboard events
 func (s *UIControlSystem) HandleKeyboardEvents() {
 	// Handle keyboard events
@@ -323,6 +378,9 @@ func (s *UIControlSystem) HandleKeyboardEvents() {
 					// Escape from text entry
 					// TODO
 				} else {
+					if polygon, ok := LeftTool.(*PolygonTool); ok {
+						polygon.Cancel()
+					}
 					if CurrentFile.DoingSelection {
 						// CurrentFile.CancelSelection()
 						CurrentFile.CommitSelection()
@@ -359,6 +417,27 @@ func (s *UIControlSystem) HandleKeyboardEvents() {
 			switch key {
 			case "toggleGrid":
 				CurrentFile.DrawGrid = !CurrentFile.DrawGrid
+			case "pixelGrid":
+				Settings.ViewData.PixelGrid = !Settings.ViewData.PixelGrid
+				SaveSettings()
+			case "rulers":
+				Settings.ViewData.Rulers = !Settings.ViewData.Rulers
+				SaveSettings()
+			case "snap":
+				Settings.ViewData.Snap = !Settings.ViewData.Snap
+				SaveSettings()
+			case "clearGuides":
+				CurrentFile.ClearGuides()
+			case "gridOptions":
+				GridUIShowDialog()
+			case "zoomIn":
+				CurrentFile.ZoomStep(1, viewCenter())
+			case "zoomOut":
+				CurrentFile.ZoomStep(-1, viewCenter())
+			case "zoomFit":
+				CurrentFile.ZoomFit()
+			case "zoomActual":
+				CurrentFile.ZoomActual()
 			case "showDebug":
 				ShowDebug = !ShowDebug
 			case "resize":
@@ -412,6 +491,11 @@ func (s *UIControlSystem) HandleKeyboardEvents() {
 					}
 				}
 
+			case "transform":
+				TransformUIShowDialog()
+			case "selectInverse":
+				CurrentFile.SelectInverse()
+
 			case "flipHorizontal":
 				CurrentFile.FlipHorizontal()
 			case "flipVertical":
@@ -441,13 +525,17 @@ func (s *UIControlSystem) HandleKeyboardEvents() {
 			case "close":
 				UIClose()
 			case "save":
-				if len(CurrentFile.FileDir) > 0 {
-					CurrentFile.SaveAs(CurrentFile.FileDir)
-				} else {
-					UISaveAs()
-				}
+				UISave()
 			case "saveAs":
 				UISaveAs()
+			case "saveAll":
+				UISaveAll()
+			case "export":
+				UIExport()
+			case "reExport":
+				UIReExport()
+			case "import":
+				ImportUIShowDialog()
 			case "undo":
 				CurrentFile.Undo()
 			case "redo":
@@ -493,7 +581,7 @@ func (s *UIControlSystem) HandleKeyboardEvents() {
 			s.keyRepeatTimer = 0
 			s.keyMoveable = false
 
-			moveAmount := int32(CurrentFile.FileCamera.Zoom)
+			moveAmount := MaxInt32(int32(CurrentFile.FileCamera.Zoom), 1)
 			x := rl.GetMouseX()
 			y := rl.GetMouseY()
 
@@ -510,25 +598,25 @@ func (s *UIControlSystem) HandleKeyboardEvents() {
 			switch {
 			case matches(last, s.Keymap.Data["toolRight"]):
 				// Move selection
-				if _, ok := LeftTool.(*SelectorTool); ok {
+				if IsSelectionTool(LeftTool) {
 					CurrentFile.MoveSelection(1, 0)
 				} else {
 					rl.SetMousePosition(int(x+moveAmount), int(y))
 				}
 			case matches(last, s.Keymap.Data["toolLeft"]):
-				if _, ok := LeftTool.(*SelectorTool); ok {
+				if IsSelectionTool(LeftTool) {
 					CurrentFile.MoveSelection(-1, 0)
 				} else {
 					rl.SetMousePosition(int(x-moveAmount), int(y))
 				}
 			case matches(last, s.Keymap.Data["toolDown"]):
-				if _, ok := LeftTool.(*SelectorTool); ok {
+				if IsSelectionTool(LeftTool) {
 					CurrentFile.MoveSelection(0, 1)
 				} else {
 					rl.SetMousePosition(int(x), int(y+moveAmount))
 				}
 			case matches(last, s.Keymap.Data["toolUp"]):
-				if _, ok := LeftTool.(*SelectorTool); ok {
+				if IsSelectionTool(LeftTool) {
 					CurrentFile.MoveSelection(0, -1)
 				} else {
 					rl.SetMousePosition(int(x), int(y-moveAmount))
@@ -557,15 +645,31 @@ func (s *UIControlSystem) Update(dt float32) {
 			if len(cmd.Name) > 0 {
 				// open also sets the currentfile before rebuilding ui
 				log.Println("Opening file", cmd.Name)
-				Files = append(Files, Open(cmd.Name))
+				if file, err := Open(cmd.Name); err == nil {
+					Files = append(Files, file)
+				} else {
+					UIShowError(err)
+				}
 				// EditorsUIAddButton(file)
 				EditorsUIRebuild()
 
 			}
 		case CommandTypeSave:
 			if len(cmd.Name) > 0 {
-				CurrentFile.SaveAs(cmd.Name)
+				err := CurrentFile.SaveAs(cmd.Name)
+				if err != nil {
+					UIShowError(err)
+				}
+				UIFinishSaveAs(err == nil)
 			}
+		case CommandTypeExport:
+			ExportUIFinishChooseFile(cmd.Name)
+		case CommandTypeImport:
+			ImportUIFinishChooseFile(cmd.Name)
+		case CommandTypeFail:
+			UIFinishSaveAs(false)
+			ExportUIFinishChooseFile("")
+			ImportUIFinishChooseFile("")
 		}
 	default:
 	}
@@ -574,7 +678,11 @@ func (s *UIControlSystem) Update(dt float32) {
 		files := rl.LoadDroppedFiles()
 		for _, filePath := range files {
 			log.Println("Opening file", filePath)
-			Files = append(Files, Open(filePath))
+			if file, err := Open(filePath); err == nil {
+				Files = append(Files, file)
+			} else {
+				UIShowError(err)
+			}
 			EditorsUIRebuild()
 		}
 		rl.UnloadDroppedFiles()
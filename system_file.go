@@ -229,7 +229,26 @@ func NewUIFileSystem() *UIRenderFileSystem {
 		}
 	}
 
+	viewTopBar = editors
+	viewLeftPanel = leftPanel
+	viewRightPanel = rightPanel
+
 	NewResizeUI()
+	NewRampUI()
+	NewChannelsUI()
+	NewHistoryUI()
+	NewUnsavedUI()
+	NewExportUI()
+	NewImportUI()
+	NewGridUI()
+	NewSelectionMasksUI()
+	NewTransformUI()
+	NewScaleUI()
+	NewRegridUI()
+	NewTilesUI()
+	NewFiltersUI()
+	NewOutlineUI()
+	NewEffectsUI()
 
 	return s
 }
@@ -245,6 +264,7 @@ func (s *UIRenderFileSystem) Draw() {
 	} else {
 		LeftTool.DrawPreview(int32(s.cursor.X), int32(s.cursor.Y))
 	}
+	CurrentFile.DrawFilterPreview()
 
 	rl.EndTextureMode()
 
@@ -280,6 +300,8 @@ func (s *UIRenderFileSystem) Draw() {
 		rl.NewVector2(-float32(previewLayer.Canvas.Texture.Width)/2, -float32(previewLayer.Canvas.Texture.Height)/2),
 		rl.White)
 
+	DrawPixelGrid(CurrentFile)
+
 	// Grid drawing
 	if CurrentFile.DrawGrid {
 		for x := int32(0); x <= CurrentFile.CanvasWidth; x += CurrentFile.TileWidth {
@@ -331,6 +353,8 @@ func (s *UIRenderFileSystem) Draw() {
 
 	}
 
+	DrawReferenceGrid(CurrentFile)
+
 	// Show outline for canvas resize preview
 	if CurrentFile.DoingResize {
 		var x, y float32
@@ -385,6 +409,12 @@ func (s *UIRenderFileSystem) Draw() {
 	} else {
 		LeftTool.DrawUI(CurrentFile.FileCamera)
 	}
+	// Selection tools draw their own outline, others show the mask
+	if CurrentFile.DoingSelection && !IsSelectionTool(LeftTool) {
+		drawSelectionOutline(CurrentFile.FileCamera, rl.Gray)
+	}
+	DrawGuides(CurrentFile)
+	DrawRulers(CurrentFile, s.cursor)
 	rl.EndMode2D()
 }
 
@@ -459,21 +489,18 @@ func (s *UIRenderFileSystem) Update(dt float32) {
 	s.mouseX = rl.GetMouseX()
 	s.mouseY = rl.GetMouseY()
 
-	// Scroll towards the cursor's location
+	// Zoom to the next step, keeping the cursor over the same pixel
 	if !UIHasControl {
 		scrollAmount := rl.GetMouseWheelMove()
-		if scrollAmount != 0 {
-			// TODO scroll scalar in config (0.1)
-			CurrentFile.FileCameraTarget.X += ((float32(s.mouseX) - float32(rl.GetScreenWidth())/2) / (CurrentFile.FileCamera.Zoom * 10)) * float32(scrollAmount)
-			CurrentFile.FileCameraTarget.Y += ((float32(s.mouseY) - float32(rl.GetScreenHeight())/2) / (CurrentFile.FileCamera.Zoom * 10)) * float32(scrollAmount)
-			CurrentFile.FileCamera.Target = CurrentFile.FileCameraTarget
-			CurrentFile.FileCamera.Zoom += float32(scrollAmount) * 0.1 * CurrentFile.FileCamera.Zoom
+		if scrollAmount > 0 {
+			CurrentFile.ZoomStep(1, rl.GetMousePosition())
+		} else if scrollAmount < 0 {
+			CurrentFile.ZoomStep(-1, rl.GetMousePosition())
 		}
 	}
 
 	if rl.IsMouseButtonDown(rl.MouseMiddleButton) {
-		CurrentFile.FileCameraTarget.X += float32(s.mouseLastX-s.mouseX) / CurrentFile.FileCamera.Zoom
-		CurrentFile.FileCameraTarget.Y += float32(s.mouseLastY-s.mouseY) / CurrentFile.FileCamera.Zoom
+		CurrentFile.Pan(float32(s.mouseLastX-s.mouseX), float32(s.mouseLastY-s.mouseY))
 	}
 	s.mouseLastX = s.mouseX
 	s.mouseLastY = s.mouseY
@@ -486,8 +513,23 @@ func (s *UIRenderFileSystem) Update(dt float32) {
 	)
 
 	PreviewUIDrawTile(int32(s.cursor.X), int32(s.cursor.Y))
+	if err := CurrentFile.UpdateSelectionTransform(); err != nil {
+		UIShowError(err)
+		TransformUIRefresh()
+	}
+	FiltersUIUpdate()
+	EffectsUIUpdate()
+	CurrentFile.UpdateFilterPreview()
+	if CurrentFile.layerEffectsChanged {
+		CurrentFile.RedrawRenderLayer()
+	}
 
 	FileHasControl = false
+	// A guide keeps being dragged when the cursor moves over the UI
+	if (!UIHasControl || draggedGuide >= 0) && CurrentFile.UpdateGuideDrag(rl.GetMousePosition(), s.cursor) {
+		FileHasControl = true
+		return
+	}
 	if !UIHasControl {
 		if rl.IsMouseButtonDown(rl.MouseLeftButton) {
 
@@ -498,10 +540,10 @@ func (s *UIRenderFileSystem) Update(dt float32) {
 				switch LeftTool.(type) {
 				case *PickerTool:
 					// ignore
-				case *SelectorTool:
+				case *SelectorTool, *MagicWandTool, *LassoTool, *PolygonTool, *TilesTool:
 					// ignore
 				default:
-					CurrentFile.AppendHistory(HistoryPixel{make(map[IntVec2]PixelStateData), CurrentFile.CurrentLayer})
+					CurrentFile.AppendHistory(HistoryPixel{PixelState: make(map[IntVec2]PixelStateData), LayerIndex: CurrentFile.CurrentLayer, Name: LeftTool.String()})
 				}
 			}
 			CurrentFile.HasDoneMouseUpLeft = false
@@ -523,10 +565,10 @@ func (s *UIRenderFileSystem) Update(dt float32) {
 				switch LeftTool.(type) {
 				case *PickerTool:
 					// ignore
-				case *SelectorTool:
+				case *SelectorTool, *MagicWandTool, *LassoTool, *PolygonTool, *TilesTool:
 					// ignore
 				default:
-					CurrentFile.AppendHistory(HistoryPixel{make(map[IntVec2]PixelStateData), CurrentFile.CurrentLayer})
+					CurrentFile.AppendHistory(HistoryPixel{PixelState: make(map[IntVec2]PixelStateData), LayerIndex: CurrentFile.CurrentLayer, Name: RightTool.String()})
 				}
 			}
 			CurrentFile.HasDoneMouseUpRight = false
@@ -33,15 +33,17 @@ func (t *FillTool) MouseUp(x, y int32, button MouseButton) {
 	}
 
 	pd := CurrentFile.GetCurrentLayer().PixelData
+	children := CurrentFile.layerChildren()
 	clickedColor := pd[IntVec2{x, y}]
 
 	var recFill func(rx, ry int32)
 	recFill = func(rx, ry int32) {
-		if pd[IntVec2{rx, ry}] == clickedColor && color != clickedColor {
+		// Stops at the edge of the selection, if there is one
+		if pd[IntVec2{rx, ry}] == clickedColor && color != clickedColor && CurrentFile.InSelection(IntVec2{rx, ry}) {
 			// Set color
 			oldColor := pd[IntVec2{rx, ry}]
 			// pd[IntVec2{rx, ry}] = color
-			CurrentFile.DrawPixel(rx, ry, color, CurrentFile.GetCurrentLayer())
+			CurrentFile.DrawPixel(rx, ry, color, CurrentFile.GetCurrentLayer(), children)
 
 			// Append history
 			if oldColor != color {
@@ -0,0 +1,75 @@
+package main
+
+import (
+	rl "github.com/gen2brain/raylib-go/raylib"
+)
+
+// LassoTool selects the pixels inside a line drawn freehand
+type LassoTool struct {
+	name   string
+	points []IntVec2
+
+	// Dragging the selection moves it with the selector
+	selector *SelectorTool
+	moving   bool
+	down     bool
+}
+
+// NewLassoTool returns the lasso tool. Requires a name.
+func NewLassoTool(name string) *LassoTool {
+	return &LassoTool{
+		name:     name,
+		selector: NewSelectorTool(name),
+	}
+}
+
+// MouseDown is for mouse down events
+func (t *LassoTool) MouseDown(x, y int32, button MouseButton) {
+	pos := IntVec2{x, y}
+	if !t.down {
+		t.down = true
+		_, onSelection := CurrentFile.Selection[pos]
+		t.moving = CurrentFile.DoingSelection && onSelection && CurrentSelectOp() == SelectOpReplace
+		t.points = t.points[:0]
+	}
+
+	if t.moving {
+		t.selector.MouseDown(x, y, button)
+		return
+	}
+	if len(t.points) == 0 || t.points[len(t.points)-1] != pos {
+		t.points = append(t.points, pos)
+	}
+}
+
+// MouseUp is for mouse up events
+func (t *LassoTool) MouseUp(x, y int32, button MouseButton) {
+	t.down = false
+	if t.moving {
+		t.moving = false
+		t.selector.MouseUp(x, y, button)
+		return
+	}
+
+	// A click without a drag selects nothing
+	if len(t.points) < 3 {
+		t.points = t.points[:0]
+	}
+	CurrentFile.SelectPixels(PolygonPixels(t.points), CurrentSelectOp())
+	t.points = t.points[:0]
+}
+
+// DrawPreview is for drawing the preview
+func (t *LassoTool) DrawPreview(x, y int32) {
+	t.selector.DrawPreview(x, y)
+}
+
+// DrawUI is for drawing the UI
+func (t *LassoTool) DrawUI(camera rl.Camera2D) {
+	t.selector.DrawUI(camera)
+	drawPolyline(t.points, camera)
+}
+
+func (t *LassoTool) String() string {
+	return t.name
+}
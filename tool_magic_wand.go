@@ -0,0 +1,99 @@
+package main
+
+import (
+	rl "github.com/gen2brain/raylib-go/raylib"
+)
+
+// MagicWandTool selects pixels of the same color as the one clicked
+type MagicWandTool struct {
+	name         string
+	tolerance    int32
+	area         WandArea
+	sampleMerged bool
+
+	// Dragging the selection moves it with the selector
+	selector *SelectorTool
+	moving   bool
+	down     bool
+	downPos  IntVec2
+}
+
+// NewMagicWandTool returns the magic wand tool. Requires a name.
+func NewMagicWandTool(name string) *MagicWandTool {
+	return &MagicWandTool{
+		name:     name,
+		selector: NewSelectorTool(name),
+	}
+}
+
+// SetTolerance sets how much each channel can differ from the clicked color
+func (t *MagicWandTool) SetTolerance(tolerance int32) {
+	t.tolerance = MaxInt32(MinInt32(tolerance, 255), 0)
+}
+
+// GetTolerance gets how much each channel can differ from the clicked color
+func (t *MagicWandTool) GetTolerance() int32 {
+	return t.tolerance
+}
+
+// SetArea sets which pixels can be reached
+func (t *MagicWandTool) SetArea(area WandArea) {
+	t.area = area
+}
+
+// GetArea gets which pixels can be reached
+func (t *MagicWandTool) GetArea() WandArea {
+	return t.area
+}
+
+// SetSampleMerged sets if colors are taken from all visible layers instead of
+// the current layer
+func (t *MagicWandTool) SetSampleMerged(sampleMerged bool) {
+	t.sampleMerged = sampleMerged
+}
+
+// GetSampleMerged gets if colors are taken from all visible layers
+func (t *MagicWandTool) GetSampleMerged() bool {
+	return t.sampleMerged
+}
+
+// MouseDown is for mouse down events
+func (t *MagicWandTool) MouseDown(x, y int32, button MouseButton) {
+	if !t.down {
+		t.down = true
+		t.downPos = IntVec2{x, y}
+		_, onSelection := CurrentFile.Selection[t.downPos]
+		t.moving = CurrentFile.DoingSelection && onSelection && CurrentSelectOp() == SelectOpReplace
+	}
+
+	if t.moving {
+		t.selector.MouseDown(x, y, button)
+	}
+}
+
+// MouseUp is for mouse up events
+func (t *MagicWandTool) MouseUp(x, y int32, button MouseButton) {
+	t.down = false
+	if t.moving {
+		t.moving = false
+		t.selector.MouseUp(x, y, button)
+		return
+	}
+
+	region := CurrentFile.WandRegion(t.downPos, t.tolerance, t.area, t.sampleMerged)
+	CurrentFile.SelectPixels(region, CurrentSelectOp())
+}
+
+// DrawPreview is for drawing the preview
+func (t *MagicWandTool) DrawPreview(x, y int32) {
+	t.selector.DrawPreview(x, y)
+}
+
+// DrawUI is for drawing the UI
+func (t *MagicWandTool) DrawUI(camera rl.Camera2D) {
+	t.selector.DrawUI(camera)
+}
+
+func (t *MagicWandTool) String() string {
+	return t.name
+}
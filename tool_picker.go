@@ -1,12 +1,44 @@
 package main
 
 import (
+	"fmt"
+
 	rl "github.com/gen2brain/raylib-go/raylib"
 )
 
+// PickerMode is where the picker samples colors from
+type PickerMode int32
+
+// Picker modes
+const (
+	// PickerModeLayer picks from the current layer
+	PickerModeLayer PickerMode = iota
+	// PickerModeMerged picks from the composite of all visible layers
+	PickerModeMerged
+	// PickerModeSwitchLayer picks from the top visible layer with a pixel at
+	// the location and makes it the current layer. Masks aren't picked
+	PickerModeSwitchLayer
+)
+
+func (m PickerMode) String() string {
+	switch m {
+	case PickerModeLayer:
+		return "layer"
+	case PickerModeMerged:
+		return "merged"
+	case PickerModeSwitchLayer:
+		return "owner"
+	}
+	return "unknown"
+}
+
 // PickerTool Pickers an area of the same colored pixels
 type PickerTool struct {
 	name string
+	mode PickerMode
+
+	// Last hovered location, used by the readout
+	lastX, lastY int32
 }
 
 // NewPickerTool returns the Picker tool. Requires a name.
@@ -16,14 +48,55 @@ func NewPickerTool(name string) *PickerTool {
 	}
 }
 
+// SetMode sets where colors are picked from
+func (t *PickerTool) SetMode(mode PickerMode) {
+	t.mode = mode
+}
+
+// GetMode gets where colors are picked from
+func (t *PickerTool) GetMode() PickerMode {
+	return t.mode
+}
+
+// pick returns the color at x, y for the current mode along with the index of
+// the layer which owns it. The index is -1 when the color comes from the
+// composite.
+func (t *PickerTool) pick(x, y int32) (rl.Color, int32, bool) {
+	loc := IntVec2{x, y}
+	switch t.mode {
+	case PickerModeMerged:
+		color, ok := CurrentFile.RenderLayer.PixelData[loc]
+		return color, -1, ok
+	case PickerModeSwitchLayer:
+		// -2 bc temp layer is excluded
+		for i := int32(len(CurrentFile.Layers) - 2); i >= 0; i-- {
+			layer := CurrentFile.Layers[i]
+			if layer.IsMask || !layer.Visible() {
+				continue
+			}
+			if color, ok := layer.PixelData[loc]; ok && color.A > 0 {
+				return color, i, true
+			}
+		}
+		return rl.Blank, -1, false
+	}
+	color, ok := CurrentFile.GetCurrentLayer().PixelData[loc]
+	return color, CurrentFile.CurrentLayer, ok
+}
+
 // MouseDown is for mouse down events
 func (t *PickerTool) MouseDown(x, y int32, button MouseButton) {
 }
 
 // MouseUp is for mouse up events
 func (t *PickerTool) MouseUp(x, y int32, button MouseButton) {
-	color, ok := CurrentFile.GetCurrentLayer().PixelData[IntVec2{x, y}]
+	color, layerIndex, ok := t.pick(x, y)
 	if ok {
+		if t.mode == PickerModeSwitchLayer && layerIndex != CurrentFile.CurrentLayer {
+			CurrentFile.SetCurrentLayer(layerIndex)
+			LayersUISetCurrentLayer(layerIndex)
+		}
+
 		PaletteUIHideCurrentColorIndicator()
 		switch button {
 		case rl.MouseLeftButton:
@@ -43,9 +116,12 @@ func (t *PickerTool) MouseUp(x, y int32, button MouseButton) {
 
 // DrawPreview is for drawing the preview
 func (t *PickerTool) DrawPreview(x, y int32) {
+	t.lastX = x
+	t.lastY = y
+
 	rl.ClearBackground(rl.Blank)
 	// Preview pixel location with a suitable color
-	c := CurrentFile.GetCurrentLayer().PixelData[IntVec2{x, y}]
+	c, _, _ := t.pick(x, y)
 	avg := (c.R + c.G + c.B) / 3
 	if avg > 255/2 {
 		rl.DrawPixel(x, y, rl.NewColor(0, 0, 0, 192))
@@ -56,7 +132,35 @@ func (t *PickerTool) DrawPreview(x, y int32) {
 
 // DrawUI is for drawing the UI
 func (t *PickerTool) DrawUI(camera rl.Camera2D) {
+	x, y := t.lastX, t.lastY
+	if UIHasControl || x < 0 || y < 0 || x >= CurrentFile.CanvasWidth || y >= CurrentFile.CanvasHeight {
+		return
+	}
+
+	color, layerIndex, ok := t.pick(x, y)
+	if !ok {
+		color = rl.Blank
+	}
+
+	tilesX := CurrentFile.CanvasWidth / CurrentFile.TileWidth
+	tx := x / CurrentFile.TileWidth
+	ty := y / CurrentFile.TileHeight
+
+	text := fmt.Sprintf("%d, %d  #%s  a:%d  tile %d (%d, %d)",
+		x, y, ColorToHex(color), color.A, ty*tilesX+tx, tx, ty)
+	if layerIndex >= 0 {
+		text += "  " + CurrentFile.Layers[layerIndex].Name
+	}
 
+	// Draw next to the cursor, with a swatch of the color
+	pos := rl.GetMousePosition()
+	pos.X += UIFontSize
+	pos.Y += UIFontSize
+	measured := rl.MeasureTextEx(Font, text, UIFontSize, 1)
+	rl.DrawRectangle(int32(pos.X)-4, int32(pos.Y)-4, int32(measured.X+UIFontSize)+12, int32(measured.Y)+8, rl.NewColor(0, 0, 0, 192))
+	rl.DrawRectangle(int32(pos.X), int32(pos.Y), int32(UIFontSize), int32(UIFontSize), color)
+	rl.DrawRectangleLines(int32(pos.X), int32(pos.Y), int32(UIFontSize), int32(UIFontSize), rl.White)
+	rl.DrawTextEx(Font, text, rl.Vector2{X: pos.X + UIFontSize + 4, Y: pos.Y}, UIFontSize, 1, rl.White)
 }
 
 func (t *PickerTool) String() string {
@@ -62,6 +62,8 @@ type PixelBrushTool struct {
 	shape                  BrushShape
 	// Don't draw over the same pixel multiple times, prevents opacity stacking
 	drawnPixels map[IntVec2]bool
+	// The layers of the file being drawn on, see File.layerChildren
+	children map[*Layer][]*Layer
 
 	currentColor rl.Color
 	circles      []map[IntVec2]bool
@@ -200,9 +202,9 @@ func (t *PixelBrushTool) drawPixel(x, y int32, color rl.Color, fileDraw bool) {
 		sx, sy := x+pos.X, y+pos.Y
 		if !t.exists(IntVec2{sx, sy}) {
 			if fileDraw {
-				CurrentFile.DrawPixel(sx, sy, color, CurrentFile.GetCurrentLayer())
+				CurrentFile.DrawPixel(sx, sy, color, CurrentFile.GetCurrentLayer(), t.children)
 				t.drawnPixels[IntVec2{sx, sy}] = true
-			} else {
+			} else if CurrentFile.InSelection(IntVec2{sx, sy}) {
 				rl.DrawPixel(sx, sy, color)
 			}
 		}
@@ -238,6 +240,11 @@ func (t *PixelBrushTool) MouseDown(x, y int32, button MouseButton) {
 		}
 	}
 
+	// The layers can't change during a stroke
+	if t.children == nil {
+		t.children = CurrentFile.layerChildren()
+	}
+
 	if t.shouldConnectToLastPos || t.isLineModifierDown() {
 		Line(t.lastPos.X, t.lastPos.Y, x, y, func(x, y int32) {
 			// prevent drawing over the first pixel and stacking them, with color.A<255, opacity stacks 😠
@@ -257,6 +264,7 @@ func (t *PixelBrushTool) MouseDown(x, y int32, button MouseButton) {
 func (t *PixelBrushTool) MouseUp(x, y int32, button MouseButton) {
 	t.shouldConnectToLastPos = false
 	t.drawnPixels = make(map[IntVec2]bool)
+	t.children = nil
 	// CurrentFile.GetCurrentLayer().Redraw()
 }
 
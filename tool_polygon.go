@@ -0,0 +1,98 @@
+package main
+
+import (
+	"time"
+
+	rl "github.com/gen2brain/raylib-go/raylib"
+)
+
+// PolygonTool selects the pixels inside straight lines placed by clicking.
+// The polygon is closed by clicking the first point or double clicking
+type PolygonTool struct {
+	name   string
+	points []IntVec2
+	cursor IntVec2
+	// For detecting double clicks
+	lastClickTime time.Time
+
+	// Dragging the selection moves it with the selector
+	selector *SelectorTool
+	moving   bool
+	down     bool
+}
+
+// polygonDoubleClickTime is the most time between the clicks of a double click
+var polygonDoubleClickTime = time.Millisecond * 400
+
+// NewPolygonTool returns the polygon selection tool. Requires a name.
+func NewPolygonTool(name string) *PolygonTool {
+	return &PolygonTool{
+		name:     name,
+		selector: NewSelectorTool(name),
+	}
+}
+
+// Cancel removes the points placed so far
+func (t *PolygonTool) Cancel() {
+	t.points = t.points[:0]
+}
+
+// MouseDown is for mouse down events
+func (t *PolygonTool) MouseDown(x, y int32, button MouseButton) {
+	if !t.down {
+		t.down = true
+		_, onSelection := CurrentFile.Selection[IntVec2{x, y}]
+		t.moving = len(t.points) == 0 && CurrentFile.DoingSelection && onSelection && CurrentSelectOp() == SelectOpReplace
+	}
+
+	if t.moving {
+		t.selector.MouseDown(x, y, button)
+	}
+}
+
+// MouseUp is for mouse up events
+func (t *PolygonTool) MouseUp(x, y int32, button MouseButton) {
+	t.down = false
+	if t.moving {
+		t.moving = false
+		t.selector.MouseUp(x, y, button)
+		return
+	}
+
+	pos := IntVec2{x, y}
+	doubleClick := time.Now().Sub(t.lastClickTime) < polygonDoubleClickTime
+	t.lastClickTime = time.Now()
+
+	if len(t.points) >= 3 && (pos == t.points[0] || doubleClick && pos == t.points[len(t.points)-1]) {
+		CurrentFile.SelectPixels(PolygonPixels(t.points), CurrentSelectOp())
+		t.points = t.points[:0]
+		return
+	}
+	if len(t.points) == 0 || t.points[len(t.points)-1] != pos {
+		t.points = append(t.points, pos)
+	}
+}
+
+// DrawPreview is for drawing the preview
+func (t *PolygonTool) DrawPreview(x, y int32) {
+	t.cursor = IntVec2{x, y}
+	t.selector.DrawPreview(x, y)
+}
+
+// DrawUI is for drawing the UI
+func (t *PolygonTool) DrawUI(camera rl.Camera2D) {
+	t.selector.DrawUI(camera)
+	if len(t.points) == 0 {
+		return
+	}
+	drawPolyline(append(t.points, t.cursor), camera)
+
+	// Clicking the first point closes the polygon
+	first := pixelCenterOnScreen(t.points[0], camera)
+	size := float32(8)
+	rl.DrawRectangleLinesEx(rl.NewRectangle(first.X-size/2, first.Y-size/2, size, size), 2, rl.White)
+}
+
+func (t *PolygonTool) String() string {
+	return t.name
+}
@@ -1,12 +1,17 @@
 package main
 
 import (
+	"log"
+	"math"
 	"time"
 
 	rl "github.com/gen2brain/raylib-go/raylib"
 )
 
-// TODO rotate
+var (
+	// selectionHandleSize is the size of the resize handles in screen pixels
+	selectionHandleSize float32 = 8
+)
 
 // SelectorTool allows for a selection to be made
 type SelectorTool struct {
@@ -14,18 +19,17 @@ type SelectorTool struct {
 	firstDown         bool
 	mouseReleased     bool
 	resizeSide        ResizeDirection
-	// Should resize the original selection only
-	oldWidth  int32
-	oldHeight int32
-	oldImg    *rl.Image
-	// selection copied into another image for modification
-	imgCopy            *rl.Image
-	oldSelection       []rl.Color
-	oldSelectionCopied bool
+	// resizeStart is the transform when the handle was grabbed
+	resizeStart TransformParams
 	// Cancels the selection if a click happens without drag
 	firstDownTime time.Time
 	name          string
 
+	// moving is true while the selection is dragged, moveStart is where the
+	// selection was when the drag started
+	moving    bool
+	moveStart IntVec2
+
 	selectionFadeColor                     int32
 	selectionFadeColorIncrease             int32 // increase by amount
 	selectionFadeColorIncreasing           bool
@@ -55,39 +59,11 @@ func (t *SelectorTool) MouseDown(x, y int32, button MouseButton) {
 		t.firstDownTime = time.Now()
 		t.firstPos = IntVec2{x, y}
 
-		// Resize selection
-		x0, y0 := CurrentFile.SelectionBounds[0], CurrentFile.SelectionBounds[1]
-		x1, y1 := CurrentFile.SelectionBounds[2], CurrentFile.SelectionBounds[3]
-		if t.firstPos.Y >= y0-1 && t.firstPos.Y-1 <= y1 {
-			if t.firstPos.X == x0-1 {
-				t.resizeSide = ResizeCL
-				CurrentFile.SelectionResizing = true
-			}
-			if t.firstPos.X-1 == x1 {
-				t.resizeSide = ResizeCR
-				CurrentFile.SelectionResizing = true
-			}
-		}
-		if t.firstPos.X >= x0-1 && t.firstPos.X-1 <= x1 {
-			if t.firstPos.Y == y0-1 {
-				// TODO use bit operations
-				if t.resizeSide == ResizeCL {
-					t.resizeSide = ResizeTL
-				} else if t.resizeSide == ResizeCR {
-					t.resizeSide = ResizeTR
-				} else {
-					t.resizeSide = ResizeTC
-				}
-				CurrentFile.SelectionResizing = true
-			}
-			if t.firstPos.Y-1 == y1 {
-				if t.resizeSide == ResizeCL {
-					t.resizeSide = ResizeBL
-				} else if t.resizeSide == ResizeCR {
-					t.resizeSide = ResizeBR
-				} else {
-					t.resizeSide = ResizeBC
-				}
+		// Grabbing a handle resizes the selection
+		if dir := selectionHandleAt(rl.GetMousePosition()); dir != ResizeNone {
+			if tr := CurrentFile.BeginSelectionTransform(); tr != nil {
+				t.resizeSide = dir
+				t.resizeStart = tr.Params
 				CurrentFile.SelectionResizing = true
 			}
 		}
@@ -98,137 +74,8 @@ func (t *SelectorTool) MouseDown(x, y int32, button MouseButton) {
 	t.lastPos = IntVec2{x, y}
 	firstPosClone := t.firstPos
 
-	// Bounds resizing
-	if CurrentFile.SelectionResizing == true {
-		if t.oldSelectionCopied == false {
-			t.oldSelectionCopied = true
-			t.oldSelection = CurrentFile.SelectionPixels
-
-			CurrentFile.MoveSelection(0, 0)
-
-			// Make an image from the selection
-			t.oldWidth = int32(CurrentFile.SelectionBounds[2] - CurrentFile.SelectionBounds[0] + 1)
-			t.oldHeight = int32(CurrentFile.SelectionBounds[3] - CurrentFile.SelectionBounds[1] + 1)
-		}
-
-		if len(t.oldSelection) == 0 {
-			CurrentFile.SelectionResizing = false
-			return
-		}
-
-		// Make a new image to modify using the old data
-		tex := rl.LoadRenderTexture(t.oldWidth, t.oldHeight)
-		rl.UpdateTexture(tex.Texture, t.oldSelection)
-		t.oldImg = rl.LoadImageFromTexture(tex.Texture)
-		rl.UnloadRenderTexture(tex)
-
-		// Resize selection bounds
-		// Selection bounds shifting logic so that the selection is flipped
-		// without including the starting pixel
-		bottom := func() {
-			CurrentFile.SelectionBounds[1] = CurrentFile.OrigSelectionBounds[1]
-			if CurrentFile.SelectionBounds[3] < CurrentFile.SelectionBounds[1]-1 {
-				CurrentFile.SelectionBounds[1] = CurrentFile.OrigSelectionBounds[1] - 1
-				CurrentFile.SelectionBounds[3] += 2
-			}
-		}
-		top := func() {
-			CurrentFile.SelectionBounds[3] = CurrentFile.OrigSelectionBounds[3]
-			if CurrentFile.SelectionBounds[1] > CurrentFile.SelectionBounds[3]+1 {
-				CurrentFile.SelectionBounds[3] = CurrentFile.OrigSelectionBounds[3] + 1
-				CurrentFile.SelectionBounds[1] -= 2
-			}
-		}
-		left := func() {
-			CurrentFile.SelectionBounds[2] = CurrentFile.OrigSelectionBounds[2]
-			if CurrentFile.SelectionBounds[0] > CurrentFile.SelectionBounds[2]+1 {
-				CurrentFile.SelectionBounds[2] = CurrentFile.OrigSelectionBounds[2] + 1
-				CurrentFile.SelectionBounds[0] -= 2
-			}
-		}
-		right := func() {
-			CurrentFile.SelectionBounds[0] = CurrentFile.OrigSelectionBounds[0]
-			if CurrentFile.SelectionBounds[2] < CurrentFile.SelectionBounds[0]-1 {
-				CurrentFile.SelectionBounds[0] = CurrentFile.OrigSelectionBounds[0] - 1
-				CurrentFile.SelectionBounds[2] += 2
-			}
-		}
-		switch t.resizeSide {
-		case ResizeTL:
-			CurrentFile.SelectionBounds[0] = t.lastPos.X + 1
-			CurrentFile.SelectionBounds[1] = t.lastPos.Y + 1
-			top()
-			left()
-		case ResizeTC:
-			CurrentFile.SelectionBounds[1] = t.lastPos.Y + 1
-			top()
-		case ResizeTR:
-			CurrentFile.SelectionBounds[2] = t.lastPos.X - 1
-			CurrentFile.SelectionBounds[1] = t.lastPos.Y + 1
-			top()
-			right()
-		case ResizeCL:
-			CurrentFile.SelectionBounds[0] = t.lastPos.X + 1
-			left()
-		case ResizeCR:
-			CurrentFile.SelectionBounds[2] = t.lastPos.X - 1
-			right()
-		case ResizeBL:
-			CurrentFile.SelectionBounds[0] = t.lastPos.X + 1
-			CurrentFile.SelectionBounds[3] = t.lastPos.Y - 1
-			bottom()
-			left()
-		case ResizeBC:
-			CurrentFile.SelectionBounds[3] = t.lastPos.Y - 1
-			bottom()
-		case ResizeBR:
-			CurrentFile.SelectionBounds[2] = t.lastPos.X - 1
-			CurrentFile.SelectionBounds[3] = t.lastPos.Y - 1
-			bottom()
-			right()
-		}
-
-		// Do the resize
-		newWidth := CurrentFile.SelectionBounds[2] - CurrentFile.SelectionBounds[0] + 1
-		newHeight := CurrentFile.SelectionBounds[3] - CurrentFile.SelectionBounds[1] + 1
-
-		// Reset the selection
-		// TODO it creates a lot of objects, not very efficient
-		CurrentFile.Selection = make(map[IntVec2]rl.Color)
-
-		// Handle selection flips
-		if newWidth <= 0 {
-			newWidth *= -1
-			newWidth += 2
-			rl.ImageFlipHorizontal(t.oldImg)
-		}
-		if newHeight <= 0 {
-			newHeight *= -1
-			newHeight += 2
-			rl.ImageFlipVertical(t.oldImg)
-		}
-
-		if newWidth > 0 && newHeight > 0 {
-			rl.ImageResizeNN(t.oldImg, newWidth, newHeight)
-		}
-
-		// Dump pixels back into the selection
-		imgPixels := rl.LoadImageColors(t.oldImg)
-		CurrentFile.SelectionPixels = imgPixels
-		var count int
-		minY := MinInt32(CurrentFile.SelectionBounds[1], CurrentFile.SelectionBounds[3])
-		maxY := MaxInt32(CurrentFile.SelectionBounds[1], CurrentFile.SelectionBounds[3])
-		minX := MinInt32(CurrentFile.SelectionBounds[0], CurrentFile.SelectionBounds[2])
-		maxX := MaxInt32(CurrentFile.SelectionBounds[0], CurrentFile.SelectionBounds[2])
-		for y := minY; y <= maxY; y++ {
-			for x := minX; x <= maxX; x++ {
-				if count < len(imgPixels) {
-					CurrentFile.Selection[IntVec2{x, y}] = imgPixels[count]
-					count++
-				}
-			}
-		}
-
+	if CurrentFile.SelectionResizing {
+		t.resize(x, y)
 		return
 	}
 
@@ -239,12 +86,20 @@ func (t *SelectorTool) MouseDown(x, y int32, button MouseButton) {
 		t.lastPos.Y, firstPosClone.Y = firstPosClone.Y, t.lastPos.Y
 	}
 
-	// Move the selection
-	if CurrentFile.DoingSelection && t.firstPos.X > CurrentFile.SelectionBounds[0] && t.firstPos.X < CurrentFile.SelectionBounds[2] &&
-		t.firstPos.Y > CurrentFile.SelectionBounds[1] && t.firstPos.Y < CurrentFile.SelectionBounds[3] {
-		CurrentFile.MoveSelection(x-t.firstPos.X, y-t.firstPos.Y)
-		t.firstPos.X = x
-		t.firstPos.Y = y
+	// Move the selection if one of its pixels is dragged
+	_, onSelection := CurrentFile.Selection[t.firstPos]
+	if t.moving || CurrentFile.DoingSelection && onSelection {
+		if !t.moving {
+			t.moving = true
+			t.moveStart = IntVec2{CurrentFile.SelectionBounds[0], CurrentFile.SelectionBounds[1]}
+		}
+		// Snapped from where the selection would be without snapping so that
+		// it can be dragged off a line
+		width := CurrentFile.SelectionBounds[2] - CurrentFile.SelectionBounds[0] + 1
+		height := CurrentFile.SelectionBounds[3] - CurrentFile.SelectionBounds[1] + 1
+		left := CurrentFile.SnapSpan(t.moveStart.X+x-t.firstPos.X, width, true)
+		top := CurrentFile.SnapSpan(t.moveStart.Y+y-t.firstPos.Y, height, false)
+		CurrentFile.MoveSelection(left-CurrentFile.SelectionBounds[0], top-CurrentFile.SelectionBounds[1])
 
 		CurrentFile.OrigSelectionBounds[0] = CurrentFile.SelectionBounds[0]
 		CurrentFile.OrigSelectionBounds[1] = CurrentFile.SelectionBounds[1]
@@ -260,6 +115,12 @@ func (t *SelectorTool) MouseDown(x, y int32, button MouseButton) {
 		}
 	}
 
+	// Snap the edges, the last pixel is inside the line it's snapped to
+	firstPosClone.X = CurrentFile.SnapSpan(firstPosClone.X, 0, true)
+	firstPosClone.Y = CurrentFile.SnapSpan(firstPosClone.Y, 0, false)
+	t.lastPos.X = MaxInt32(CurrentFile.SnapSpan(t.lastPos.X+1, 0, true)-1, firstPosClone.X)
+	t.lastPos.Y = MaxInt32(CurrentFile.SnapSpan(t.lastPos.Y+1, 0, false)-1, firstPosClone.Y)
+
 	// Reset the selection
 	// TODO it creates a lot of objects, not very efficient
 	CurrentFile.Selection = make(map[IntVec2]rl.Color)
@@ -286,11 +147,123 @@ func (t *SelectorTool) MouseDown(x, y int32, button MouseButton) {
 	}
 }
 
+// resize scales the selection from the grabbed handle. The drag is turned
+// into the selection's unrotated direction, and the opposite side stays put
+func (t *SelectorTool) resize(x, y int32) {
+	tr := CurrentFile.SelectionTransform
+	if tr == nil {
+		CurrentFile.SelectionResizing = false
+		return
+	}
+	start := t.resizeStart
+	hx, hy := resizeHandleSide(t.resizeSide)
+	angle := float64(start.Rotation) * math.Pi / 180
+	sin, cos := math.Sin(angle), math.Cos(angle)
+	dx, dy := float64(x-t.firstPos.X), float64(y-t.firstPos.Y)
+
+	// Edges around the middle of the selection
+	l, r := -float64(start.Width)/2, float64(start.Width)/2
+	top, bottom := -float64(start.Height)/2, float64(start.Height)/2
+	if hx < 0 {
+		l += math.Round(cos*dx + sin*dy)
+	} else if hx > 0 {
+		r += math.Round(cos*dx + sin*dy)
+	}
+	if hy < 0 {
+		top += math.Round(-sin*dx + cos*dy)
+	} else if hy > 0 {
+		bottom += math.Round(-sin*dx + cos*dy)
+	}
+
+	// Corners follow whichever side changed most, edges grow both ways
+	if transformUILockRatio || isBindingDown("transformLockRatio") {
+		ratio := float64(start.Height) / float64(start.Width)
+		w, h := r-l, bottom-top
+		switch {
+		case hx != 0 && hy != 0:
+			if math.Abs(w)*ratio >= math.Abs(h) {
+				h = math.Copysign(math.Abs(w)*ratio, h)
+				if hy < 0 {
+					top = bottom - h
+				} else {
+					bottom = top + h
+				}
+			} else {
+				w = math.Copysign(math.Abs(h)/ratio, w)
+				if hx < 0 {
+					l = r - w
+				} else {
+					r = l + w
+				}
+			}
+		case hx != 0:
+			h = math.Abs(w) * ratio
+			top, bottom = -h/2, h/2
+		default:
+			w = math.Abs(h) / ratio
+			l, r = -w/2, w/2
+		}
+	}
+
+	// Dragging past the opposite side flips the selection
+	params := start
+	params.Width = int32(math.Min(math.Max(math.Round(math.Abs(r-l)), 1), MaxCanvasSize))
+	params.Height = int32(math.Min(math.Max(math.Round(math.Abs(bottom-top)), 1), MaxCanvasSize))
+	params.FlipH = start.FlipH != (r < l)
+	params.FlipV = start.FlipV != (bottom < top)
+
+	mx, my := (l+r)/2, (top+bottom)/2
+	cx := float64(start.X) + float64(start.Width)/2 + cos*mx - sin*my
+	cy := float64(start.Y) + float64(start.Height)/2 + sin*mx + cos*my
+	params.X = int32(math.Round(cx - float64(params.Width)/2))
+	params.Y = int32(math.Round(cy - float64(params.Height)/2))
+
+	tr.Params = params
+	if err := CurrentFile.UpdateSelectionTransform(); err != nil {
+		log.Println(err)
+	}
+	TransformUIRefresh()
+}
+
+// selectionFrame returns the selection's transform, or one for the selection
+// as it is if it isn't being transformed
+func selectionFrame() *SelectionTransform {
+	if CurrentFile.SelectionTransform != nil {
+		return CurrentFile.SelectionTransform
+	}
+	b := CurrentFile.SelectionBounds
+	return &SelectionTransform{Params: TransformParams{
+		X:      MinInt32(b[0], b[2]),
+		Y:      MinInt32(b[1], b[3]),
+		Width:  MaxInt32(b[0], b[2]) - MinInt32(b[0], b[2]) + 1,
+		Height: MaxInt32(b[1], b[3]) - MinInt32(b[1], b[3]) + 1,
+	}}
+}
+
+// selectionHandleAt returns which resize handle is under the screen position,
+// or ResizeNone
+func selectionHandleAt(screen rl.Vector2) ResizeDirection {
+	if !CurrentFile.DoingSelection || len(CurrentFile.Selection) == 0 {
+		return ResizeNone
+	}
+	points := selectionFrame().transformCorners(CurrentFile.FileCamera)
+	reach := selectionHandleSize/2 + 1
+	for i, point := range points {
+		if ResizeDirection(i) == ResizeCC {
+			continue
+		}
+		if float32(math.Abs(float64(screen.X-point.X))) <= reach && float32(math.Abs(float64(screen.Y-point.Y))) <= reach {
+			return ResizeDirection(i)
+		}
+	}
+	return ResizeNone
+}
+
 // MouseUp is for mouse up events
 func (t *SelectorTool) MouseUp(x, y int32, button MouseButton) {
 	t.firstDown = false
 	t.mouseReleased = true
-	t.oldSelectionCopied = false
+	t.moving = false
 	CurrentFile.SelectionResizing = false
 	t.resizeSide = ResizeNone
 
@@ -323,20 +296,6 @@ func (t *SelectorTool) DrawUI(camera rl.Camera2D) {
 	if !CurrentFile.DoingSelection {
 		return
 	}
-	pos := rl.GetWorldToScreen2D(rl.Vector2{X: float32(CurrentFile.SelectionBounds[0]) - float32(CurrentFile.CanvasWidth)/2, Y: float32(CurrentFile.SelectionBounds[1]) - float32(CurrentFile.CanvasHeight)/2}, camera)
-	x := pos.X
-	y := pos.Y
-	w := float32(CurrentFile.SelectionBounds[2]-CurrentFile.SelectionBounds[0]+1) * camera.Zoom
-	h := float32(CurrentFile.SelectionBounds[3]-CurrentFile.SelectionBounds[1]+1) * camera.Zoom
-
-	if w <= 0 {
-		x += w - 1*camera.Zoom
-		w = w*-1 + 2*camera.Zoom
-	}
-	if h <= 0 {
-		y += h - 1*camera.Zoom
-		h = h*-1 + 2*camera.Zoom
-	}
 
 	if time.Now().Sub(t.selectionFadeColorIncreaseTimeLast) > t.selectionFadeColorIncreaseTimeInterval {
 		t.selectionFadeColorIncreaseTimeLast = time.Now()
@@ -359,12 +318,28 @@ func (t *SelectorTool) DrawUI(camera rl.Camera2D) {
 	// log.Println(t.selectionFadeColor)
 	c := rl.NewColor(uint8(t.selectionFadeColor), uint8(t.selectionFadeColor), uint8(t.selectionFadeColor), 255)
 
-	p := camera.Zoom                                                   // pixel size
-	rl.DrawRectangleLinesEx(rl.NewRectangle(x, y, w, h), 4, c)         // main
-	rl.DrawRectangleLinesEx(rl.NewRectangle(x-p, y-p, w+p*2, p), 2, c) // top
-	rl.DrawRectangleLinesEx(rl.NewRectangle(x-p, y+h, w+p*2, p), 2, c) // bottom
-	rl.DrawRectangleLinesEx(rl.NewRectangle(x-p, y-p, p, h+p*2), 2, c) // left
-	rl.DrawRectangleLinesEx(rl.NewRectangle(x+w, y-p, p, h+p*2), 2, c) // right
+	// Shapes other than rectangles are outlined, the frame the handles are
+	// on is drawn around them
+	frame := selectionFrame()
+	points := frame.transformCorners(camera)
+	if frame.Params.Rotation == 0 && CurrentFile.IsSelectionRect() {
+		rl.DrawRectangleLinesEx(rl.NewRectangle(points[ResizeTL].X, points[ResizeTL].Y, points[ResizeBR].X-points[ResizeTL].X, points[ResizeBR].Y-points[ResizeTL].Y), 4, c)
+	} else {
+		drawSelectionOutline(camera, c)
+		for _, side := range [][2]ResizeDirection{{ResizeTL, ResizeTR}, {ResizeTR, ResizeBR}, {ResizeBR, ResizeBL}, {ResizeBL, ResizeTL}} {
+			rl.DrawLineEx(points[side[0]], points[side[1]], 1, c)
+		}
+	}
+
+	// Handles are the same size at any zoom
+	for i, point := range points {
+		if ResizeDirection(i) == ResizeCC {
+			continue
+		}
+		handle := rl.NewRectangle(point.X-selectionHandleSize/2, point.Y-selectionHandleSize/2, selectionHandleSize, selectionHandleSize)
+		rl.DrawRectangleRec(handle, c)
+		rl.DrawRectangleLinesEx(handle, 1, rl.Black)
+	}
 }
 
 func (t *SelectorTool) String() string {
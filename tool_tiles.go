@@ -0,0 +1,131 @@
+package main
+
+import (
+	rl "github.com/gen2brain/raylib-go/raylib"
+)
+
+// TilesTool selects a range of tiles like SpriteSelectorTool, then drags
+// them to another tile to move them there, or swaps them if the swapTiles
+// binding is held when they're dropped
+type TilesTool struct {
+	name     string
+	selector *SpriteSelectorTool
+	down     bool // if the mouse has been pressed
+	dragging bool // if the selected tiles are being dragged
+
+	// First and Last are the selected tiles, or -1 if none are
+	First, Last int32
+	onChange    func()
+}
+
+// NewTilesTool returns the tiles tool. onChange is called when the selected
+// tiles change
+func NewTilesTool(name string, onChange func()) *TilesTool {
+	t := &TilesTool{
+		name:     name,
+		First:    -1,
+		Last:     -1,
+		onChange: onChange,
+	}
+	t.selector = NewSpriteSelectorTool(name, func(firstSprite, lastSprite int32) {
+		t.Select(firstSprite, lastSprite)
+	})
+	return t
+}
+
+// tileIndexAt returns the sheet position of the tile under the pixel
+func tileIndexAt(x, y int32) int32 {
+	clampedPos := GetClampedCoordinates(x, y)
+	tilePos := GetTilePosition(clampedPos.X, clampedPos.Y)
+	return tilePos.X/CurrentFile.TileWidth + (tilePos.Y/CurrentFile.TileHeight)*(CurrentFile.CanvasWidth/CurrentFile.TileWidth)
+}
+
+// Select selects the tiles first to last, out of range tiles clear it
+func (t *TilesTool) Select(first, last int32) {
+	if first < 0 || last < first || last >= CurrentFile.TileCount() {
+		first, last = -1, -1
+	}
+	t.First, t.Last = first, last
+	if t.onChange != nil {
+		t.onChange()
+	}
+}
+
+// MouseDown is for mouse down events
+func (t *TilesTool) MouseDown(x, y int32, button MouseButton) {
+	if !t.down {
+		t.down = true
+		index := tileIndexAt(x, y)
+		t.dragging = t.First >= 0 && index >= t.First && index <= t.Last
+	}
+	if !t.dragging {
+		t.selector.MouseDown(x, y, button)
+	}
+}
+
+// MouseUp is for mouse up events
+func (t *TilesTool) MouseUp(x, y int32, button MouseButton) {
+	if !t.down {
+		return
+	}
+	t.down = false
+	if !t.dragging {
+		t.selector.MouseUp(x, y, button)
+		return
+	}
+	t.dragging = false
+
+	to := tileIndexAt(x, y)
+	if to >= t.First && to <= t.Last {
+		return
+	}
+	count := t.Last - t.First
+	if isBindingDown("swapTiles") {
+		if err := CurrentFile.SwapTiles(t.First, to); err != nil {
+			UIShowError(err)
+			return
+		}
+		t.Select(to, to)
+		return
+	}
+	to = MinInt32(to, CurrentFile.TileCount()-1-count)
+	if err := CurrentFile.MoveTiles(t.First, t.Last, to); err != nil {
+		UIShowError(err)
+		return
+	}
+	t.Select(to, to+count)
+}
+
+// drawTile draws a rectangle over the tile at the sheet position
+func drawTile(index int32, color rl.Color) {
+	origin := CurrentFile.TileOrigin(index)
+	rl.DrawRectangle(origin.X, origin.Y, CurrentFile.TileWidth, CurrentFile.TileHeight, color)
+}
+
+// DrawPreview is for drawing the preview
+func (t *TilesTool) DrawPreview(x, y int32) {
+	if t.dragging {
+		rl.ClearBackground(rl.Blank)
+		for i := t.First; i <= t.Last; i++ {
+			drawTile(i, rl.NewColor(255, 161, 0, 96))
+		}
+		drawTile(tileIndexAt(x, y), rl.NewColor(0, 121, 241, 128))
+		return
+	}
+
+	t.selector.DrawPreview(x, y)
+	if !t.selector.firstDown && t.First >= 0 {
+		for i := t.First; i <= t.Last; i++ {
+			drawTile(i, rl.NewColor(255, 161, 0, 96))
+		}
+	}
+}
+
+// DrawUI is for drawing the UI
+func (t *TilesTool) DrawUI(camera rl.Camera2D) {
+
+}
+
+func (t *TilesTool) String() string {
+	return t.name
+}
@@ -0,0 +1,431 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"math"
+
+	rl "github.com/gen2brain/raylib-go/raylib"
+)
+
+// ScaleMode is how the pixels of a transformed selection are resampled
+type ScaleMode int32
+
+// Scale modes
+const (
+	// ScaleModeNearest repeats or drops pixels
+	ScaleModeNearest ScaleMode = iota
+	// ScaleModeScale2x enlarges with Scale2x/EPX, which rounds off diagonal
+	// edges, before picking the nearest pixel
+	ScaleModeScale2x
+	// ScaleModeRotSprite scales like nearest neighbour but rotates by picking
+	// pixels from an 8x Scale2x enlargement, which keeps lines cleaner
+	ScaleModeRotSprite
+	// ScaleModeScale3x enlarges with Scale3x before picking the nearest pixel
+	ScaleModeScale3x
+	// ScaleModeXBR enlarges with 2xBR, which follows shallower edges than
+	// Scale2x. Pixels aren't blended so no new colors are made
+	ScaleModeXBR
+)
+
+func (m ScaleMode) String() string {
+	switch m {
+	case ScaleModeNearest:
+		return "nearest"
+	case ScaleModeScale2x:
+		return "scale2x"
+	case ScaleModeRotSprite:
+		return "rotsprite"
+	case ScaleModeScale3x:
+		return "scale3x"
+	case ScaleModeXBR:
+		return "xbr"
+	}
+	return "unknown"
+}
+
+// TransformParams are what's done to the selection. The source is flipped,
+// scaled to Width by Height with its top left at X, Y, then rotated around
+// its middle
+type TransformParams struct {
+	X, Y, Width, Height int32
+	FlipH, FlipV        bool
+	// Rotation is in degrees clockwise
+	Rotation int32
+	Mode     ScaleMode
+}
+
+// SelectionTransform keeps the selection as it was before being transformed
+// so that each change starts from the original pixels instead of the result
+// of the last change. Nothing is lost until the selection is committed
+type SelectionTransform struct {
+	Params TransformParams
+
+	source  transformGrid
+	applied TransformParams
+}
+
+// transformCell is a pixel of the selection's bounds, In is false for pixels
+// outside its shape
+type transformCell struct {
+	Color rl.Color
+	In    bool
+}
+
+// transformGrid is a rectangle of cells, row by row
+type transformGrid struct {
+	Width, Height int32
+	Cells         []transformCell
+}
+
+func newTransformGrid(width, height int32) transformGrid {
+	return transformGrid{width, height, make([]transformCell, width*height)}
+}
+
+// at returns the cell, clamping to the nearest edge
+func (g transformGrid) at(x, y int32) transformCell {
+	x = MinInt32(MaxInt32(x, 0), g.Width-1)
+	y = MinInt32(MaxInt32(y, 0), g.Height-1)
+	return g.Cells[y*g.Width+x]
+}
+
+// flip returns the grid mirrored
+func (g transformGrid) flip(horizontal, vertical bool) transformGrid {
+	if !horizontal && !vertical {
+		return g
+	}
+	out := newTransformGrid(g.Width, g.Height)
+	for y := int32(0); y < g.Height; y++ {
+		for x := int32(0); x < g.Width; x++ {
+			sx, sy := x, y
+			if horizontal {
+				sx = g.Width - 1 - x
+			}
+			if vertical {
+				sy = g.Height - 1 - y
+			}
+			out.Cells[y*g.Width+x] = g.Cells[sy*g.Width+sx]
+		}
+	}
+	return out
+}
+
+// nearest returns the grid resized by picking the nearest cell
+func (g transformGrid) nearest(width, height int32) transformGrid {
+	if width == g.Width && height == g.Height {
+		return g
+	}
+	out := newTransformGrid(width, height)
+	for y := int32(0); y < height; y++ {
+		for x := int32(0); x < width; x++ {
+			out.Cells[y*width+x] = g.at(x*g.Width/width, y*g.Height/height)
+		}
+	}
+	return out
+}
+
+// scale2x returns the grid twice the size using Scale2x/EPX. Each cell is
+// split into four, and corners take the color of matching neighbours
+func (g transformGrid) scale2x() transformGrid {
+	out := newTransformGrid(g.Width*2, g.Height*2)
+	for y := int32(0); y < g.Height; y++ {
+		for x := int32(0); x < g.Width; x++ {
+			p := g.at(x, y)
+			a, b := g.at(x, y-1), g.at(x+1, y)
+			c, d := g.at(x-1, y), g.at(x, y+1)
+
+			e0, e1, e2, e3 := p, p, p, p
+			if c == a && c != d && a != b {
+				e0 = a
+			}
+			if a == b && a != c && b != d {
+				e1 = b
+			}
+			if d == c && d != b && c != a {
+				e2 = c
+			}
+			if b == d && b != a && d != c {
+				e3 = d
+			}
+
+			i := y*2*out.Width + x*2
+			out.Cells[i], out.Cells[i+1] = e0, e1
+			out.Cells[i+out.Width], out.Cells[i+out.Width+1] = e2, e3
+		}
+	}
+	return out
+}
+
+// scale3x returns the grid three times the size using Scale3x
+func (g transformGrid) scale3x() transformGrid {
+	out := newTransformGrid(g.Width*3, g.Height*3)
+	for y := int32(0); y < g.Height; y++ {
+		for x := int32(0); x < g.Width; x++ {
+			a, b, c := g.at(x-1, y-1), g.at(x, y-1), g.at(x+1, y-1)
+			d, e, f := g.at(x-1, y), g.at(x, y), g.at(x+1, y)
+			gg, h, i := g.at(x-1, y+1), g.at(x, y+1), g.at(x+1, y+1)
+
+			e0, e1, e2 := e, e, e
+			e3, e5 := e, e
+			e6, e7, e8 := e, e, e
+			if d == b && b != f && d != h {
+				e0 = d
+			}
+			if d == b && b != f && d != h && e != c || b == f && b != d && f != h && e != a {
+				e1 = b
+			}
+			if b == f && b != d && f != h {
+				e2 = f
+			}
+			if d == b && b != f && d != h && e != gg || d == h && d != b && h != f && e != a {
+				e3 = d
+			}
+			if b == f && b != d && f != h && e != i || h == f && d != h && b != f && e != c {
+				e5 = f
+			}
+			if d == h && d != b && h != f {
+				e6 = d
+			}
+			if d == h && d != b && h != f && e != i || h == f && d != h && b != f && e != gg {
+				e7 = h
+			}
+			if h == f && d != h && b != f {
+				e8 = f
+			}
+
+			row := y * 3 * out.Width
+			for j, cell := range []transformCell{e0, e1, e2, e3, e, e5, e6, e7, e8} {
+				out.Cells[row+int32(j/3)*out.Width+x*3+int32(j%3)] = cell
+			}
+		}
+	}
+	return out
+}
+
+// cellDistance returns how different two cells look, weighted by brightness
+// more than hue like xBR does
+func cellDistance(a, b transformCell) float64 {
+	if a.In != b.In {
+		return 255 * 48
+	}
+	yuv := func(c rl.Color) (float64, float64, float64) {
+		r, g, b := float64(c.R), float64(c.G), float64(c.B)
+		return 0.299*r + 0.587*g + 0.114*b, -0.169*r - 0.331*g + 0.5*b, 0.5*r - 0.419*g - 0.081*b
+	}
+	ay, au, av := yuv(a.Color)
+	by, bu, bv := yuv(b.Color)
+	return 48*math.Abs(ay-by) + 7*math.Abs(au-bu) + 6*math.Abs(av-bv) + 48*math.Abs(float64(a.Color.A)-float64(b.Color.A))
+}
+
+// xbr2x returns the grid twice the size using 2xBR. Each corner of a cell
+// takes the color across an edge if the edge runs diagonally through it
+func (g transformGrid) xbr2x() transformGrid {
+	out := newTransformGrid(g.Width*2, g.Height*2)
+	for y := int32(0); y < g.Height; y++ {
+		for x := int32(0); x < g.Width; x++ {
+			e := g.at(x, y)
+			for corner := int32(0); corner < 4; corner++ {
+				// Neighbours named as if this is the bottom right corner
+				dx, dy := corner%2*2-1, corner/2*2-1
+				at := func(rx, ry int32) transformCell {
+					return g.at(x+rx*dx, y+ry*dy)
+				}
+				b, c, d := at(0, -1), at(1, -1), at(-1, 0)
+				f, gg, h, i := at(1, 0), at(-1, 1), at(0, 1), at(1, 1)
+				f4, h5, i4, i5 := at(2, 0), at(0, 2), at(2, 1), at(1, 2)
+
+				result := e
+				across := cellDistance(e, c) + cellDistance(e, gg) + cellDistance(i, f4) + cellDistance(i, h5) + 4*cellDistance(h, f)
+				along := cellDistance(h, d) + cellDistance(h, i5) + cellDistance(f, i4) + cellDistance(f, b) + 4*cellDistance(e, i)
+				if across < along && e != f && e != h {
+					if cellDistance(e, f) <= cellDistance(e, h) {
+						result = f
+					} else {
+						result = h
+					}
+				}
+				out.Cells[(y*2+corner/2)*out.Width+x*2+corner%2] = result
+			}
+		}
+	}
+	return out
+}
+
+// scale returns the grid resized with the scale mode
+func (g transformGrid) scale(width, height int32, mode ScaleMode) transformGrid {
+	// Enlargements are only applied a few times, large ones are blocky
+	for i := 0; i < 3 && (g.Width < width || g.Height < height); i++ {
+		switch mode {
+		case ScaleModeScale2x:
+			g = g.scale2x()
+		case ScaleModeScale3x:
+			g = g.scale3x()
+		case ScaleModeXBR:
+			g = g.xbr2x()
+		}
+	}
+	return g.nearest(width, height)
+}
+
+// rotate90 returns the grid turned clockwise a quarter turn times
+func (g transformGrid) rotate90(turns int32) transformGrid {
+	turns = (turns%4 + 4) % 4
+	for ; turns > 0; turns-- {
+		out := newTransformGrid(g.Height, g.Width)
+		for y := int32(0); y < g.Height; y++ {
+			for x := int32(0); x < g.Width; x++ {
+				out.Cells[x*out.Width+(out.Width-1-y)] = g.Cells[y*g.Width+x]
+			}
+		}
+		g = out
+	}
+	return g
+}
+
+// rotate returns the grid turned clockwise by degrees around its middle,
+// and where its top left is compared to the unrotated grid
+func (g transformGrid) rotate(degrees int32, mode ScaleMode) (transformGrid, IntVec2) {
+	degrees = (degrees%360 + 360) % 360
+	if degrees%90 == 0 {
+		out := g.rotate90(degrees / 90)
+		return out, IntVec2{(g.Width - out.Width) / 2, (g.Height - out.Height) / 2}
+	}
+
+	// Each pixel of the result takes the pixel it came from
+	sample, k := g, int32(1)
+	if mode == ScaleModeRotSprite {
+		sample, k = g.scale2x().scale2x().scale2x(), 8
+	}
+	angle := float64(degrees) * math.Pi / 180
+	sin, cos := math.Sin(angle), math.Cos(angle)
+	w, h := float64(g.Width), float64(g.Height)
+	ex := (math.Abs(w*cos) + math.Abs(h*sin)) / 2
+	ey := (math.Abs(w*sin) + math.Abs(h*cos)) / 2
+	x0, y0 := int32(math.Floor(w/2-ex)), int32(math.Floor(h/2-ey))
+	x1, y1 := int32(math.Ceil(w/2+ex)), int32(math.Ceil(h/2+ey))
+
+	out := newTransformGrid(x1-x0, y1-y0)
+	for y := y0; y < y1; y++ {
+		for x := x0; x < x1; x++ {
+			rx, ry := float64(x)+0.5-w/2, float64(y)+0.5-h/2
+			u := (cos*rx + sin*ry + w/2) * float64(k)
+			v := (-sin*rx + cos*ry + h/2) * float64(k)
+			if u < 0 || v < 0 || u >= float64(sample.Width) || v >= float64(sample.Height) {
+				continue
+			}
+			out.Cells[(y-y0)*out.Width+x-x0] = sample.Cells[int32(v)*sample.Width+int32(u)]
+		}
+	}
+	return out, IntVec2{x0, y0}
+}
+
+// BeginSelectionTransform lifts the selection and keeps it as the source of
+// a transform, if there isn't already one. It returns nil if nothing is
+// selected
+func (f *File) BeginSelectionTransform() *SelectionTransform {
+	if f.SelectionTransform != nil {
+		return f.SelectionTransform
+	}
+	if !f.DoingSelection || len(f.Selection) == 0 {
+		return nil
+	}
+	f.MoveSelection(0, 0)
+
+	x0 := MinInt32(f.SelectionBounds[0], f.SelectionBounds[2])
+	y0 := MinInt32(f.SelectionBounds[1], f.SelectionBounds[3])
+	x1 := MaxInt32(f.SelectionBounds[0], f.SelectionBounds[2])
+	y1 := MaxInt32(f.SelectionBounds[1], f.SelectionBounds[3])
+	source := newTransformGrid(x1-x0+1, y1-y0+1)
+	for y := y0; y <= y1; y++ {
+		for x := x0; x <= x1; x++ {
+			color, ok := f.Selection[IntVec2{x, y}]
+			source.Cells[(y-y0)*source.Width+x-x0] = transformCell{color, ok}
+		}
+	}
+
+	params := TransformParams{X: x0, Y: y0, Width: source.Width, Height: source.Height}
+	f.SelectionTransform = &SelectionTransform{Params: params, source: source, applied: params}
+	return f.SelectionTransform
+}
+
+// EndSelectionTransform keeps the transformed pixels as they are, the next
+// transform starts from them
+func (f *File) EndSelectionTransform() {
+	f.SelectionTransform = nil
+}
+
+// UpdateSelectionTransform redraws the selection if the transform has
+// changed since it was last drawn. A size larger than MaxCanvasSize is
+// reduced to it and returns an error
+func (f *File) UpdateSelectionTransform() error {
+	t := f.SelectionTransform
+	if t == nil || t.Params == t.applied {
+		return nil
+	}
+	var err error
+	if t.Params.Width > MaxCanvasSize || t.Params.Height > MaxCanvasSize {
+		err = fmt.Errorf("Couldn't transform selection: The selection can't be larger than %dx%d", MaxCanvasSize, MaxCanvasSize)
+	}
+	t.Params.Width = MinInt32(MaxInt32(t.Params.Width, 1), MaxCanvasSize)
+	t.Params.Height = MinInt32(MaxInt32(t.Params.Height, 1), MaxCanvasSize)
+	t.Params.Rotation = (t.Params.Rotation%360 + 360) % 360
+	t.applied = t.Params
+
+	p := t.Params
+	grid, offset := t.source.flip(p.FlipH, p.FlipV).scale(p.Width, p.Height, p.Mode).rotate(p.Rotation, p.Mode)
+
+	f.Selection = make(map[IntVec2]rl.Color)
+	f.SelectionPixels = make([]rl.Color, 0, len(grid.Cells))
+	for i, cell := range grid.Cells {
+		if cell.In {
+			f.Selection[IntVec2{p.X + offset.X + int32(i)%grid.Width, p.Y + offset.Y + int32(i)/grid.Width}] = cell.Color
+		}
+		f.SelectionPixels = append(f.SelectionPixels, cell.Color)
+	}
+	f.SelectionBounds = [4]int32{p.X + offset.X, p.Y + offset.Y, p.X + offset.X + grid.Width - 1, p.Y + offset.Y + grid.Height - 1}
+	f.OrigSelectionBounds = f.SelectionBounds
+	return err
+}
+
+// FlipSelectionTransform mirrors the transformed selection on the screen.
+// Mirroring undoes the direction of the rotation
+func (f *File) FlipSelectionTransform(horizontal bool) {
+	t := f.BeginSelectionTransform()
+	if t == nil {
+		return
+	}
+	if horizontal {
+		t.Params.FlipH = !t.Params.FlipH
+	} else {
+		t.Params.FlipV = !t.Params.FlipV
+	}
+	t.Params.Rotation = -t.Params.Rotation
+	if err := f.UpdateSelectionTransform(); err != nil {
+		log.Println(err)
+	}
+}
+
+// transformCorners returns the screen position of the corners and the middle
+// of the edges of the unrotated rectangle, rotated, in ResizeDirection order.
+// ResizeCC is the middle of the rectangle
+func (t *SelectionTransform) transformCorners(camera rl.Camera2D) [9]rl.Vector2 {
+	p := t.Params
+	angle := float64(p.Rotation) * math.Pi / 180
+	sin, cos := float32(math.Sin(angle)), float32(math.Cos(angle))
+	cx := float32(p.X) + float32(p.Width)/2 - float32(CurrentFile.CanvasWidth)/2
+	cy := float32(p.Y) + float32(p.Height)/2 - float32(CurrentFile.CanvasHeight)/2
+
+	var points [9]rl.Vector2
+	for i := range points {
+		hx, hy := resizeHandleSide(ResizeDirection(i))
+		lx, ly := hx*float32(p.Width)/2, hy*float32(p.Height)/2
+		points[i] = rl.GetWorldToScreen2D(rl.NewVector2(cx+lx*cos-ly*sin, cy+lx*sin+ly*cos), camera)
+	}
+	return points
+}
+
+// resizeHandleSide returns -1, 0 or 1 for the left, middle or right and the
+// top, middle or bottom of the handle
+func resizeHandleSide(dir ResizeDirection) (float32, float32) {
+	return float32(int32(dir)%3 - 1), float32(int32(dir)/3 - 1)
+}
@@ -826,3 +826,12 @@ func NewScrollableList(bounds rl.Rectangle, children []*Entity, flowDirection La
 	e.FlowChildren()
 	return e
 }
+
+// setTextLabel sets the text of a text entity, such as a button or input
+func setTextLabel(entity *Entity, label string) {
+	if drawable, ok := entity.GetDrawable(); ok {
+		if drawableText, ok := drawable.DrawableType.(*DrawableText); ok {
+			drawableText.Label = label
+		}
+	}
+}
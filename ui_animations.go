@@ -16,6 +16,9 @@ var (
 
 // AnimationsUIRebuildList rebuilds the list
 func AnimationsUIRebuildList() {
+	if animationsListContainer == nil {
+		return
+	}
 	animationsList.DestroyNested()
 	animationsList.Destroy()
 	animationsListContainer.RemoveChild(animationsList)
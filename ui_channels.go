@@ -0,0 +1,357 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+
+	rl "github.com/gen2brain/raylib-go/raylib"
+)
+
+// colorChannel is a single numeric channel of a color model
+type colorChannel struct {
+	Name  string
+	Max   float64
+	Value float64
+
+	input  *Entity
+	slider *Entity
+}
+
+// colorModel converts between a color and its channel values. The channel
+// values are in the range 0 to colorChannel.Max
+type colorModel struct {
+	Name      string
+	Channels  []*colorChannel
+	FromColor func(color rl.Color) []float64
+	ToColor   func(values []float64, alpha uint8) rl.Color
+}
+
+var (
+	channelsButtons   *Entity
+	channelsReadout   *Entity
+	channelsTarget    *Entity
+	channelsModels    []*colorModel
+	channelsEditRight bool
+
+	// The model being edited isn't refreshed from the color, otherwise values
+	// like the hue are lost when converting back from RGB
+	channelsEditingModel *colorModel
+
+	channelsInputWidth  = UIFontSize * 3
+	channelsSliderWidth = UIFontSize * 8
+	channelsRowHeight   = UIFontSize * 1.5
+)
+
+// ChannelsUIShowDialog shows the dialog
+func ChannelsUIShowDialog() {
+	channelsButtons.Show()
+	ChannelsUIRefresh()
+}
+
+// ChannelsUIHideDialog hides the dialog
+func ChannelsUIHideDialog() {
+	channelsButtons.Hide()
+}
+
+// channelsUICurrentColor returns the color being edited
+func channelsUICurrentColor() rl.Color {
+	if channelsEditRight {
+		return RightColor
+	}
+	return LeftColor
+}
+
+// channelsUISetColor sets the color being edited and updates the rest of the
+// color UI
+func channelsUISetColor(model *colorModel) {
+	values := make([]float64, len(model.Channels))
+	for i, channel := range model.Channels {
+		values[i] = channel.Value
+	}
+	color := model.ToColor(values, channelsUICurrentColor().A)
+
+	channelsEditingModel = model
+	PaletteUIHideCurrentColorIndicator()
+	if channelsEditRight {
+		CurrentColorSetRightColor(color)
+	} else {
+		CurrentColorSetLeftColor(color)
+		SetUIColors(color)
+	}
+	makeBlendArea(color)
+	makeOpacitySliderArea(color)
+	channelsEditingModel = nil
+}
+
+// channelsUIDrawSlider draws the gradient for a channel with the other
+// channels of the model held at their current values
+func channelsUIDrawSlider(model *colorModel, index int) {
+	channel := model.Channels[index]
+	if drawable, ok := channel.slider.GetDrawable(); ok {
+		if renderTexture, ok := drawable.DrawableType.(*DrawableRenderTexture); ok {
+			texture := renderTexture.Texture
+			w := texture.Texture.Width
+			h := texture.Texture.Height
+
+			values := make([]float64, len(model.Channels))
+			for i, c := range model.Channels {
+				values[i] = c.Value
+			}
+
+			rl.BeginTextureMode(texture)
+			for px := int32(0); px < w; px++ {
+				values[index] = channel.Max * float64(px) / float64(w-1)
+				rl.DrawRectangle(px, 0, 1, h, model.ToColor(values, 255))
+			}
+
+			// Marker for the current value
+			mx := int32(math.Round(channel.Value / channel.Max * float64(w-1)))
+			rl.DrawRectangle(mx-2, 0, 4, h, rl.Black)
+			rl.DrawRectangle(mx-1, 0, 2, h, rl.White)
+			rl.EndTextureMode()
+		}
+	}
+}
+
+// channelsUISetInputLabel sets the text of a channel input
+func channelsUISetInputLabel(channel *colorChannel) {
+	if drawable, ok := channel.input.GetDrawable(); ok {
+		if drawableText, ok := drawable.DrawableType.(*DrawableText); ok {
+			drawableText.Label = fmt.Sprint(int32(math.Round(channel.Value)))
+		}
+	}
+}
+
+// ChannelsUIRefresh updates every channel input, slider and the contrast
+// readout from LeftColor and RightColor. Nothing is updated while the dialog
+// is hidden, it's refreshed when it's shown
+func ChannelsUIRefresh() {
+	if channelsButtons == nil {
+		return
+	}
+	if drawable, ok := channelsButtons.GetDrawable(); !ok || drawable.Hidden {
+		return
+	}
+
+	color := channelsUICurrentColor()
+	for _, model := range channelsModels {
+		if model != channelsEditingModel {
+			for i, value := range model.FromColor(color) {
+				model.Channels[i].Value = value
+				channelsUISetInputLabel(model.Channels[i])
+			}
+		}
+		for i := range model.Channels {
+			channelsUIDrawSlider(model, i)
+		}
+	}
+
+	if drawable, ok := channelsReadout.GetDrawable(); ok {
+		if drawableText, ok := drawable.DrawableType.(*DrawableText); ok {
+			ratio := ContrastRatio(LeftColor, RightColor)
+			drawableText.Label = fmt.Sprintf("luminance %.2f / %.2f  contrast %.2f:1 %s",
+				RelativeLuminance(LeftColor), RelativeLuminance(RightColor), ratio, ContrastRating(ratio))
+		}
+	}
+}
+
+// channelsUIMakeInput makes the numeric input for a channel, tabbing moves to
+// the input returned by tabNext
+func channelsUIMakeInput(model *colorModel, channel *colorChannel, tabNext func() *Entity) *Entity {
+	return NewInput(rl.NewRectangle(0, 0, channelsInputWidth, channelsRowHeight), "0", TextAlignCenter, false,
+		func(entity *Entity, button MouseButton) {
+			// button up
+		}, nil,
+		func(entity *Entity, key Key) {
+			// key pressed
+			if drawable, ok := entity.GetDrawable(); ok {
+				if drawableText, ok := drawable.DrawableType.(*DrawableText); ok {
+					alterValue := func() {
+						if parsed, err := strconv.ParseFloat(drawableText.Label, 64); err == nil {
+							channel.Value = math.Min(parsed, channel.Max)
+							channelsUISetColor(model)
+						}
+					}
+
+					switch {
+					case key >= 48 && key <= 57: // 0 to 9
+						drawableText.Label += string(rune(key))
+						alterValue()
+					case key == rl.KeyBackspace && len(drawableText.Label) > 0:
+						drawableText.Label = drawableText.Label[:len(drawableText.Label)-1]
+						alterValue()
+					case key == rl.KeyTab:
+						RemoveCapturedInput()
+						if next := tabNext(); next != nil {
+							if interactable, ok := next.GetInteractable(); ok {
+								SetCapturedInput(next, interactable)
+							}
+						}
+					case key == rl.KeyEnter:
+						RemoveCapturedInput()
+						channelsUISetInputLabel(channel)
+					}
+				}
+			}
+		})
+}
+
+// channelsUIMakeSlider makes the slider for a channel
+func channelsUIMakeSlider(model *colorModel, channel *colorChannel) *Entity {
+	return NewRenderTexture(rl.NewRectangle(0, 0, channelsSliderWidth, channelsRowHeight),
+		func(entity *Entity, button MouseButton) {
+			// button up
+		},
+		func(entity *Entity, button MouseButton, isHeld bool) {
+			// button down
+			if moveable, ok := entity.GetMoveable(); ok {
+				p := (float32(rl.GetMouseX()) - moveable.Bounds.X) / (moveable.Bounds.Width - 1)
+				channel.Value = channel.Max * clamp01(float64(p))
+				channelsUISetInputLabel(channel)
+				channelsUISetColor(model)
+			}
+		})
+}
+
+// newColorModels returns the color models shown in the dialog
+func newColorModels() []*colorModel {
+	return []*colorModel{
+		{
+			Name:     "RGB",
+			Channels: []*colorChannel{{Name: "R", Max: 255}, {Name: "G", Max: 255}, {Name: "B", Max: 255}},
+			FromColor: func(color rl.Color) []float64 {
+				return []float64{float64(color.R), float64(color.G), float64(color.B)}
+			},
+			ToColor: func(values []float64, alpha uint8) rl.Color {
+				return rl.NewColor(floatToUint8(values[0]/255), floatToUint8(values[1]/255), floatToUint8(values[2]/255), alpha)
+			},
+		},
+		{
+			Name:     "HSV",
+			Channels: []*colorChannel{{Name: "H", Max: 360}, {Name: "S", Max: 100}, {Name: "V", Max: 100}},
+			FromColor: func(color rl.Color) []float64 {
+				h, s, v := ColorToHSV(color)
+				return []float64{h, s * 100, v * 100}
+			},
+			ToColor: func(values []float64, alpha uint8) rl.Color {
+				return HSVToColor(values[0], values[1]/100, values[2]/100, alpha)
+			},
+		},
+		{
+			Name:     "HSL",
+			Channels: []*colorChannel{{Name: "H", Max: 360}, {Name: "S", Max: 100}, {Name: "L", Max: 100}},
+			FromColor: func(color rl.Color) []float64 {
+				h, s, l := ColorToHSL(color)
+				return []float64{h, s * 100, l * 100}
+			},
+			ToColor: func(values []float64, alpha uint8) rl.Color {
+				return HSLToColor(values[0], values[1]/100, values[2]/100, alpha)
+			},
+		},
+		{
+			// Chroma is shown as 0-37 since sRGB colors don't go past 0.37
+			Name:     "OKLCH",
+			Channels: []*colorChannel{{Name: "L", Max: 100}, {Name: "C", Max: 37}, {Name: "H", Max: 360}},
+			FromColor: func(color rl.Color) []float64 {
+				l, c, h := ColorToOKLCH(color)
+				return []float64{l * 100, math.Min(c*100, 37), h}
+			},
+			ToColor: func(values []float64, alpha uint8) rl.Color {
+				return OKLCHToColor(values[0]/100, values[1]/100, values[2], alpha)
+			},
+		},
+	}
+}
+
+// NewChannelsUI returns the dialog with numeric channel inputs and sliders
+// for the current colors
+func NewChannelsUI() *Entity {
+	cx := rl.GetScreenWidth() / 2
+	cy := rl.GetScreenHeight() / 2
+
+	bounds := rl.NewRectangle(
+		float32(cx)-UIFontSize*25,
+		float32(cy)-UIFontSize*5,
+		float32(rl.GetScreenWidth()),
+		float32(rl.GetScreenHeight()),
+	)
+
+	rowWidth := UIFontSize + channelsInputWidth + channelsSliderWidth
+	channelsModels = newColorModels()
+
+	// Every input, in order, for tabbing
+	inputs := make([]*Entity, 0, 12)
+	modelBoxes := make([]*Entity, 0, len(channelsModels))
+	for _, model := range channelsModels {
+		rows := []*Entity{
+			NewButtonText(rl.NewRectangle(0, 0, rowWidth, channelsRowHeight),
+				model.Name, TextAlignLeft, false, nil, nil),
+		}
+		for _, channel := range model.Channels {
+			n := len(inputs) + 1
+			channel.input = channelsUIMakeInput(model, channel, func() *Entity {
+				return inputs[n%len(inputs)]
+			})
+			channel.slider = channelsUIMakeSlider(model, channel)
+			inputs = append(inputs, channel.input)
+
+			rows = append(rows, NewBox(rl.NewRectangle(0, 0, rowWidth, channelsRowHeight), []*Entity{
+				NewButtonText(rl.NewRectangle(0, 0, UIFontSize, channelsRowHeight),
+					channel.Name, TextAlignCenter, false, nil, nil),
+				channel.input,
+				channel.slider,
+			}, FlowDirectionHorizontal))
+		}
+		modelBoxes = append(modelBoxes, NewBox(
+			rl.NewRectangle(0, 0, rowWidth+UIFontSize, channelsRowHeight*float32(len(rows))),
+			rows, FlowDirectionVertical))
+	}
+
+	closeChannelsButton := NewButtonText(
+		rl.NewRectangle(0, 0, UIButtonHeight, channelsRowHeight),
+		"X", TextAlignCenter, false, func(entity *Entity, button MouseButton) {
+			ChannelsUIHideDialog()
+		}, nil)
+
+	channelsTarget = NewButtonText(
+		rl.NewRectangle(0, 0, UIFontSize*8, channelsRowHeight),
+		"editing: left", TextAlignCenter, false, func(entity *Entity, button MouseButton) {
+			channelsEditRight = !channelsEditRight
+			if drawable, ok := entity.GetDrawable(); ok {
+				if drawableText, ok := drawable.DrawableType.(*DrawableText); ok {
+					if channelsEditRight {
+						drawableText.Label = "editing: right"
+					} else {
+						drawableText.Label = "editing: left"
+					}
+				}
+			}
+			ChannelsUIRefresh()
+		}, nil)
+
+	columnsWidth := (rowWidth + UIFontSize) * 2
+	channelsReadout = NewButtonText(
+		rl.NewRectangle(0, 0, columnsWidth-UIButtonHeight-UIFontSize*8, channelsRowHeight),
+		"", TextAlignLeft, false, nil, nil)
+
+	channelsButtons = NewBox(
+		rl.NewRectangle(bounds.X, bounds.Y, columnsWidth, bounds.Height),
+		[]*Entity{
+			NewBox(rl.NewRectangle(0, 0, columnsWidth, channelsRowHeight), []*Entity{
+				closeChannelsButton,
+				channelsTarget,
+				channelsReadout,
+			}, FlowDirectionHorizontal),
+			NewBox(rl.NewRectangle(0, 0, columnsWidth, channelsRowHeight*4), modelBoxes[:2], FlowDirectionHorizontal),
+			NewBox(rl.NewRectangle(0, 0, columnsWidth, channelsRowHeight*4), modelBoxes[2:], FlowDirectionHorizontal),
+		},
+		FlowDirectionVertical,
+	)
+	channelsButtons.FlowChildren()
+
+	ChannelsUIRefresh()
+	ChannelsUIHideDialog()
+
+	return channelsButtons
+}
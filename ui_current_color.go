@@ -193,6 +193,8 @@ func SetUIColors(color rl.Color) {
 	MoveColorSelector(foundColor)
 	MoveOpacitySelector(float32(color.A) / 255)
 	MoveAreaSelector(float32(ax)/255, float32(ay)/255)
+
+	ChannelsUIRefresh()
 }
 
 // CurrentColorSetLeftColor sets the left color and updates the UI components
@@ -226,6 +228,7 @@ func CurrentColorSetLeftColor(color rl.Color) {
 	}
 
 	SetUIHexColor(color)
+	ChannelsUIRefresh()
 }
 
 // CurrentColorSetRightColor sets the right color and updates the UI components
@@ -9,6 +9,9 @@ var (
 
 // EditorsUIRebuild rebuilds the list of open editors
 func EditorsUIRebuild() {
+	if editorsButtons == nil {
+		return
+	}
 	editorsButtons.RemoveChildren()
 
 	for _, f := range Files {
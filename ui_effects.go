@@ -0,0 +1,303 @@
+package main
+
+import (
+	"fmt"
+
+	rl "github.com/gen2brain/raylib-go/raylib"
+)
+
+var (
+	effectsButtons       *Entity
+	effectsAddKind       *Entity
+	effectsListContainer *Entity
+	effectsList          *Entity
+	effectsListBounds    rl.Rectangle
+	effectsColor         *Entity
+	effectsInputs        []*Entity
+	effectsInside        *Entity
+	effectsDiagonal      *Entity
+	effectsPerTile       *Entity
+
+	// effectsUIAddKind is the kind of effect the add button adds
+	effectsUIAddKind = LayerEffectOutline
+	// effectsUILayer and effectsUISelected are the layer and the index of
+	// the effect being edited, effectsUISelected is -1 if there isn't one
+	effectsUILayer    int32
+	effectsUISelected int32 = -1
+	// effectsUIEffect is written to by the controls, and effectsUIApplied is
+	// the effect as it was last written to or read from the layer
+	effectsUIEffect  LayerEffect
+	effectsUIApplied LayerEffect
+
+	effectsLabelWidth   = UIFontSize * 8
+	effectsControlWidth = UIFontSize * 20
+)
+
+// EffectsUIShowDialog shows the effects of the current layer
+func EffectsUIShowDialog() {
+	effectsButtons.Show()
+	effectsUISelect(-1)
+}
+
+// EffectsUIHideDialog hides the dialog
+func EffectsUIHideDialog() {
+	effectsButtons.Hide()
+}
+
+// effectsUIShown returns true if the dialog is showing
+func effectsUIShown() bool {
+	if effectsButtons == nil {
+		return false
+	}
+	drawable, ok := effectsButtons.GetDrawable()
+	return ok && !drawable.Hidden
+}
+
+// effectsUISelect starts editing the effect on the current layer, -1 edits
+// nothing
+func effectsUISelect(index int32) {
+	effectsUILayer = CurrentFile.CurrentLayer
+	effects := CurrentFile.GetCurrentLayer().Effects
+	if index < 0 || index >= int32(len(effects)) {
+		index = -1
+		effectsUIEffect = LayerEffect{}
+	} else {
+		effectsUIEffect = copyLayerEffects(effects[index : index+1])[0]
+	}
+	effectsUISelected = index
+	effectsUIApplied = effectsUIEffect
+	effectsUIRebuild()
+}
+
+// EffectsUIUpdate writes the changes made in the controls to the layer, and
+// shows changes made to the layer some other way, such as by undoing. It's
+// called every frame
+func EffectsUIUpdate() {
+	if !effectsUIShown() {
+		return
+	}
+	if effectsUILayer != CurrentFile.CurrentLayer || effectsUILayer >= int32(len(CurrentFile.Layers)-1) {
+		effectsUISelect(-1)
+		return
+	}
+	effects := CurrentFile.Layers[effectsUILayer].Effects
+	if effectsUISelected >= int32(len(effects)) {
+		effectsUISelect(-1)
+		return
+	}
+	if effectsUISelected < 0 {
+		return
+	}
+
+	if !sameLayerEffect(effects[effectsUISelected], effectsUIApplied) {
+		effectsUISelect(effectsUISelected)
+		return
+	}
+	if !sameLayerEffect(effectsUIEffect, effectsUIApplied) {
+		effectsUIApplied = copyLayerEffects([]LayerEffect{effectsUIEffect})[0]
+		CurrentFile.SetLayerEffect(effectsUILayer, effectsUISelected, effectsUIApplied)
+	}
+}
+
+// effectsUIRefresh shows the effect being edited in the controls
+func effectsUIRefresh() {
+	setTextLabel(effectsAddKind, effectsUIAddKind.String())
+	setTextLabel(effectsColor, ColorToHex(effectsUIEffect.Color))
+	values := []int32{effectsUIEffect.OffsetX, effectsUIEffect.OffsetY, effectsUIEffect.Amount}
+	for i, input := range effectsInputs {
+		setTextLabel(input, fmt.Sprint(values[i]))
+	}
+	setTextLabel(effectsInside, map[bool]string{false: "outside", true: "inside"}[effectsUIEffect.Inside])
+	setTextLabel(effectsDiagonal, map[bool]string{false: "4-way", true: "8-way"}[effectsUIEffect.Diagonal])
+	setTextLabel(effectsPerTile, fmt.Sprint(effectsUIEffect.PerTile))
+}
+
+// effectsUIMakeList makes a row for each of the current layer's effects.
+// Clicking the name edits the effect
+func effectsUIMakeList() *Entity {
+	list := NewScrollableList(effectsListBounds, []*Entity{}, FlowDirectionVertical|FlowDirectionNoWrap)
+	if CurrentFile == nil {
+		return list
+	}
+
+	width := effectsListBounds.Width
+	for i, effect := range CurrentFile.GetCurrentLayer().Effects {
+		index := int32(i)
+		enabled := map[bool]string{false: "off", true: "on"}[effect.Enabled]
+		list.PushChild(NewBox(rl.NewRectangle(0, 0, width, UIButtonHeight), []*Entity{
+			NewButtonText(rl.NewRectangle(0, 0, UIButtonHeight*1.5, UIButtonHeight),
+				enabled, TextAlignCenter, false, func(entity *Entity, button MouseButton) {
+					toggled := CurrentFile.GetCurrentLayer().Effects[index]
+					toggled.Enabled = !toggled.Enabled
+					CurrentFile.SetLayerEffect(CurrentFile.CurrentLayer, index, toggled)
+					effectsUISelect(index)
+				}, nil),
+			NewButtonText(rl.NewRectangle(0, 0, width-UIButtonHeight*2.5, UIButtonHeight),
+				effect.Kind.String(), TextAlignLeft, index == effectsUISelected, func(entity *Entity, button MouseButton) {
+					effectsUISelect(index)
+				}, nil),
+			NewButtonText(rl.NewRectangle(0, 0, UIButtonHeight, UIButtonHeight),
+				"X", TextAlignCenter, false, func(entity *Entity, button MouseButton) {
+					CurrentFile.DeleteLayerEffect(CurrentFile.CurrentLayer, index)
+					effectsUISelect(-1)
+				}, nil),
+		}, FlowDirectionHorizontal))
+	}
+	list.FlowChildren()
+
+	return list
+}
+
+// effectsUIRebuild rebuilds the list of effects and the layers, and
+// refreshes the controls
+func effectsUIRebuild() {
+	if !effectsUIShown() {
+		return
+	}
+
+	effectsListContainer.RemoveChild(effectsList)
+	effectsList.DestroyNested()
+	effectsList.Destroy()
+
+	effectsList = effectsUIMakeList()
+	effectsListContainer.PushChild(effectsList)
+	effectsListContainer.FlowChildren()
+
+	effectsUIRefresh()
+	// The layers show whether they have effects
+	LayersUIRebuildList()
+}
+
+// NewEffectsUI returns the dialog for adding, editing and applying the
+// effects of the current layer
+func NewEffectsUI() *Entity {
+	cx := rl.GetScreenWidth() / 2
+	cy := rl.GetScreenHeight() / 2
+
+	width := effectsLabelWidth + effectsControlWidth
+	effectsListBounds = rl.NewRectangle(0, 0, width, UIButtonHeight*4)
+	bounds := rl.NewRectangle(
+		float32(cx)-width/2,
+		float32(cy)-UIButtonHeight*7.5,
+		width,
+		UIButtonHeight*15,
+	)
+
+	makeButton := func(onMouseUp func()) *Entity {
+		return NewButtonText(
+			rl.NewRectangle(0, 0, effectsControlWidth, UIButtonHeight),
+			"", TextAlignCenter, false, func(entity *Entity, button MouseButton) {
+				onMouseUp()
+				effectsUIRefresh()
+			}, nil)
+	}
+	makeRow := func(label string, control *Entity) *Entity {
+		return NewBox(rl.NewRectangle(0, 0, width, UIButtonHeight), []*Entity{
+			NewButtonText(rl.NewRectangle(0, 0, effectsLabelWidth, UIButtonHeight),
+				label, TextAlignLeft, false, nil, nil),
+			control,
+		}, FlowDirectionHorizontal)
+	}
+
+	addButton := NewButtonText(
+		rl.NewRectangle(0, 0, effectsLabelWidth, UIButtonHeight),
+		"add", TextAlignLeft, false, func(entity *Entity, button MouseButton) {
+			effect := NewLayerEffect(effectsUIAddKind)
+			switch effectsUIAddKind {
+			case LayerEffectColorOverlay:
+				effect.Color, effect.Amount = LeftColor, 50
+			case LayerEffectPaletteRemap:
+				effect.Palette = append([]rl.Color(nil), Settings.PaletteData[CurrentFile.CurrentPalette].data...)
+			case LayerEffectOpacity:
+				effect.Amount = 50
+			}
+			if err := CurrentFile.AddLayerEffect(CurrentFile.CurrentLayer, effect); err != nil {
+				UIShowError(err)
+				return
+			}
+			effectsUISelect(int32(len(CurrentFile.GetCurrentLayer().Effects) - 1))
+		}, nil)
+	effectsAddKind = makeButton(func() {
+		effectsUIAddKind = (effectsUIAddKind + 1) % (LayerEffectOpacity + 1)
+	})
+
+	effectsList = effectsUIMakeList()
+	effectsListContainer = NewBox(effectsListBounds, []*Entity{effectsList}, FlowDirectionVertical)
+
+	effectsColor = makeColorInput(effectsControlWidth, func(color rl.Color) {
+		effectsUIEffect.Color = color
+	})
+	makeColorButton := func(label string, onMouseUp func()) *Entity {
+		return NewButtonText(
+			rl.NewRectangle(0, 0, width/3, UIButtonHeight),
+			label, TextAlignCenter, false, func(entity *Entity, button MouseButton) {
+				onMouseUp()
+				effectsUIRefresh()
+			}, nil)
+	}
+	useLeftColor := makeColorButton("left color", func() { effectsUIEffect.Color = LeftColor })
+	useRightColor := makeColorButton("right color", func() { effectsUIEffect.Color = RightColor })
+	usePalette := makeColorButton("palette", func() {
+		effectsUIEffect.Palette = append([]rl.Color(nil), Settings.PaletteData[CurrentFile.CurrentPalette].data...)
+	})
+
+	// Made in reverse so that each can tab to the next
+	amountInput := ResizeUIMakeInput(func() *int32 { return &effectsUIEffect.Amount }, nil)
+	offsetYInput := ResizeUIMakeSignedInput(func() *int32 { return &effectsUIEffect.OffsetY }, amountInput)
+	offsetXInput := ResizeUIMakeSignedInput(func() *int32 { return &effectsUIEffect.OffsetX }, offsetYInput)
+	effectsInputs = []*Entity{offsetXInput, offsetYInput, amountInput}
+
+	effectsInside = makeButton(func() { effectsUIEffect.Inside = !effectsUIEffect.Inside })
+	effectsDiagonal = makeButton(func() { effectsUIEffect.Diagonal = !effectsUIEffect.Diagonal })
+	effectsPerTile = makeButton(func() { effectsUIEffect.PerTile = !effectsUIEffect.PerTile })
+
+	closeEffectsButton := NewButtonText(
+		rl.NewRectangle(0, 0, UIButtonHeight, UIButtonHeight),
+		"X", TextAlignCenter, false, func(entity *Entity, button MouseButton) {
+			EffectsUIHideDialog()
+		}, nil)
+	applyButton := NewButtonText(
+		rl.NewRectangle(0, 0, width, UIButtonHeight),
+		"apply effects", TextAlignCenter, false, func(entity *Entity, button MouseButton) {
+			if err := CurrentFile.ApplyLayerEffects(CurrentFile.CurrentLayer); err != nil {
+				UIShowError(err)
+				return
+			}
+			effectsUISelect(-1)
+		}, nil)
+
+	effectsButtons = NewBox(
+		bounds,
+		[]*Entity{
+			NewBox(rl.NewRectangle(0, 0, width, UIButtonHeight), []*Entity{
+				closeEffectsButton,
+				NewButtonText(rl.NewRectangle(0, 0, width-UIButtonHeight, UIButtonHeight),
+					"layer effects", TextAlignCenter, false, nil, nil),
+			}, FlowDirectionHorizontal),
+			NewBox(rl.NewRectangle(0, 0, width, UIButtonHeight), []*Entity{
+				addButton,
+				effectsAddKind,
+			}, FlowDirectionHorizontal),
+			effectsListContainer,
+			makeRow("color", effectsColor),
+			NewBox(rl.NewRectangle(0, 0, width, UIButtonHeight), []*Entity{
+				useLeftColor,
+				useRightColor,
+				usePalette,
+			}, FlowDirectionHorizontal),
+			makeRow("offset x", offsetXInput),
+			makeRow("offset y", offsetYInput),
+			makeRow("amount %", amountInput),
+			makeRow("outline", effectsInside),
+			makeRow("connect", effectsDiagonal),
+			makeRow("per tile", effectsPerTile),
+			applyButton,
+		},
+		FlowDirectionVertical,
+	)
+	effectsButtons.FlowChildren()
+
+	effectsButtons.Hide()
+
+	return effectsButtons
+}
@@ -0,0 +1,211 @@
+package main
+
+import (
+	"fmt"
+
+	rl "github.com/gen2brain/raylib-go/raylib"
+)
+
+var (
+	exportButtons *Entity
+	exportPath    *Entity
+	exportLayers  *Entity
+	exportRegion  *Entity
+	exportSplit   *Entity
+	exportTrim    *Entity
+	exportTmpl    *Entity
+
+	// exportUISettings are edited by the dialog and only given to the file
+	// once it has been exported
+	exportUISettings ExportSettings
+	// exportUIPending exports once a file has been chosen
+	exportUIPending bool
+
+	exportLabelWidth   = UIFontSize * 8
+	exportControlWidth = UIFontSize * 20
+)
+
+// ExportUIShowDialog shows the dialog with the settings the current file was
+// last exported with
+func ExportUIShowDialog() {
+	exportUISettings = CurrentFile.ExportSettings
+	exportUIPending = false
+	exportButtons.Show()
+	exportUIRefresh()
+}
+
+// ExportUIHideDialog hides the dialog
+func ExportUIHideDialog() {
+	exportButtons.Hide()
+}
+
+// ExportUIFinishChooseFile is called by the control system when the export
+// file dialog closes. path is empty if it was cancelled
+func ExportUIFinishChooseFile(path string) {
+	if len(path) == 0 {
+		exportUIPending = false
+		return
+	}
+	exportUISettings.Path = path
+	exportUIRefresh()
+
+	if exportUIPending {
+		exportUIPending = false
+		exportUIExport()
+	}
+}
+
+// exportUIExport exports the current file, asking where to first if it
+// hasn't been exported before
+func exportUIExport() {
+	if len(exportUISettings.Path) == 0 {
+		exportUIPending = true
+		UIExportChooseFile()
+		return
+	}
+	if err := CurrentFile.Export(exportUISettings); err != nil {
+		UIShowError(err)
+		return
+	}
+	ExportUIHideDialog()
+}
+
+// exportUIRefresh shows exportUISettings in the buttons
+func exportUIRefresh() {
+	path := exportUISettings.Path
+	if len(path) == 0 {
+		path = "choose..."
+	}
+	setTextLabel(exportPath, path)
+	setTextLabel(exportLayers, exportUISettings.Layers.String())
+	setTextLabel(exportRegion, exportUISettings.Region.String())
+	setTextLabel(exportSplit, exportUISettings.Split.String())
+	setTextLabel(exportTrim, fmt.Sprint(exportUISettings.Trim))
+	setTextLabel(exportTmpl, exportUISettings.Template)
+}
+
+// exportUIMakeTemplateInput makes the input for the filename template.
+// Shifted brackets and minus are typed as braces and an underscore
+func exportUIMakeTemplateInput() *Entity {
+	return NewInput(rl.NewRectangle(0, 0, exportControlWidth, UIButtonHeight), "", TextAlignCenter, false,
+		func(entity *Entity, button MouseButton) {
+			// button up
+		}, nil,
+		func(entity *Entity, key Key) {
+			// key pressed
+			if drawable, ok := entity.GetDrawable(); ok {
+				if drawableText, ok := drawable.DrawableType.(*DrawableText); ok {
+					shift := rl.IsKeyDown(rl.KeyLeftShift) || rl.IsKeyDown(rl.KeyRightShift)
+
+					switch {
+					case key == rl.KeyBackspace && len(drawableText.Label) > 0:
+						drawableText.Label = drawableText.Label[:len(drawableText.Label)-1]
+					case key == rl.KeyEnter:
+						RemoveCapturedInput()
+					case shift && key == rl.KeyLeftBracket:
+						drawableText.Label += "{"
+					case shift && key == rl.KeyRightBracket:
+						drawableText.Label += "}"
+					case shift && key == rl.KeyMinus:
+						drawableText.Label += "_"
+					case key >= rl.KeyA && key <= rl.KeyZ && !shift:
+						drawableText.Label += string(rune(key - rl.KeyA + 'a'))
+					case key >= 32 && key <= 126: // printable, including pasted text
+						drawableText.Label += string(rune(key))
+					}
+					exportUISettings.Template = drawableText.Label
+				}
+			}
+		})
+}
+
+// NewExportUI returns the dialog with the options for exporting
+func NewExportUI() *Entity {
+	cx := rl.GetScreenWidth() / 2
+	cy := rl.GetScreenHeight() / 2
+
+	width := exportLabelWidth + exportControlWidth
+	bounds := rl.NewRectangle(
+		float32(cx)-width/2,
+		float32(cy)-UIButtonHeight*6,
+		width,
+		UIButtonHeight*12,
+	)
+
+	makeButton := func(onMouseUp func()) *Entity {
+		return NewButtonText(
+			rl.NewRectangle(0, 0, exportControlWidth, UIButtonHeight),
+			"", TextAlignCenter, false, func(entity *Entity, button MouseButton) {
+				onMouseUp()
+				exportUIRefresh()
+			}, nil)
+	}
+	makeRow := func(label string, control *Entity) *Entity {
+		return NewBox(rl.NewRectangle(0, 0, width, UIButtonHeight), []*Entity{
+			NewButtonText(rl.NewRectangle(0, 0, exportLabelWidth, UIButtonHeight),
+				label, TextAlignLeft, false, nil, nil),
+			control,
+		}, FlowDirectionHorizontal)
+	}
+
+	exportPath = makeButton(func() {
+		UIExportChooseFile()
+	})
+	exportLayers = makeButton(func() {
+		exportUISettings.Layers = (exportUISettings.Layers + 1) % (ExportLayersEach + 1)
+	})
+	exportRegion = makeButton(func() {
+		exportUISettings.Region = (exportUISettings.Region + 1) % (ExportRegionSelection + 1)
+	})
+	exportSplit = makeButton(func() {
+		exportUISettings.Split = (exportUISettings.Split + 1) % (ExportSplitAnimations + 1)
+	})
+	exportTrim = makeButton(func() {
+		exportUISettings.Trim = !exportUISettings.Trim
+	})
+	exportTmpl = exportUIMakeTemplateInput()
+
+	scaleInput := ResizeUIMakeInput(func() *int32 { return &exportUISettings.Scale }, nil)
+	tilesInput := ResizeUIMakeInput(func() *int32 { return &exportUISettings.Tiles }, scaleInput)
+	firstTileInput := ResizeUIMakeInput(func() *int32 { return &exportUISettings.FirstTile }, tilesInput)
+
+	closeExportButton := NewButtonText(
+		rl.NewRectangle(0, 0, UIButtonHeight, UIButtonHeight),
+		"X", TextAlignCenter, false, func(entity *Entity, button MouseButton) {
+			exportUIPending = false
+			ExportUIHideDialog()
+		}, nil)
+
+	exportButton := NewButtonText(
+		rl.NewRectangle(0, 0, width, UIButtonHeight),
+		"export", TextAlignCenter, false, func(entity *Entity, button MouseButton) {
+			exportUIExport()
+		}, nil)
+
+	exportButtons = NewBox(
+		bounds,
+		[]*Entity{
+			NewBox(rl.NewRectangle(0, 0, width, UIButtonHeight), []*Entity{
+				closeExportButton,
+				NewButtonText(rl.NewRectangle(0, 0, width-UIButtonHeight, UIButtonHeight),
+					"export options", TextAlignCenter, false, nil, nil),
+			}, FlowDirectionHorizontal),
+			makeRow("file", exportPath),
+			makeRow("layers", exportLayers),
+			makeRow("region", exportRegion),
+			makeRow("first tile", firstTileInput),
+			makeRow("tiles (0=all)", tilesInput),
+			makeRow("split", exportSplit),
+			makeRow("scale", scaleInput),
+			makeRow("trim", exportTrim),
+			makeRow("template", exportTmpl),
+			exportButton,
+		},
+		FlowDirectionVertical,
+	)
+	exportButtons.FlowChildren()
+
+	ExportUIHideDialog()
+
+	return exportButtons
+}
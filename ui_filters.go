@@ -0,0 +1,149 @@
+package main
+
+import (
+	"fmt"
+
+	rl "github.com/gen2brain/raylib-go/raylib"
+)
+
+var (
+	filtersButtons *Entity
+	filtersKind    *Entity
+	filtersLabels  []*Entity
+	filtersInputs  []*Entity
+
+	// filtersUIKind is kept between uses of the dialog
+	filtersUIKind = FilterHueSaturationLightness
+	// filtersUIUnused is written to by the inputs when nothing is previewed
+	filtersUIUnused int32
+
+	filtersLabelWidth   = UIFontSize * 8
+	filtersControlWidth = UIFontSize * 20
+)
+
+// FiltersUIShowDialog shows the dialog and starts previewing the filter
+func FiltersUIShowDialog() {
+	CurrentFile.BeginFilterPreview(FilterParams{Kind: filtersUIKind, Values: filtersUIKind.Defaults()})
+	FiltersUIUpdate()
+	filtersButtons.Show()
+	filtersUIRefresh()
+}
+
+// FiltersUIHideDialog hides the dialog and stops previewing the filter
+func FiltersUIHideDialog() {
+	filtersButtons.Hide()
+	CurrentFile.EndFilterPreview()
+}
+
+// FiltersUIUpdate keeps the colors being replaced as the left and right
+// colors, it's called every frame
+func FiltersUIUpdate() {
+	if p := CurrentFile.FilterPreview; p != nil && p.Params.Kind == FilterReplaceColor {
+		p.Params.From, p.Params.To = LeftColor, RightColor
+	}
+}
+
+// filtersUIRefresh shows the filter and the names and values it uses
+func filtersUIRefresh() {
+	setTextLabel(filtersKind, filtersUIKind.String())
+	labels := filtersUIKind.Labels()
+	var values [3]int32
+	if p := CurrentFile.FilterPreview; p != nil {
+		values = p.Params.Values
+	}
+	for i := range filtersInputs {
+		if i < len(labels) {
+			setTextLabel(filtersLabels[i], labels[i])
+			setTextLabel(filtersInputs[i], fmt.Sprint(values[i]))
+		} else {
+			setTextLabel(filtersLabels[i], "")
+			setTextLabel(filtersInputs[i], "")
+		}
+	}
+}
+
+// NewFiltersUI returns the dialog for picking a filter and its values. The
+// filter is shown on the canvas until it's applied or the dialog is closed
+func NewFiltersUI() *Entity {
+	cx := rl.GetScreenWidth() / 2
+	cy := rl.GetScreenHeight() / 2
+
+	width := filtersLabelWidth + filtersControlWidth
+	bounds := rl.NewRectangle(
+		float32(cx)-width/2,
+		float32(cy)-UIButtonHeight*3,
+		width,
+		UIButtonHeight*6,
+	)
+
+	// Inputs which write to nothing if the preview has ended
+	bind := func(i int) func() *int32 {
+		return func() *int32 {
+			if CurrentFile.FilterPreview == nil || i >= len(filtersUIKind.Labels()) {
+				return &filtersUIUnused
+			}
+			return &CurrentFile.FilterPreview.Params.Values[i]
+		}
+	}
+
+	// Made in reverse so that each can tab to the next
+	var next *Entity
+	filtersInputs = make([]*Entity, 3)
+	filtersLabels = make([]*Entity, 3)
+	for i := len(filtersInputs) - 1; i >= 0; i-- {
+		filtersInputs[i] = ResizeUIMakeInput(bind(i), next)
+		filtersLabels[i] = NewButtonText(rl.NewRectangle(0, 0, filtersLabelWidth, UIButtonHeight),
+			"", TextAlignLeft, false, nil, nil)
+		next = filtersInputs[i]
+	}
+
+	filtersKind = NewButtonText(
+		rl.NewRectangle(0, 0, filtersControlWidth, UIButtonHeight),
+		"", TextAlignCenter, false, func(entity *Entity, button MouseButton) {
+			filtersUIKind = (filtersUIKind + 1) % (FilterReplaceColor + 1)
+			if p := CurrentFile.FilterPreview; p != nil {
+				p.Params = FilterParams{Kind: filtersUIKind, Values: filtersUIKind.Defaults()}
+			}
+			FiltersUIUpdate()
+			filtersUIRefresh()
+		}, nil)
+
+	closeFiltersButton := NewButtonText(
+		rl.NewRectangle(0, 0, UIButtonHeight, UIButtonHeight),
+		"X", TextAlignCenter, false, func(entity *Entity, button MouseButton) {
+			FiltersUIHideDialog()
+		}, nil)
+	applyButton := NewButtonText(
+		rl.NewRectangle(0, 0, width, UIButtonHeight),
+		"apply", TextAlignCenter, false, func(entity *Entity, button MouseButton) {
+			CurrentFile.ApplyFilterPreview()
+			FiltersUIHideDialog()
+		}, nil)
+
+	rows := []*Entity{
+		NewBox(rl.NewRectangle(0, 0, width, UIButtonHeight), []*Entity{
+			closeFiltersButton,
+			NewButtonText(rl.NewRectangle(0, 0, width-UIButtonHeight, UIButtonHeight),
+				"filters", TextAlignCenter, false, nil, nil),
+		}, FlowDirectionHorizontal),
+		NewBox(rl.NewRectangle(0, 0, width, UIButtonHeight), []*Entity{
+			NewButtonText(rl.NewRectangle(0, 0, filtersLabelWidth, UIButtonHeight),
+				"filter", TextAlignLeft, false, nil, nil),
+			filtersKind,
+		}, FlowDirectionHorizontal),
+	}
+	for i := range filtersInputs {
+		rows = append(rows, NewBox(rl.NewRectangle(0, 0, width, UIButtonHeight), []*Entity{
+			filtersLabels[i],
+			filtersInputs[i],
+		}, FlowDirectionHorizontal))
+	}
+	rows = append(rows, applyButton)
+
+	filtersButtons = NewBox(bounds, rows, FlowDirectionVertical)
+	filtersButtons.FlowChildren()
+
+	filtersButtons.Hide()
+
+	return filtersButtons
+}
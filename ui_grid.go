@@ -0,0 +1,123 @@
+package main
+
+import (
+	"fmt"
+
+	rl "github.com/gen2brain/raylib-go/raylib"
+)
+
+var (
+	gridButtons   *Entity
+	gridShow      *Entity
+	gridIsometric *Entity
+	gridSnap      *Entity
+
+	// gridUIBefore is compared with the grid when the dialog closes to know
+	// if the file has changed
+	gridUIBefore ReferenceGrid
+
+	gridLabelWidth   = UIFontSize * 8
+	gridControlWidth = UIFontSize * 20
+)
+
+// GridUIShowDialog shows the dialog with the current file's reference grid
+func GridUIShowDialog() {
+	gridUIBefore = CurrentFile.ReferenceGrid
+	gridButtons.Show()
+	gridUIRefresh()
+}
+
+// GridUIHideDialog hides the dialog
+func GridUIHideDialog() {
+	gridButtons.Hide()
+	if CurrentFile.ReferenceGrid != gridUIBefore {
+		CurrentFile.markChanged()
+	}
+}
+
+// gridUIRefresh shows the grid settings in the buttons
+func gridUIRefresh() {
+	setTextLabel(gridShow, fmt.Sprint(CurrentFile.ReferenceGrid.Show))
+	setTextLabel(gridIsometric, fmt.Sprint(CurrentFile.ReferenceGrid.Isometric))
+	setTextLabel(gridSnap, fmt.Sprint(Settings.ViewData.Snap))
+}
+
+// NewGridUI returns the dialog with the options for the reference grid,
+// isometric grid and snapping
+func NewGridUI() *Entity {
+	cx := rl.GetScreenWidth() / 2
+	cy := rl.GetScreenHeight() / 2
+
+	width := gridLabelWidth + gridControlWidth
+	bounds := rl.NewRectangle(
+		float32(cx)-width/2,
+		float32(cy)-UIButtonHeight*5,
+		width,
+		UIButtonHeight*10,
+	)
+
+	makeButton := func(onMouseUp func()) *Entity {
+		return NewButtonText(
+			rl.NewRectangle(0, 0, gridControlWidth, UIButtonHeight),
+			"", TextAlignCenter, false, func(entity *Entity, button MouseButton) {
+				onMouseUp()
+				gridUIRefresh()
+			}, nil)
+	}
+	makeRow := func(label string, control *Entity) *Entity {
+		return NewBox(rl.NewRectangle(0, 0, width, UIButtonHeight), []*Entity{
+			NewButtonText(rl.NewRectangle(0, 0, gridLabelWidth, UIButtonHeight),
+				label, TextAlignLeft, false, nil, nil),
+			control,
+		}, FlowDirectionHorizontal)
+	}
+
+	gridShow = makeButton(func() {
+		CurrentFile.ReferenceGrid.Show = !CurrentFile.ReferenceGrid.Show
+	})
+	gridIsometric = makeButton(func() {
+		CurrentFile.ReferenceGrid.Isometric = !CurrentFile.ReferenceGrid.Isometric
+	})
+	gridSnap = makeButton(func() {
+		Settings.ViewData.Snap = !Settings.ViewData.Snap
+		SaveSettings()
+	})
+
+	// Made in reverse so that each can tab to the next
+	isoWidthInput := ResizeUIMakeInput(func() *int32 { return &CurrentFile.ReferenceGrid.IsoWidth }, nil)
+	offsetYInput := ResizeUIMakeInput(func() *int32 { return &CurrentFile.ReferenceGrid.OffsetY }, isoWidthInput)
+	offsetXInput := ResizeUIMakeInput(func() *int32 { return &CurrentFile.ReferenceGrid.OffsetX }, offsetYInput)
+	heightInput := ResizeUIMakeInput(func() *int32 { return &CurrentFile.ReferenceGrid.Height }, offsetXInput)
+	widthInput := ResizeUIMakeInput(func() *int32 { return &CurrentFile.ReferenceGrid.Width }, heightInput)
+
+	closeGridButton := NewButtonText(
+		rl.NewRectangle(0, 0, UIButtonHeight, UIButtonHeight),
+		"X", TextAlignCenter, false, func(entity *Entity, button MouseButton) {
+			GridUIHideDialog()
+		}, nil)
+
+	gridButtons = NewBox(
+		bounds,
+		[]*Entity{
+			NewBox(rl.NewRectangle(0, 0, width, UIButtonHeight), []*Entity{
+				closeGridButton,
+				NewButtonText(rl.NewRectangle(0, 0, width-UIButtonHeight, UIButtonHeight),
+					"grid options", TextAlignCenter, false, nil, nil),
+			}, FlowDirectionHorizontal),
+			makeRow("show grid", gridShow),
+			makeRow("width", widthInput),
+			makeRow("height", heightInput),
+			makeRow("offset x", offsetXInput),
+			makeRow("offset y", offsetYInput),
+			makeRow("isometric", gridIsometric),
+			makeRow("iso width", isoWidthInput),
+			makeRow("snap", gridSnap),
+		},
+		FlowDirectionVertical,
+	)
+	gridButtons.FlowChildren()
+
+	gridButtons.Hide()
+
+	return gridButtons
+}
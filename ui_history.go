@@ -0,0 +1,105 @@
+package main
+
+import (
+	rl "github.com/gen2brain/raylib-go/raylib"
+)
+
+var (
+	historyButtons       *Entity
+	historyListContainer *Entity
+	historyList          *Entity
+	historyListBounds    rl.Rectangle
+)
+
+// HistoryUIShowDialog shows the dialog
+func HistoryUIShowDialog() {
+	historyButtons.Show()
+	HistoryUIRebuild()
+}
+
+// HistoryUIHideDialog hides the dialog
+func HistoryUIHideDialog() {
+	historyButtons.Hide()
+}
+
+// historyUIMakeList makes a button for the initial state and every action in
+// the current file's history. The last applied action is selected
+func historyUIMakeList() *Entity {
+	list := NewScrollableList(historyListBounds, []*Entity{}, FlowDirectionVertical|FlowDirectionNoWrap)
+	if CurrentFile == nil {
+		return list
+	}
+
+	position := CurrentFile.HistoryPosition()
+	makeButton := func(index int32, label string) *Entity {
+		return NewButtonText(
+			rl.NewRectangle(0, 0, historyListBounds.Width, UIButtonHeight),
+			label, TextAlignLeft, index == position, func(entity *Entity, button MouseButton) {
+				CurrentFile.JumpToHistory(index)
+			}, nil)
+	}
+
+	list.PushChild(makeButton(-1, "initial state"))
+	for i, action := range CurrentFile.History {
+		list.PushChild(makeButton(int32(i), action.String()))
+	}
+	list.FlowChildren()
+
+	return list
+}
+
+// HistoryUIRebuild rebuilds the list of actions
+func HistoryUIRebuild() {
+	if historyListContainer == nil {
+		return
+	}
+	if drawable, ok := historyButtons.GetDrawable(); !ok || drawable.Hidden {
+		return
+	}
+
+	historyListContainer.RemoveChild(historyList)
+	historyList.DestroyNested()
+	historyList.Destroy()
+
+	historyList = historyUIMakeList()
+	historyListContainer.PushChild(historyList)
+	historyListContainer.FlowChildren()
+}
+
+// NewHistoryUI returns the dialog which lists the undo history and jumps to
+// any point in it
+func NewHistoryUI() *Entity {
+	cx := rl.GetScreenWidth() / 2
+	cy := rl.GetScreenHeight() / 2
+
+	bounds := rl.NewRectangle(
+		float32(cx)-UIFontSize*10,
+		float32(cy)-UIFontSize*10,
+		float32(rl.GetScreenWidth()),
+		float32(rl.GetScreenHeight()),
+	)
+
+	closeHistoryButton := NewButtonText(
+		rl.NewRectangle(0, 0, UIButtonHeight, UIButtonHeight),
+		"X", TextAlignCenter, false, func(entity *Entity, button MouseButton) {
+			HistoryUIHideDialog()
+		}, nil)
+
+	historyListBounds = rl.NewRectangle(0, 0, UIFontSize*2*10, UIFontSize*2*10)
+	historyList = historyUIMakeList()
+	historyListContainer = NewBox(historyListBounds, []*Entity{historyList}, FlowDirectionVertical)
+
+	historyButtons = NewBox(
+		bounds,
+		[]*Entity{
+			closeHistoryButton,
+			historyListContainer,
+		},
+		FlowDirectionHorizontal,
+	)
+	historyButtons.FlowChildren()
+
+	HistoryUIHideDialog()
+
+	return historyButtons
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	rl "github.com/gen2brain/raylib-go/raylib"
+)
+
+var (
+	importButtons *Entity
+	importPath    *Entity
+	importMode    *Entity
+
+	importUISettings = NewImportSettings()
+	// importUIPending imports once a file has been chosen
+	importUIPending bool
+
+	importLabelWidth   = UIFontSize * 8
+	importControlWidth = UIFontSize * 20
+)
+
+// ImportUIShowDialog shows the dialog
+func ImportUIShowDialog() {
+	importUIPending = false
+	importButtons.Show()
+	importUIRefresh()
+}
+
+// ImportUIHideDialog hides the dialog
+func ImportUIHideDialog() {
+	importButtons.Hide()
+}
+
+// UIImportChooseFile asks which png to import
+func UIImportChooseFile() {
+	UIControlSystemCmds <- UIControlChanData{CommandType: CommandTypeImport}
+}
+
+// ImportUIFinishChooseFile is called by the control system when the import
+// file dialog closes. path is empty if it was cancelled
+func ImportUIFinishChooseFile(path string) {
+	if len(path) == 0 {
+		importUIPending = false
+		return
+	}
+	importUISettings.Path = path
+	importUIRefresh()
+
+	if importUIPending {
+		importUIPending = false
+		importUIImport()
+	}
+}
+
+// importUIImport imports the chosen png, asking for one first if it hasn't
+// been chosen
+func importUIImport() {
+	if len(importUISettings.Path) == 0 {
+		importUIPending = true
+		UIImportChooseFile()
+		return
+	}
+
+	file, err := Import(importUISettings)
+	if err != nil {
+		UIShowError(err)
+		return
+	}
+	if file != nil {
+		Files = append(Files, file)
+		EditorsUIRebuild()
+	}
+	ImportUIHideDialog()
+}
+
+// importUIRefresh shows importUISettings in the buttons
+func importUIRefresh() {
+	path := importUISettings.Path
+	if len(path) == 0 {
+		path = "choose..."
+	}
+	setTextLabel(importPath, path)
+	setTextLabel(importMode, importUISettings.Mode.String())
+}
+
+// NewImportUI returns the dialog with the options for importing pngs
+func NewImportUI() *Entity {
+	cx := rl.GetScreenWidth() / 2
+	cy := rl.GetScreenHeight() / 2
+
+	width := importLabelWidth + importControlWidth
+	bounds := rl.NewRectangle(
+		float32(cx)-width/2,
+		float32(cy)-UIButtonHeight*6,
+		width,
+		UIButtonHeight*12,
+	)
+
+	makeButton := func(onMouseUp func()) *Entity {
+		return NewButtonText(
+			rl.NewRectangle(0, 0, importControlWidth, UIButtonHeight),
+			"", TextAlignCenter, false, func(entity *Entity, button MouseButton) {
+				onMouseUp()
+				importUIRefresh()
+			}, nil)
+	}
+	makeRow := func(label string, control *Entity) *Entity {
+		return NewBox(rl.NewRectangle(0, 0, width, UIButtonHeight), []*Entity{
+			NewButtonText(rl.NewRectangle(0, 0, importLabelWidth, UIButtonHeight),
+				label, TextAlignLeft, false, nil, nil),
+			control,
+		}, FlowDirectionHorizontal)
+	}
+
+	importPath = makeButton(func() {
+		UIImportChooseFile()
+	})
+	importMode = makeButton(func() {
+		importUISettings.Mode = (importUISettings.Mode + 1) % (ImportModeLayer + 1)
+	})
+
+	// Made in reverse so that each can tab to the next
+	fpsInput := ResizeUIMakeInput(func() *int32 { return &importUISettings.FPS }, nil)
+	framesInput := ResizeUIMakeInput(func() *int32 { return &importUISettings.Frames }, fpsInput)
+	spacingYInput := ResizeUIMakeInput(func() *int32 { return &importUISettings.SpacingY }, framesInput)
+	spacingXInput := ResizeUIMakeInput(func() *int32 { return &importUISettings.SpacingX }, spacingYInput)
+	marginYInput := ResizeUIMakeInput(func() *int32 { return &importUISettings.MarginY }, spacingXInput)
+	marginXInput := ResizeUIMakeInput(func() *int32 { return &importUISettings.MarginX }, marginYInput)
+	tileHeightInput := ResizeUIMakeInput(func() *int32 { return &importUISettings.TileHeight }, marginXInput)
+	tileWidthInput := ResizeUIMakeInput(func() *int32 { return &importUISettings.TileWidth }, tileHeightInput)
+
+	closeImportButton := NewButtonText(
+		rl.NewRectangle(0, 0, UIButtonHeight, UIButtonHeight),
+		"X", TextAlignCenter, false, func(entity *Entity, button MouseButton) {
+			importUIPending = false
+			ImportUIHideDialog()
+		}, nil)
+
+	importButton := NewButtonText(
+		rl.NewRectangle(0, 0, width, UIButtonHeight),
+		"import", TextAlignCenter, false, func(entity *Entity, button MouseButton) {
+			importUIImport()
+		}, nil)
+
+	importButtons = NewBox(
+		bounds,
+		[]*Entity{
+			NewBox(rl.NewRectangle(0, 0, width, UIButtonHeight), []*Entity{
+				closeImportButton,
+				NewButtonText(rl.NewRectangle(0, 0, width-UIButtonHeight, UIButtonHeight),
+					"import png", TextAlignCenter, false, nil, nil),
+			}, FlowDirectionHorizontal),
+			makeRow("file", importPath),
+			makeRow("as", importMode),
+			makeRow("tile width", tileWidthInput),
+			makeRow("tile height", tileHeightInput),
+			makeRow("margin x", marginXInput),
+			makeRow("margin y", marginYInput),
+			makeRow("spacing x", spacingXInput),
+			makeRow("spacing y", spacingYInput),
+			makeRow("frames (0=all)", framesInput),
+			makeRow("fps", fpsInput),
+			importButton,
+		},
+		FlowDirectionVertical,
+	)
+	importButtons.FlowChildren()
+
+	ImportUIHideDialog()
+
+	return importButtons
+}
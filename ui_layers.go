@@ -12,6 +12,11 @@ var (
 
 	layerList          *Entity
 	layerListContainer *Entity
+
+	// The preview being dragged to reorder the layers
+	movingLayer *Moveable
+	// Lists the other open files to copy the current layer to
+	layersCopyMenu *Entity
 )
 
 // LayersUISetCurrentLayer can be used to activate a callback on a layer button
@@ -34,19 +39,37 @@ func LayersUISetCurrentLayer(index int32) {
 // LayersUIMakeList makes the list
 func LayersUIMakeList(bounds rl.Rectangle) {
 	layerList = NewScrollableList(rl.NewRectangle(0, UIButtonHeight, bounds.Width, bounds.Height-UIButtonHeight), []*Entity{}, FlowDirectionVerticalReversed|FlowDirectionNoWrap)
+	layerInteractables = make(map[int]*Entity)
+
 	// All of the layers
 	for i, layer := range CurrentFile.Layers {
 		if i == len(CurrentFile.Layers)-1 {
 			// ignore hidden layer
 			continue
 		}
+		if layersUIIsCollapsed(layer) {
+			continue
+		}
 		layerList.PushChild(LayersUIMakeLayerBox(int32(i), layer))
 	}
 	layerList.FlowChildren()
 }
 
+// layersUIIsCollapsed returns true if any of the layer's groups are collapsed
+func layersUIIsCollapsed(layer *Layer) bool {
+	for p := layer.Parent; p != nil; p = p.Parent {
+		if p.Collapsed {
+			return true
+		}
+	}
+	return false
+}
+
 // LayersUIRebuildList rebuilds the list
 func LayersUIRebuildList() {
+	if layerListContainer == nil {
+		return
+	}
 	layerListContainer.RemoveChild(layerList)
 	layerList.DestroyNested()
 	layerList.Destroy()
@@ -73,8 +96,7 @@ func LayersUIMakeLayerBox(y int32, layer *Layer) *Entity {
 			// button up
 			if res, err := scene.QueryID(entity.ID); err == nil {
 				drawable := res.Components[entity.Scene.ComponentsMap["drawable"]].(*Drawable)
-				CurrentFile.Layers[y].Hidden = !CurrentFile.Layers[y].Hidden
-				CurrentFile.RedrawRenderLayer()
+				CurrentFile.SetLayerHidden(y, !CurrentFile.Layers[y].Hidden)
 				drawableTexture, ok := drawable.DrawableType.(*DrawableTexture)
 				if ok {
 					if CurrentFile.Layers[y].Hidden {
@@ -89,9 +111,6 @@ func LayersUIMakeLayerBox(y int32, layer *Layer) *Entity {
 		func(entity *Entity, button MouseButton) {
 			// button up
 			if err := CurrentFile.MoveLayerUp(y, true); err == nil {
-				if CurrentFile.CurrentLayer == y {
-					CurrentFile.SetCurrentLayer(y + 1)
-				}
 				LayersUIRebuildList()
 				CurrentFile.RedrawRenderLayer()
 			}
@@ -100,9 +119,6 @@ func LayersUIMakeLayerBox(y int32, layer *Layer) *Entity {
 		func(entity *Entity, button MouseButton) {
 			// button up
 			if err := CurrentFile.MoveLayerDown(y, true); err == nil {
-				if CurrentFile.CurrentLayer == y {
-					CurrentFile.SetCurrentLayer(y - 1)
-				}
 				LayersUIRebuildList()
 				CurrentFile.RedrawRenderLayer()
 			}
@@ -176,7 +192,44 @@ func LayersUIMakeLayerBox(y int32, layer *Layer) *Entity {
 		},
 		FlowDirectionHorizontal)
 
-	preview := NewRenderTexture(rl.NewRectangle(0, 0, UIButtonHeight, UIButtonHeight), nil, nil)
+	// Dragging the preview onto another layer moves the layer there
+	preview := NewRenderTexture(rl.NewRectangle(0, 0, UIButtonHeight, UIButtonHeight),
+		func(entity *Entity, button MouseButton) {
+			// button up
+			if movingLayer == nil {
+				return
+			}
+			movingLayer = nil
+
+			cur := rl.GetMousePosition()
+			for index, label := range layerInteractables {
+				if moveable, ok := label.GetMoveable(); ok {
+					my := cur.Y - moveable.Offset.Y
+					if my >= moveable.Bounds.Y && my < moveable.Bounds.Y+moveable.Bounds.Height && int32(index) != y {
+						if err := CurrentFile.MoveLayer(y, int32(index)); err != nil {
+							log.Println(err)
+						}
+						break
+					}
+				}
+			}
+			LayersUIRebuildList()
+		},
+		func(entity *Entity, button MouseButton, isHeld bool) {
+			// button down
+			if isHeld && button == rl.MouseLeftButton {
+				if movingLayer == nil {
+					if moveable, ok := entity.GetMoveable(); ok {
+						movingLayer = moveable
+					}
+				}
+
+				movingLayer.Bounds.Y = rl.GetMousePosition().Y - movingLayer.Offset.Y - movingLayer.Bounds.Height/2
+			}
+		})
+	if moveable, ok := preview.GetMoveable(); ok {
+		moveable.Draggable = true
+	}
 	if res, err := scene.QueryID(preview.ID); err == nil {
 		drawable := res.Components[preview.Scene.ComponentsMap["drawable"]].(*Drawable)
 		renderTexture, ok := drawable.DrawableType.(*DrawableRenderTexture)
@@ -185,11 +238,61 @@ func LayersUIMakeLayerBox(y int32, layer *Layer) *Entity {
 		}
 	}
 
+	// Shows what kind of layer it is, indented by how deeply it's nested.
+	// Clicking a group's marker collapses it, clicking a layer's effects
+	// marker hides or shows its effects
+	markerWidth := UIFontSize * (1 + float32(layer.Depth())/2)
+	markerLabel := ""
+	hasEffects := len(layer.Effects) > 0 && !layer.IsGroup && !layer.IsMask
+	switch {
+	case hasEffects && layer.EffectsHidden:
+		markerWidth += UIFontSize * 1.5
+		markerLabel = "(fx)"
+	case hasEffects:
+		markerWidth += UIFontSize * 1.5
+		markerLabel = "fx"
+	case layer.IsGroup && layer.Collapsed:
+		markerLabel = "+"
+	case layer.IsGroup:
+		markerLabel = "-"
+	case layer.IsMask:
+		markerLabel = "m"
+	case layer.Clipping:
+		markerLabel = "c"
+	}
+	marker := NewButtonText(rl.NewRectangle(0, 0, markerWidth, UIButtonHeight), markerLabel, TextAlignRight, false,
+		func(entity *Entity, button MouseButton) {
+			// button up
+			switch {
+			case layer.IsGroup:
+				layer.Collapsed = !layer.Collapsed
+				LayersUIRebuildList()
+			case hasEffects:
+				CurrentFile.SetLayerEffectsHidden(y, !layer.EffectsHidden)
+				LayersUIRebuildList()
+			}
+		}, nil)
+
 	isCurrent := CurrentFile.CurrentLayer == y
-	label := NewInput(rl.NewRectangle(0, 0, bounds.Width-UIButtonHeight*2.5, UIButtonHeight), layer.Name, TextAlignCenter, isCurrent,
+	label := NewInput(rl.NewRectangle(0, 0, bounds.Width-UIButtonHeight*2.5-markerWidth, UIButtonHeight), layer.Name, TextAlignCenter, CurrentFile.IsLayerSelected(y),
 		func(entity *Entity, button MouseButton) {
 			// button up
 			if hoverable, ok := entity.GetHoverable(); ok {
+				// Shift selects more layers along with the current one
+				if rl.IsKeyDown(rl.KeyLeftShift) || rl.IsKeyDown(rl.KeyRightShift) {
+					if y != CurrentFile.CurrentLayer {
+						CurrentFile.ToggleLayerSelected(y)
+						hoverable.Selected = CurrentFile.IsLayerSelected(y)
+					}
+					return
+				}
+				if len(CurrentFile.SelectedLayers) > 0 {
+					CurrentFile.ClearSelectedLayers()
+					CurrentFile.SetCurrentLayer(y)
+					LayersUIRebuildList()
+					return
+				}
+
 				if currentLayerHoverable != nil {
 					currentLayerHoverable.Selected = false
 				}
@@ -218,7 +321,7 @@ func LayersUIMakeLayerBox(y int32, layer *Layer) *Entity {
 							RemoveCapturedInput()
 						}
 					}
-					CurrentFile.Layers[y].Name = drawableText.Label
+					CurrentFile.SetLayerName(y, drawableText.Label)
 				}
 			}
 
@@ -238,11 +341,58 @@ func LayersUIMakeLayerBox(y int32, layer *Layer) *Entity {
 	box := NewBox(rl.NewRectangle(0, 0, bounds.Width, UIButtonHeight), []*Entity{
 		buttonBox,
 		preview,
+		marker,
 		label,
 	}, FlowDirectionHorizontal)
 	return box
 }
 
+// LayersUIShowCopyMenu shows a list of the other open files at the cursor.
+// Clicking one copies the current layer to it
+func LayersUIShowCopyMenu() {
+	if layersCopyMenu != nil {
+		layersCopyMenu.DestroyNested()
+		layersCopyMenu = nil
+	}
+
+	measured := rl.MeasureTextEx(Font, "cancel ", UIFontSize, 1)
+	buttons := make([]*Entity, 0, len(Files))
+	for _, file := range Files {
+		if file == CurrentFile {
+			continue
+		}
+		target := file
+		if m := rl.MeasureTextEx(Font, target.Filename+" ", UIFontSize, 1); m.X > measured.X {
+			measured = m
+		}
+		buttons = append(buttons, NewButtonText(rl.NewRectangle(0, 0, 0, UIFontSize*2),
+			target.Filename, TextAlignLeft, false, func(entity *Entity, button MouseButton) {
+				CurrentFile.CopyLayerToFile(CurrentFile.CurrentLayer, target)
+				layersCopyMenu.Hide()
+			}, nil))
+	}
+	if len(buttons) == 0 {
+		log.Println("Couldn't copy layer: No other files are open")
+		return
+	}
+	buttons = append(buttons, NewButtonText(rl.NewRectangle(0, 0, 0, UIFontSize*2),
+		"cancel", TextAlignLeft, false, func(entity *Entity, button MouseButton) {
+			layersCopyMenu.Hide()
+		}, nil))
+
+	// Make every button as wide as the longest name
+	for _, button := range buttons {
+		if moveable, ok := button.GetMoveable(); ok {
+			moveable.Bounds.Width = measured.X + 10
+		}
+	}
+
+	cur := rl.GetMousePosition()
+	layersCopyMenu = NewBox(rl.NewRectangle(cur.X-measured.X-10, cur.Y, measured.X+10, UIFontSize*2*float32(len(buttons))),
+		buttons, FlowDirectionVertical)
+	layersCopyMenu.FlowChildren()
+}
+
 // NewLayersUI creates the UI representation of the CurrentFile's layers
 func NewLayersUI(bounds rl.Rectangle) *Entity {
 	// New layer button
@@ -261,8 +411,56 @@ func NewLayersUI(bounds rl.Rectangle) *Entity {
 			LayersUIRebuildList()
 		}, nil)
 
-	layerListContainer = NewBox(bounds, []*Entity{
+	makeButton := func(label string, onMouseUp func()) *Entity {
+		return NewButtonText(rl.NewRectangle(0, 0, (bounds.Width-UIButtonHeight)/4, UIButtonHeight/2), label, TextAlignCenter, false,
+			func(entity *Entity, button MouseButton) {
+				// button up
+				onMouseUp()
+				LayersUIRebuildList()
+			}, nil)
+	}
+
+	// Two rows of buttons for the current layer
+	layerButtons := NewBox(rl.NewRectangle(0, 0, bounds.Width-UIButtonHeight, UIButtonHeight), []*Entity{
+		makeButton("group", func() {
+			CurrentFile.AddNewGroup(CurrentFile.CurrentLayer)
+		}),
+		makeButton("ungroup", func() {
+			if err := CurrentFile.Ungroup(CurrentFile.CurrentLayer); err != nil {
+				log.Println(err)
+			}
+		}),
+		makeButton("mask", func() {
+			if err := CurrentFile.AddLayerMask(CurrentFile.CurrentLayer); err != nil {
+				log.Println(err)
+			}
+		}),
+		makeButton("clip", func() {
+			CurrentFile.SetLayerClipping(CurrentFile.CurrentLayer, !CurrentFile.GetCurrentLayer().Clipping)
+		}),
+		makeButton("duplicate", func() {
+			CurrentFile.DuplicateLayer(CurrentFile.CurrentLayer)
+		}),
+		makeButton("from sel", func() {
+			if err := CurrentFile.NewLayerFromSelection(); err != nil {
+				log.Println(err)
+			}
+		}),
+		makeButton("copy to", func() {
+			LayersUIShowCopyMenu()
+		}),
+		makeButton("effects", func() {
+			EffectsUIShowDialog()
+		}),
+	}, FlowDirectionHorizontal)
+
+	headerBox := NewBox(rl.NewRectangle(0, 0, bounds.Width, UIButtonHeight), []*Entity{
 		newLayerButton,
+		layerButtons,
+	}, FlowDirectionHorizontal)
+
+	layerListContainer = NewBox(bounds, []*Entity{
+		headerBox,
 	}, FlowDirectionVertical)
 
 	LayersUIMakeList(bounds)
@@ -16,9 +16,9 @@ var (
 // NewMenuUI returns a new entity
 func NewMenuUI(bounds rl.Rectangle) *Entity {
 	// Top level dropdown buttons
-	var fileButton, editButton, paletteButton *Entity
+	var fileButton, editButton, paletteButton, viewButton *Entity
 	// submenus
-	var fileSubMenu, editSubMenu, paletteSubMenu *Entity
+	var fileSubMenu, editSubMenu, paletteSubMenu, viewSubMenu *Entity
 
 	// button is top level menu button, dropdown is the child elements,
 	showDropdown := func(button *Entity, dropdown *Entity) {
@@ -89,16 +89,24 @@ func NewMenuUI(bounds rl.Rectangle) *Entity {
 			showDropdown(entity, paletteSubMenu)
 		}, nil)
 
+	measured = rl.MeasureTextEx(Font, " view ", UIFontSize, 1)
+	viewButton = NewButtonText(
+		rl.NewRectangle(100, 100, measured.X+10, UIFontSize*2),
+		" view ", TextAlignCenter, false, func(entity *Entity, button MouseButton) {
+			showDropdown(entity, viewSubMenu)
+		}, nil)
+
 	// Add to the bar
 	menuButtons = NewBox(bounds, []*Entity{
 		fileButton,
 		editButton,
 		paletteButton,
+		viewButton,
 	}, FlowDirectionHorizontal)
 	menuButtons.FlowChildren()
 
 	// File menu
-	measured = rl.MeasureTextEx(Font, "close file    ", UIFontSize, 1)
+	measured = rl.MeasureTextEx(Font, "crop to selection ", UIFontSize, 1)
 	bounds.Y += UIFontSize * 2
 	bounds.Height = float32(rl.GetScreenHeight())
 	bounds.Width = measured.X + 10
@@ -111,17 +119,33 @@ func NewMenuUI(bounds rl.Rectangle) *Entity {
 		NewButtonText( // Save
 			rl.NewRectangle(0, 0, measured.X+10, UIFontSize*2),
 			"save", TextAlignLeft, false, func(entity *Entity, button MouseButton) {
-				if len(CurrentFile.FileDir) > 0 {
-					CurrentFile.SaveAs(CurrentFile.FileDir)
-				} else {
-					UISaveAs()
-				}
+				UISave()
+			}, nil),
+		NewButtonText( // Save All
+			rl.NewRectangle(0, 0, measured.X+10, UIFontSize*2),
+			"save all", TextAlignLeft, false, func(entity *Entity, button MouseButton) {
+				UISaveAll()
 			}, nil),
 		NewButtonText( // Save As
 			rl.NewRectangle(0, 0, measured.X+10, UIFontSize*2),
 			"save as", TextAlignLeft, false, func(entity *Entity, button MouseButton) {
 				UISaveAs()
 			}, nil),
+		NewButtonText( // Export
+			rl.NewRectangle(0, 0, measured.X+10, UIFontSize*2),
+			"export", TextAlignLeft, false, func(entity *Entity, button MouseButton) {
+				UIExport()
+			}, nil),
+		NewButtonText( // Re-export
+			rl.NewRectangle(0, 0, measured.X+10, UIFontSize*2),
+			"re-export", TextAlignLeft, false, func(entity *Entity, button MouseButton) {
+				UIReExport()
+			}, nil),
+		NewButtonText( // Import
+			rl.NewRectangle(0, 0, measured.X+10, UIFontSize*2),
+			"import", TextAlignLeft, false, func(entity *Entity, button MouseButton) {
+				ImportUIShowDialog()
+			}, nil),
 		NewButtonText( // Open
 			rl.NewRectangle(0, 0, measured.X+10, UIFontSize*2),
 			"open", TextAlignLeft, false, func(entity *Entity, button MouseButton) {
@@ -137,6 +161,45 @@ func NewMenuUI(bounds rl.Rectangle) *Entity {
 			"resize", TextAlignLeft, false, func(entity *Entity, button MouseButton) {
 				ResizeUIShowDialog()
 			}, nil),
+		NewButtonText( // Re-grid
+			rl.NewRectangle(0, 0, measured.X+10, UIFontSize*2),
+			"re-grid", TextAlignLeft, false, func(entity *Entity, button MouseButton) {
+				RegridUIShowDialog()
+			}, nil),
+		NewButtonText( // Scale
+			rl.NewRectangle(0, 0, measured.X+10, UIFontSize*2),
+			"scale image", TextAlignLeft, false, func(entity *Entity, button MouseButton) {
+				ScaleUIShowDialog()
+			}, nil),
+		NewButtonText( // Crop
+			rl.NewRectangle(0, 0, measured.X+10, UIFontSize*2),
+			"crop to selection", TextAlignLeft, false, func(entity *Entity, button MouseButton) {
+				if err := CurrentFile.CropToSelection(); err != nil {
+					UIShowError(err)
+				}
+			}, nil),
+		NewButtonText( // Trim
+			rl.NewRectangle(0, 0, measured.X+10, UIFontSize*2),
+			"trim", TextAlignLeft, false, func(entity *Entity, button MouseButton) {
+				if err := CurrentFile.TrimImage(); err != nil {
+					UIShowError(err)
+				}
+			}, nil),
+		NewButtonText( // Rotate 90
+			rl.NewRectangle(0, 0, measured.X+10, UIFontSize*2),
+			"rotate 90", TextAlignLeft, false, func(entity *Entity, button MouseButton) {
+				CurrentFile.RotateImage(1)
+			}, nil),
+		NewButtonText( // Rotate 180
+			rl.NewRectangle(0, 0, measured.X+10, UIFontSize*2),
+			"rotate 180", TextAlignLeft, false, func(entity *Entity, button MouseButton) {
+				CurrentFile.RotateImage(2)
+			}, nil),
+		NewButtonText( // Rotate 270
+			rl.NewRectangle(0, 0, measured.X+10, UIFontSize*2),
+			"rotate 270", TextAlignLeft, false, func(entity *Entity, button MouseButton) {
+				CurrentFile.RotateImage(3)
+			}, nil),
 	}, FlowDirectionVertical)
 	fileSubMenu.FlowChildren()
 	fileSubMenu.Hide()
@@ -163,7 +226,32 @@ func NewMenuUI(bounds rl.Rectangle) *Entity {
 		NewButtonText( // Outline
 			rl.NewRectangle(0, 0, measured.X+10, UIFontSize*2),
 			"outline", TextAlignLeft, false, func(entity *Entity, button MouseButton) {
-				CurrentFile.Outline()
+				OutlineUIShowDialog()
+			}, nil),
+		NewButtonText( // Transform
+			rl.NewRectangle(0, 0, measured.X+10, UIFontSize*2),
+			"transform", TextAlignLeft, false, func(entity *Entity, button MouseButton) {
+				TransformUIShowDialog()
+			}, nil),
+		NewButtonText( // Select inverse
+			rl.NewRectangle(0, 0, measured.X+10, UIFontSize*2),
+			"select inverse", TextAlignLeft, false, func(entity *Entity, button MouseButton) {
+				CurrentFile.SelectInverse()
+			}, nil),
+		NewButtonText( // Selection masks
+			rl.NewRectangle(0, 0, measured.X+10, UIFontSize*2),
+			"selection masks", TextAlignLeft, false, func(entity *Entity, button MouseButton) {
+				SelectionMasksUIShowDialog()
+			}, nil),
+		NewButtonText( // Tiles
+			rl.NewRectangle(0, 0, measured.X+10, UIFontSize*2),
+			"tiles", TextAlignLeft, false, func(entity *Entity, button MouseButton) {
+				TilesUIShowDialog()
+			}, nil),
+		NewButtonText( // Filters
+			rl.NewRectangle(0, 0, measured.X+10, UIFontSize*2),
+			"filters", TextAlignLeft, false, func(entity *Entity, button MouseButton) {
+				FiltersUIShowDialog()
 			}, nil),
 	}, FlowDirectionVertical)
 	editSubMenu.FlowChildren()
@@ -242,6 +330,18 @@ func NewMenuUI(bounds rl.Rectangle) *Entity {
 				PaletteUIRebuildPalette()
 				paletteSubMenu.Hide()
 			}, nil),
+		NewButtonText( // Ramps and harmonies
+			rl.NewRectangle(0, 0, measured.X+10, UIFontSize*2),
+			"ramp/harmony", TextAlignLeft, false, func(entity *Entity, button MouseButton) {
+				RampUIShowDialog()
+				paletteSubMenu.Hide()
+			}, nil),
+		NewButtonText( // Color channels
+			rl.NewRectangle(0, 0, measured.X+10, UIFontSize*2),
+			"color channels", TextAlignLeft, false, func(entity *Entity, button MouseButton) {
+				ChannelsUIShowDialog()
+				paletteSubMenu.Hide()
+			}, nil),
 		NewButtonText( // Load Items Spacer
 			rl.NewRectangle(0, 0, measured.X+10, UIFontSize*2),
 			"---- Load ----", TextAlignCenter, false, func(entity *Entity, button MouseButton) {
@@ -291,5 +391,66 @@ func NewMenuUI(bounds rl.Rectangle) *Entity {
 		}
 	}
 
+	// View menu
+	measured = rl.MeasureTextEx(Font, "toggle pixel grid ", UIFontSize, 1)
+	paletteButtonMoveable, ok := paletteButton.GetMoveable()
+	if !ok {
+		log.Panic("paletteButton error")
+	}
+	bounds.X += paletteButtonMoveable.Bounds.Width
+	bounds.Width = measured.X + 10
+	viewSubMenu = NewBox(bounds, []*Entity{
+		NewButtonText( // Zoom in
+			rl.NewRectangle(0, 0, measured.X+10, UIFontSize*2),
+			"zoom in", TextAlignLeft, false, func(entity *Entity, button MouseButton) {
+				CurrentFile.ZoomStep(1, viewCenter())
+			}, nil),
+		NewButtonText( // Zoom out
+			rl.NewRectangle(0, 0, measured.X+10, UIFontSize*2),
+			"zoom out", TextAlignLeft, false, func(entity *Entity, button MouseButton) {
+				CurrentFile.ZoomStep(-1, viewCenter())
+			}, nil),
+		NewButtonText( // Fit to window
+			rl.NewRectangle(0, 0, measured.X+10, UIFontSize*2),
+			"fit to window", TextAlignLeft, false, func(entity *Entity, button MouseButton) {
+				CurrentFile.ZoomFit()
+			}, nil),
+		NewButtonText( // Actual size
+			rl.NewRectangle(0, 0, measured.X+10, UIFontSize*2),
+			"actual size", TextAlignLeft, false, func(entity *Entity, button MouseButton) {
+				CurrentFile.ZoomActual()
+			}, nil),
+		NewButtonText( // Pixel grid
+			rl.NewRectangle(0, 0, measured.X+10, UIFontSize*2),
+			"toggle pixel grid", TextAlignLeft, false, func(entity *Entity, button MouseButton) {
+				Settings.ViewData.PixelGrid = !Settings.ViewData.PixelGrid
+				SaveSettings()
+			}, nil),
+		NewButtonText( // Rulers
+			rl.NewRectangle(0, 0, measured.X+10, UIFontSize*2),
+			"toggle rulers", TextAlignLeft, false, func(entity *Entity, button MouseButton) {
+				Settings.ViewData.Rulers = !Settings.ViewData.Rulers
+				SaveSettings()
+			}, nil),
+		NewButtonText( // Snapping
+			rl.NewRectangle(0, 0, measured.X+10, UIFontSize*2),
+			"toggle snapping", TextAlignLeft, false, func(entity *Entity, button MouseButton) {
+				Settings.ViewData.Snap = !Settings.ViewData.Snap
+				SaveSettings()
+			}, nil),
+		NewButtonText( // Grid options
+			rl.NewRectangle(0, 0, measured.X+10, UIFontSize*2),
+			"grid options", TextAlignLeft, false, func(entity *Entity, button MouseButton) {
+				GridUIShowDialog()
+			}, nil),
+		NewButtonText( // Clear guides
+			rl.NewRectangle(0, 0, measured.X+10, UIFontSize*2),
+			"clear guides", TextAlignLeft, false, func(entity *Entity, button MouseButton) {
+				CurrentFile.ClearGuides()
+			}, nil),
+	}, FlowDirectionVertical)
+	viewSubMenu.FlowChildren()
+	viewSubMenu.Hide()
+
 	return menuButtons
 }
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	rl "github.com/gen2brain/raylib-go/raylib"
+)
+
+var (
+	outlineButtons  *Entity
+	outlineColor    *Entity
+	outlineInside   *Entity
+	outlineDiagonal *Entity
+	outlineMerged   *Entity
+	outlinePerTile  *Entity
+
+	// The options are kept between uses of the dialog, the color is shared by
+	// the outline and the shadow
+	outlineUIOptions       = OutlineOptions{Color: rl.Black}
+	outlineUIShadowX int32 = 1
+	outlineUIShadowY int32 = 1
+
+	outlineLabelWidth   = UIFontSize * 8
+	outlineControlWidth = UIFontSize * 20
+)
+
+// OutlineUIShowDialog shows the dialog
+func OutlineUIShowDialog() {
+	outlineButtons.Show()
+	outlineUIRefresh()
+}
+
+// OutlineUIHideDialog hides the dialog
+func OutlineUIHideDialog() {
+	outlineButtons.Hide()
+}
+
+// outlineUIRefresh shows the options in the controls
+func outlineUIRefresh() {
+	setTextLabel(outlineColor, ColorToHex(outlineUIOptions.Color))
+	setTextLabel(outlineInside, map[bool]string{false: "outside", true: "inside"}[outlineUIOptions.Inside])
+	setTextLabel(outlineDiagonal, map[bool]string{false: "4-way", true: "8-way"}[outlineUIOptions.Diagonal])
+	setTextLabel(outlineMerged, map[bool]string{false: "current layer", true: "merged to new layer"}[outlineUIOptions.Merged])
+	setTextLabel(outlinePerTile, fmt.Sprint(outlineUIOptions.PerTile))
+}
+
+// makeColorInput makes an input for a color as hex, onChange is called
+// whenever it's a whole color
+func makeColorInput(width float32, onChange func(color rl.Color)) *Entity {
+	return NewInput(rl.NewRectangle(0, 0, width, UIButtonHeight), "", TextAlignCenter, false,
+		func(entity *Entity, button MouseButton) {
+			// button up
+		}, nil,
+		func(entity *Entity, key Key) {
+			// key pressed
+			if drawable, ok := entity.GetDrawable(); ok {
+				if drawableText, ok := drawable.DrawableType.(*DrawableText); ok {
+					switch {
+					case key == rl.KeyBackspace && len(drawableText.Label) > 0:
+						drawableText.Label = drawableText.Label[:len(drawableText.Label)-1]
+					case key == rl.KeyEnter:
+						RemoveCapturedInput()
+					case len(drawableText.Label) >= 8:
+					case key >= 48 && key <= 57: // 0 to 9
+						fallthrough
+					case key >= 97 && key <= 102: // a to f
+						fallthrough
+					case key >= rl.KeyA && key <= rl.KeyF:
+						drawableText.Label += strings.ToLower(string(rune(key)))
+					}
+
+					if color, err := HexToColor(drawableText.Label); err == nil {
+						onChange(color)
+					}
+				}
+			}
+		})
+}
+
+// NewOutlineUI returns the dialog for drawing outlines and drop shadows
+func NewOutlineUI() *Entity {
+	cx := rl.GetScreenWidth() / 2
+	cy := rl.GetScreenHeight() / 2
+
+	width := outlineLabelWidth + outlineControlWidth
+	bounds := rl.NewRectangle(
+		float32(cx)-width/2,
+		float32(cy)-UIButtonHeight*5.5,
+		width,
+		UIButtonHeight*11,
+	)
+
+	makeButton := func(onMouseUp func()) *Entity {
+		return NewButtonText(
+			rl.NewRectangle(0, 0, outlineControlWidth, UIButtonHeight),
+			"", TextAlignCenter, false, func(entity *Entity, button MouseButton) {
+				onMouseUp()
+				outlineUIRefresh()
+			}, nil)
+	}
+	makeRow := func(label string, control *Entity) *Entity {
+		return NewBox(rl.NewRectangle(0, 0, width, UIButtonHeight), []*Entity{
+			NewButtonText(rl.NewRectangle(0, 0, outlineLabelWidth, UIButtonHeight),
+				label, TextAlignLeft, false, nil, nil),
+			control,
+		}, FlowDirectionHorizontal)
+	}
+
+	outlineColor = makeColorInput(outlineControlWidth, func(color rl.Color) {
+		outlineUIOptions.Color = color
+	})
+	useLeftColor := NewButtonText(
+		rl.NewRectangle(0, 0, width/2, UIButtonHeight),
+		"left color", TextAlignCenter, false, func(entity *Entity, button MouseButton) {
+			outlineUIOptions.Color = LeftColor
+			outlineUIRefresh()
+		}, nil)
+	useRightColor := NewButtonText(
+		rl.NewRectangle(0, 0, width/2, UIButtonHeight),
+		"right color", TextAlignCenter, false, func(entity *Entity, button MouseButton) {
+			outlineUIOptions.Color = RightColor
+			outlineUIRefresh()
+		}, nil)
+
+	outlineInside = makeButton(func() { outlineUIOptions.Inside = !outlineUIOptions.Inside })
+	outlineDiagonal = makeButton(func() { outlineUIOptions.Diagonal = !outlineUIOptions.Diagonal })
+	outlineMerged = makeButton(func() { outlineUIOptions.Merged = !outlineUIOptions.Merged })
+	outlinePerTile = makeButton(func() { outlineUIOptions.PerTile = !outlineUIOptions.PerTile })
+
+	// Made in reverse so that each can tab to the next
+	shadowYInput := ResizeUIMakeSignedInput(func() *int32 { return &outlineUIShadowY }, nil)
+	shadowXInput := ResizeUIMakeSignedInput(func() *int32 { return &outlineUIShadowX }, shadowYInput)
+
+	closeOutlineButton := NewButtonText(
+		rl.NewRectangle(0, 0, UIButtonHeight, UIButtonHeight),
+		"X", TextAlignCenter, false, func(entity *Entity, button MouseButton) {
+			OutlineUIHideDialog()
+		}, nil)
+	outlineButton := NewButtonText(
+		rl.NewRectangle(0, 0, width, UIButtonHeight),
+		"outline", TextAlignCenter, false, func(entity *Entity, button MouseButton) {
+			CurrentFile.Outline(outlineUIOptions)
+		}, nil)
+	shadowButton := NewButtonText(
+		rl.NewRectangle(0, 0, width, UIButtonHeight),
+		"drop shadow", TextAlignCenter, false, func(entity *Entity, button MouseButton) {
+			CurrentFile.DropShadow(ShadowOptions{
+				OffsetX: outlineUIShadowX,
+				OffsetY: outlineUIShadowY,
+				Color:   outlineUIOptions.Color,
+				Merged:  outlineUIOptions.Merged,
+				PerTile: outlineUIOptions.PerTile,
+			})
+		}, nil)
+
+	outlineButtons = NewBox(
+		bounds,
+		[]*Entity{
+			NewBox(rl.NewRectangle(0, 0, width, UIButtonHeight), []*Entity{
+				closeOutlineButton,
+				NewButtonText(rl.NewRectangle(0, 0, width-UIButtonHeight, UIButtonHeight),
+					"outline and shadow", TextAlignCenter, false, nil, nil),
+			}, FlowDirectionHorizontal),
+			makeRow("color", outlineColor),
+			NewBox(rl.NewRectangle(0, 0, width, UIButtonHeight), []*Entity{
+				useLeftColor,
+				useRightColor,
+			}, FlowDirectionHorizontal),
+			makeRow("draw", outlineInside),
+			makeRow("connect", outlineDiagonal),
+			makeRow("from", outlineMerged),
+			makeRow("per tile", outlinePerTile),
+			outlineButton,
+			makeRow("shadow x", shadowXInput),
+			makeRow("shadow y", shadowYInput),
+			shadowButton,
+		},
+		FlowDirectionVertical,
+	)
+	outlineButtons.FlowChildren()
+
+	outlineButtons.Hide()
+
+	return outlineButtons
+}
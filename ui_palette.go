@@ -291,6 +291,16 @@ func PaletteUIAddColor(color rl.Color, index int32) *Entity {
 	return e
 }
 
+// PaletteUIAppendColors adds the colors to the end of the current palette and
+// saves the settings
+func PaletteUIAppendColors(colors []rl.Color) {
+	for _, color := range colors {
+		PaletteUIAddColor(color, int32(len(Settings.PaletteData[CurrentFile.CurrentPalette].data)))
+		Settings.PaletteData[CurrentFile.CurrentPalette].data = append(Settings.PaletteData[CurrentFile.CurrentPalette].data, color)
+	}
+	SaveSettings()
+}
+
 // NewPaletteUI returns a new PaletteUI
 func NewPaletteUI(bounds rl.Rectangle) *Entity {
 	PaletteUIPaletteEntity = NewScrollableList(rl.NewRectangle(0, 0, bounds.Width, bounds.Height-UIButtonHeight/2), []*Entity{}, FlowDirectionHorizontal)
@@ -0,0 +1,153 @@
+package main
+
+import (
+	rl "github.com/gen2brain/raylib-go/raylib"
+)
+
+var (
+	rampButtons     *Entity
+	rampPreview     *Entity
+	rampModeButton  *Entity
+	rampStepsInput  *Entity
+	rampShiftInput  *Entity
+	rampColors      []rl.Color
+	rampMode              = RampInterpolationOKLab
+	rampSteps       int32 = 5
+	rampHueShift    int32 = 20
+	rampPreviewSize       = rl.NewRectangle(0, 0, UIFontSize*2*10, UIButtonHeight)
+)
+
+// RampUIShowDialog shows the dialog
+func RampUIShowDialog() {
+	rampButtons.Show()
+}
+
+// RampUIHideDialog hides the dialog
+func RampUIHideDialog() {
+	rampButtons.Hide()
+}
+
+// RampUISetColors sets the generated colors and redraws the preview
+func RampUISetColors(colors []rl.Color) {
+	rampColors = colors
+
+	if drawable, ok := rampPreview.GetDrawable(); ok {
+		if renderTexture, ok := drawable.DrawableType.(*DrawableRenderTexture); ok {
+			texture := renderTexture.Texture
+			w := texture.Texture.Width
+			h := texture.Texture.Height
+
+			rl.BeginTextureMode(texture)
+			rl.ClearBackground(rl.Blank)
+			rl.DrawRectangle(0, 0, w, h/2, rl.Gray)
+			rl.DrawRectangle(0, h/2, w, h/2, rl.Black)
+			if len(colors) > 0 {
+				cw := w / int32(len(colors))
+				for i, color := range colors {
+					rl.DrawRectangle(int32(i)*cw, 0, cw, h, color)
+				}
+			}
+			rl.EndTextureMode()
+		}
+	}
+}
+
+// NewRampUI returns the dialog for generating color ramps and harmonies
+func NewRampUI() *Entity {
+	cx := rl.GetScreenWidth() / 2
+	cy := rl.GetScreenHeight() / 2
+
+	bounds := rl.NewRectangle(
+		float32(cx)-UIFontSize*25,
+		float32(cy)-UIFontSize*5,
+		float32(rl.GetScreenWidth()),
+		float32(rl.GetScreenHeight()),
+	)
+
+	closeRampButton := NewButtonText(
+		rl.NewRectangle(0, 0, UIButtonHeight, UIButtonHeight),
+		"X", TextAlignCenter, false, func(entity *Entity, button MouseButton) {
+			RampUIHideDialog()
+		}, nil)
+
+	rampModeButton = NewButtonText(
+		rl.NewRectangle(0, 0, UIFontSize*2*10, UIButtonHeight),
+		"mode: "+rampMode.String(), TextAlignCenter, false, func(entity *Entity, button MouseButton) {
+			rampMode = (rampMode + 1) % (RampInterpolationOKLab + 1)
+			if drawable, ok := entity.GetDrawable(); ok {
+				if drawableText, ok := drawable.DrawableType.(*DrawableText); ok {
+					drawableText.Label = "mode: " + rampMode.String()
+				}
+			}
+		}, nil)
+
+	rampShiftInput = ResizeUIMakeInput(func() *int32 { return &rampHueShift }, nil)
+	rampStepsInput = ResizeUIMakeInput(func() *int32 { return &rampSteps }, rampShiftInput)
+
+	rampPreview = NewRenderTexture(rampPreviewSize, nil, nil)
+
+	makeButton := func(label string, onMouseUp func()) *Entity {
+		return NewButtonText(
+			rl.NewRectangle(0, 0, UIFontSize*2*10, UIButtonHeight),
+			label, TextAlignCenter, false, func(entity *Entity, button MouseButton) {
+				onMouseUp()
+			}, nil)
+	}
+
+	settingsBox := NewBox(rl.NewRectangle(
+		float32(cx),
+		float32(cy),
+		float32(UIFontSize*2*10),
+		float32(UIFontSize*2*10),
+	), []*Entity{
+		rampModeButton,
+		rampStepsInput,
+		rampShiftInput,
+		rampPreview,
+		makeButton("add to palette", func() {
+			PaletteUIAppendColors(rampColors)
+		}),
+	}, FlowDirectionVertical)
+
+	generateBox := NewBox(rl.NewRectangle(
+		float32(cx),
+		float32(cy),
+		float32(UIFontSize*2*10),
+		float32(UIFontSize*2*10),
+	), []*Entity{
+		makeButton("ramp left to right", func() {
+			RampUISetColors(GenerateRamp(LeftColor, RightColor, rampSteps, rampMode))
+		}),
+		makeButton("hue shift ramp (left)", func() {
+			RampUISetColors(GenerateHueShiftRamp(LeftColor, rampSteps, float64(rampHueShift), rampMode))
+		}),
+		makeButton("complementary", func() {
+			RampUISetColors(GenerateHarmony(LeftColor, ColorHarmonyComplementary))
+		}),
+		makeButton("triadic", func() {
+			RampUISetColors(GenerateHarmony(LeftColor, ColorHarmonyTriadic))
+		}),
+		makeButton("split complementary", func() {
+			RampUISetColors(GenerateHarmony(LeftColor, ColorHarmonySplitComplementary))
+		}),
+		makeButton("analogous", func() {
+			RampUISetColors(GenerateHarmony(LeftColor, ColorHarmonyAnalogous))
+		}),
+	}, FlowDirectionVertical)
+
+	rampButtons = NewBox(
+		bounds,
+		[]*Entity{
+			closeRampButton,
+			settingsBox,
+			generateBox,
+		},
+		FlowDirectionHorizontal,
+	)
+	rampButtons.FlowChildren()
+
+	RampUISetColors(nil)
+	RampUIHideDialog()
+
+	return rampButtons
+}
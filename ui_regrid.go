@@ -0,0 +1,114 @@
+package main
+
+import (
+	rl "github.com/gen2brain/raylib-go/raylib"
+)
+
+var (
+	regridButtons *Entity
+	regridAnchor  *Entity
+
+	// Filled in from the current file when the dialog is shown
+	regridUITileWidth  int32
+	regridUITileHeight int32
+	regridUIColumns    int32
+	regridUIAnchor     = ResizeTL
+
+	// regridUIAnchors are the anchors which can be picked, with their names
+	regridUIAnchors      = []ResizeDirection{ResizeTL, ResizeCC, ResizeBC}
+	regridUIAnchorLabels = map[ResizeDirection]string{ResizeTL: "top left", ResizeCC: "center", ResizeBC: "bottom"}
+
+	regridLabelWidth   = UIFontSize * 8
+	regridControlWidth = UIFontSize * 20
+)
+
+// RegridUIShowDialog shows the dialog, starting with the current layout
+func RegridUIShowDialog() {
+	regridUITileWidth = CurrentFile.TileWidth
+	regridUITileHeight = CurrentFile.TileHeight
+	regridUIColumns = MaxInt32(CurrentFile.CanvasWidth/CurrentFile.TileWidth, 1)
+	regridButtons.Show()
+	setTextLabel(regridAnchor, regridUIAnchorLabels[regridUIAnchor])
+}
+
+// RegridUIHideDialog hides the dialog
+func RegridUIHideDialog() {
+	regridButtons.Hide()
+}
+
+// NewRegridUI returns the dialog for moving the tiles into a new tile size
+// and number of columns
+func NewRegridUI() *Entity {
+	cx := rl.GetScreenWidth() / 2
+	cy := rl.GetScreenHeight() / 2
+
+	width := regridLabelWidth + regridControlWidth
+	bounds := rl.NewRectangle(
+		float32(cx)-width/2,
+		float32(cy)-UIButtonHeight*3,
+		width,
+		UIButtonHeight*6,
+	)
+
+	makeRow := func(label string, control *Entity) *Entity {
+		return NewBox(rl.NewRectangle(0, 0, width, UIButtonHeight), []*Entity{
+			NewButtonText(rl.NewRectangle(0, 0, regridLabelWidth, UIButtonHeight),
+				label, TextAlignLeft, false, nil, nil),
+			control,
+		}, FlowDirectionHorizontal)
+	}
+
+	// Made in reverse so that each can tab to the next
+	columnsInput := ResizeUIMakeInput(func() *int32 { return &regridUIColumns }, nil)
+	tileHeightInput := ResizeUIMakeInput(func() *int32 { return &regridUITileHeight }, columnsInput)
+	tileWidthInput := ResizeUIMakeInput(func() *int32 { return &regridUITileWidth }, tileHeightInput)
+
+	regridAnchor = NewButtonText(
+		rl.NewRectangle(0, 0, regridControlWidth, UIButtonHeight),
+		"", TextAlignCenter, false, func(entity *Entity, button MouseButton) {
+			for i, anchor := range regridUIAnchors {
+				if anchor == regridUIAnchor {
+					regridUIAnchor = regridUIAnchors[(i+1)%len(regridUIAnchors)]
+					break
+				}
+			}
+			setTextLabel(regridAnchor, regridUIAnchorLabels[regridUIAnchor])
+		}, nil)
+
+	closeRegridButton := NewButtonText(
+		rl.NewRectangle(0, 0, UIButtonHeight, UIButtonHeight),
+		"X", TextAlignCenter, false, func(entity *Entity, button MouseButton) {
+			RegridUIHideDialog()
+		}, nil)
+	applyButton := NewButtonText(
+		rl.NewRectangle(0, 0, width, UIButtonHeight),
+		"re-grid", TextAlignCenter, false, func(entity *Entity, button MouseButton) {
+			if err := CurrentFile.RegridImage(regridUITileWidth, regridUITileHeight, regridUIColumns, regridUIAnchor); err != nil {
+				UIShowError(err)
+				return
+			}
+			RegridUIHideDialog()
+		}, nil)
+
+	regridButtons = NewBox(
+		bounds,
+		[]*Entity{
+			NewBox(rl.NewRectangle(0, 0, width, UIButtonHeight), []*Entity{
+				closeRegridButton,
+				NewButtonText(rl.NewRectangle(0, 0, width-UIButtonHeight, UIButtonHeight),
+					"re-grid tiles", TextAlignCenter, false, nil, nil),
+			}, FlowDirectionHorizontal),
+			makeRow("tile width", tileWidthInput),
+			makeRow("tile height", tileHeightInput),
+			makeRow("columns", columnsInput),
+			makeRow("anchor", regridAnchor),
+			applyButton,
+		},
+		FlowDirectionVertical,
+	)
+	regridButtons.FlowChildren()
+
+	regridButtons.Hide()
+
+	return regridButtons
+}
@@ -35,6 +35,18 @@ func ResizeUIHideDialog() {
 // ResizeUIMakeInput is a helper function which binds to a value. Optionally,
 //an *Entity can be provided to switch focus to when tab is pressed.
 func ResizeUIMakeInput(linkedValueCallback func() *int32, tabNext *Entity) *Entity {
+	return makeNumberInput(linkedValueCallback, tabNext, false)
+}
+
+// ResizeUIMakeSignedInput is ResizeUIMakeInput which also takes negative
+// numbers
+func ResizeUIMakeSignedInput(linkedValueCallback func() *int32, tabNext *Entity) *Entity {
+	return makeNumberInput(linkedValueCallback, tabNext, true)
+}
+
+// makeNumberInput makes an input which binds to a value, a minus sign can
+// start it if signed is set
+func makeNumberInput(linkedValueCallback func() *int32, tabNext *Entity, signed bool) *Entity {
 	i := NewInput(rl.NewRectangle(0, 0, UIFontSize*2*10, UIButtonHeight), fmt.Sprint(*linkedValueCallback()), TextAlignCenter, false,
 		func(entity *Entity, button MouseButton) {
 			// button up
@@ -56,6 +68,8 @@ func ResizeUIMakeInput(linkedValueCallback func() *int32, tabNext *Entity) *Enti
 					case key >= 48 && key <= 57: // a to z
 						drawableParent.Label += string(rune(key))
 						alterValue()
+					case key == rl.KeyMinus && signed && len(drawableParent.Label) == 0:
+						drawableParent.Label = "-"
 					case key == rl.KeyBackspace && len(drawableParent.Label) > 0:
 						drawableParent.Label = drawableParent.Label[:len(drawableParent.Label)-1]
 						alterValue()
@@ -0,0 +1,98 @@
+package main
+
+import (
+	rl "github.com/gen2brain/raylib-go/raylib"
+)
+
+var (
+	scaleButtons *Entity
+	scaleMode    *Entity
+
+	// scaleUIFactor and scaleUIMode are kept between uses of the dialog
+	scaleUIFactor int32 = 2
+	scaleUIMode         = ScaleModeNearest
+
+	scaleLabelWidth   = UIFontSize * 8
+	scaleControlWidth = UIFontSize * 20
+)
+
+// ScaleUIShowDialog shows the dialog
+func ScaleUIShowDialog() {
+	scaleButtons.Show()
+	setTextLabel(scaleMode, scaleUIMode.String())
+}
+
+// ScaleUIHideDialog hides the dialog
+func ScaleUIHideDialog() {
+	scaleButtons.Hide()
+}
+
+// NewScaleUI returns the dialog for enlarging the whole image
+func NewScaleUI() *Entity {
+	cx := rl.GetScreenWidth() / 2
+	cy := rl.GetScreenHeight() / 2
+
+	width := scaleLabelWidth + scaleControlWidth
+	bounds := rl.NewRectangle(
+		float32(cx)-width/2,
+		float32(cy)-UIButtonHeight*2,
+		width,
+		UIButtonHeight*4,
+	)
+
+	makeRow := func(label string, control *Entity) *Entity {
+		return NewBox(rl.NewRectangle(0, 0, width, UIButtonHeight), []*Entity{
+			NewButtonText(rl.NewRectangle(0, 0, scaleLabelWidth, UIButtonHeight),
+				label, TextAlignLeft, false, nil, nil),
+			control,
+		}, FlowDirectionHorizontal)
+	}
+
+	factorInput := ResizeUIMakeInput(func() *int32 { return &scaleUIFactor }, nil)
+	// Rotsprite only differs from nearest neighbour when rotating, so it's
+	// skipped
+	scaleMode = NewButtonText(
+		rl.NewRectangle(0, 0, scaleControlWidth, UIButtonHeight),
+		scaleUIMode.String(), TextAlignCenter, false, func(entity *Entity, button MouseButton) {
+			scaleUIMode = (scaleUIMode + 1) % (ScaleModeXBR + 1)
+			if scaleUIMode == ScaleModeRotSprite {
+				scaleUIMode++
+			}
+			setTextLabel(scaleMode, scaleUIMode.String())
+		}, nil)
+
+	closeScaleButton := NewButtonText(
+		rl.NewRectangle(0, 0, UIButtonHeight, UIButtonHeight),
+		"X", TextAlignCenter, false, func(entity *Entity, button MouseButton) {
+			ScaleUIHideDialog()
+		}, nil)
+	applyButton := NewButtonText(
+		rl.NewRectangle(0, 0, width, UIButtonHeight),
+		"scale", TextAlignCenter, false, func(entity *Entity, button MouseButton) {
+			if err := CurrentFile.ScaleImage(scaleUIFactor, scaleUIMode); err != nil {
+				UIShowError(err)
+				return
+			}
+			ScaleUIHideDialog()
+		}, nil)
+
+	scaleButtons = NewBox(
+		bounds,
+		[]*Entity{
+			NewBox(rl.NewRectangle(0, 0, width, UIButtonHeight), []*Entity{
+				closeScaleButton,
+				NewButtonText(rl.NewRectangle(0, 0, width-UIButtonHeight, UIButtonHeight),
+					"scale image", TextAlignCenter, false, nil, nil),
+			}, FlowDirectionHorizontal),
+			makeRow("times", factorInput),
+			makeRow("mode", scaleMode),
+			applyButton,
+		},
+		FlowDirectionVertical,
+	)
+	scaleButtons.FlowChildren()
+
+	scaleButtons.Hide()
+
+	return scaleButtons
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	rl "github.com/gen2brain/raylib-go/raylib"
+)
+
+var (
+	selectionMasksButtons       *Entity
+	selectionMasksName          *Entity
+	selectionMasksListContainer *Entity
+	selectionMasksList          *Entity
+	selectionMasksListBounds    rl.Rectangle
+)
+
+// SelectionMasksUIShowDialog shows the dialog
+func SelectionMasksUIShowDialog() {
+	selectionMasksButtons.Show()
+	SelectionMasksUIRebuild()
+}
+
+// SelectionMasksUIHideDialog hides the dialog
+func SelectionMasksUIHideDialog() {
+	selectionMasksButtons.Hide()
+}
+
+// selectionMasksUIMakeList makes a row for each of the current file's masks.
+// Clicking the name selects the mask, using the held modifier keys to add,
+// subtract or intersect
+func selectionMasksUIMakeList() *Entity {
+	list := NewScrollableList(selectionMasksListBounds, []*Entity{}, FlowDirectionVertical|FlowDirectionNoWrap)
+	if CurrentFile == nil {
+		return list
+	}
+
+	width := selectionMasksListBounds.Width
+	for i, mask := range CurrentFile.SelectionMasks {
+		index := i
+		list.PushChild(NewBox(rl.NewRectangle(0, 0, width, UIButtonHeight), []*Entity{
+			NewButtonText(rl.NewRectangle(0, 0, width-UIButtonHeight, UIButtonHeight),
+				mask.Name, TextAlignLeft, false, func(entity *Entity, button MouseButton) {
+					CurrentFile.LoadSelectionMask(index, CurrentSelectOp())
+				}, nil),
+			NewButtonText(rl.NewRectangle(0, 0, UIButtonHeight, UIButtonHeight),
+				"X", TextAlignCenter, false, func(entity *Entity, button MouseButton) {
+					CurrentFile.DeleteSelectionMask(index)
+					SelectionMasksUIRebuild()
+				}, nil),
+		}, FlowDirectionHorizontal))
+	}
+	list.FlowChildren()
+
+	return list
+}
+
+// SelectionMasksUIRebuild rebuilds the list of masks
+func SelectionMasksUIRebuild() {
+	if selectionMasksListContainer == nil {
+		return
+	}
+	if drawable, ok := selectionMasksButtons.GetDrawable(); !ok || drawable.Hidden {
+		return
+	}
+
+	selectionMasksListContainer.RemoveChild(selectionMasksList)
+	selectionMasksList.DestroyNested()
+	selectionMasksList.Destroy()
+
+	selectionMasksList = selectionMasksUIMakeList()
+	selectionMasksListContainer.PushChild(selectionMasksList)
+	selectionMasksListContainer.FlowChildren()
+}
+
+// selectionMasksUIMakeNameInput makes the input for the name the selection is
+// saved with
+func selectionMasksUIMakeNameInput(width float32) *Entity {
+	return NewInput(rl.NewRectangle(0, 0, width, UIButtonHeight), "mask", TextAlignCenter, false,
+		func(entity *Entity, button MouseButton) {
+			// button up
+		}, nil,
+		func(entity *Entity, key Key) {
+			// key pressed
+			if drawable, ok := entity.GetDrawable(); ok {
+				if drawableText, ok := drawable.DrawableType.(*DrawableText); ok {
+					shift := rl.IsKeyDown(rl.KeyLeftShift) || rl.IsKeyDown(rl.KeyRightShift)
+
+					switch {
+					case key == rl.KeyBackspace && len(drawableText.Label) > 0:
+						drawableText.Label = drawableText.Label[:len(drawableText.Label)-1]
+					case key == rl.KeyEnter:
+						RemoveCapturedInput()
+					case len(drawableText.Label) >= 24:
+					case key >= rl.KeyA && key <= rl.KeyZ && !shift:
+						drawableText.Label += string(rune(key - rl.KeyA + 'a'))
+					case key >= 32 && key <= 126: // printable, including pasted text
+						drawableText.Label += string(rune(key))
+					}
+				}
+			}
+		})
+}
+
+// NewSelectionMasksUI returns the dialog which saves the selection as a named
+// mask and selects saved masks again
+func NewSelectionMasksUI() *Entity {
+	cx := rl.GetScreenWidth() / 2
+	cy := rl.GetScreenHeight() / 2
+
+	width := UIFontSize * 2 * 10
+	bounds := rl.NewRectangle(
+		float32(cx)-width/2,
+		float32(cy)-UIFontSize*10,
+		width+UIButtonHeight,
+		UIFontSize*2*10+UIButtonHeight*2,
+	)
+
+	closeSelectionMasksButton := NewButtonText(
+		rl.NewRectangle(0, 0, UIButtonHeight, UIButtonHeight),
+		"X", TextAlignCenter, false, func(entity *Entity, button MouseButton) {
+			SelectionMasksUIHideDialog()
+		}, nil)
+
+	selectionMasksName = selectionMasksUIMakeNameInput(width - UIButtonHeight*3)
+	saveButton := NewButtonText(
+		rl.NewRectangle(0, 0, UIButtonHeight*3, UIButtonHeight),
+		"save", TextAlignCenter, false, func(entity *Entity, button MouseButton) {
+			if drawable, ok := selectionMasksName.GetDrawable(); ok {
+				if drawableText, ok := drawable.DrawableType.(*DrawableText); ok {
+					if err := CurrentFile.SaveSelectionMask(drawableText.Label); err != nil {
+						UIShowError(err)
+						return
+					}
+					SelectionMasksUIRebuild()
+				}
+			}
+		}, nil)
+
+	selectionMasksListBounds = rl.NewRectangle(0, 0, width, UIFontSize*2*10)
+	selectionMasksList = selectionMasksUIMakeList()
+	selectionMasksListContainer = NewBox(selectionMasksListBounds, []*Entity{selectionMasksList}, FlowDirectionVertical)
+
+	selectionMasksButtons = NewBox(
+		bounds,
+		[]*Entity{
+			closeSelectionMasksButton,
+			NewBox(rl.NewRectangle(0, 0, width, UIButtonHeight*2+selectionMasksListBounds.Height), []*Entity{
+				NewButtonText(rl.NewRectangle(0, 0, width, UIButtonHeight),
+					"selection masks", TextAlignCenter, false, nil, nil),
+				NewBox(rl.NewRectangle(0, 0, width, UIButtonHeight), []*Entity{
+					selectionMasksName,
+					saveButton,
+				}, FlowDirectionHorizontal),
+				selectionMasksListContainer,
+			}, FlowDirectionVertical),
+		},
+		FlowDirectionHorizontal,
+	)
+	selectionMasksButtons.FlowChildren()
+
+	SelectionMasksUIHideDialog()
+
+	return selectionMasksButtons
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"fmt"
+
+	rl "github.com/gen2brain/raylib-go/raylib"
+)
+
+var (
+	tilesButtons  *Entity
+	tilesSelected *Entity
+
+	// tilesUITool selects and drags tiles while the dialog is open, in place
+	// of tilesUILastTool
+	tilesUITool     *TilesTool
+	tilesUILastTool Tool
+
+	tilesLabelWidth   = UIFontSize * 8
+	tilesControlWidth = UIFontSize * 20
+)
+
+// TilesUIShowDialog shows the dialog and switches the left tool to selecting
+// tiles
+func TilesUIShowDialog() {
+	if LeftTool != tilesUITool {
+		tilesUILastTool = LeftTool
+		LeftTool = tilesUITool
+	}
+	tilesUITool.Select(-1, -1)
+	tilesButtons.Show()
+}
+
+// TilesUIHideDialog hides the dialog and gives back the left tool
+func TilesUIHideDialog() {
+	tilesButtons.Hide()
+	if LeftTool == tilesUITool && tilesUILastTool != nil {
+		LeftTool = tilesUILastTool
+	}
+	tilesUILastTool = nil
+}
+
+// tilesUIRefresh shows the selected tiles
+func tilesUIRefresh() {
+	switch {
+	case tilesUITool.First < 0:
+		setTextLabel(tilesSelected, "none")
+	case tilesUITool.First == tilesUITool.Last:
+		setTextLabel(tilesSelected, fmt.Sprint(tilesUITool.First))
+	default:
+		setTextLabel(tilesSelected, fmt.Sprintf("%d to %d", tilesUITool.First, tilesUITool.Last))
+	}
+}
+
+// NewTilesUI returns the dialog for inserting, deleting, duplicating and
+// swapping tiles. Tiles are selected and dragged on the canvas
+func NewTilesUI() *Entity {
+	cx := rl.GetScreenWidth() / 2
+	cy := rl.GetScreenHeight() / 2
+
+	width := tilesLabelWidth + tilesControlWidth
+	bounds := rl.NewRectangle(
+		float32(cx)-width/2,
+		float32(cy)-UIButtonHeight*3,
+		width,
+		UIButtonHeight*6,
+	)
+
+	tilesUITool = NewTilesTool("Tiles", tilesUIRefresh)
+
+	// makeButton makes a button which runs a command on the selected tiles,
+	// then selects the tiles returned
+	makeButton := func(label string, command func(first, last int32) (int32, int32, error)) *Entity {
+		return NewButtonText(
+			rl.NewRectangle(0, 0, width, UIButtonHeight),
+			label, TextAlignCenter, false, func(entity *Entity, button MouseButton) {
+				if tilesUITool.First < 0 {
+					UIShowError(fmt.Errorf("No tiles are selected"))
+					return
+				}
+				first, last, err := command(tilesUITool.First, tilesUITool.Last)
+				if err != nil {
+					UIShowError(err)
+					return
+				}
+				tilesUITool.Select(first, last)
+			}, nil)
+	}
+
+	tilesSelected = NewButtonText(
+		rl.NewRectangle(0, 0, tilesControlWidth, UIButtonHeight),
+		"none", TextAlignCenter, false, nil, nil)
+
+	closeTilesButton := NewButtonText(
+		rl.NewRectangle(0, 0, UIButtonHeight, UIButtonHeight),
+		"X", TextAlignCenter, false, func(entity *Entity, button MouseButton) {
+			TilesUIHideDialog()
+		}, nil)
+
+	tilesButtons = NewBox(
+		bounds,
+		[]*Entity{
+			NewBox(rl.NewRectangle(0, 0, width, UIButtonHeight), []*Entity{
+				closeTilesButton,
+				NewButtonText(rl.NewRectangle(0, 0, width-UIButtonHeight, UIButtonHeight),
+					"tiles", TextAlignCenter, false, nil, nil),
+			}, FlowDirectionHorizontal),
+			NewBox(rl.NewRectangle(0, 0, width, UIButtonHeight), []*Entity{
+				NewButtonText(rl.NewRectangle(0, 0, tilesLabelWidth, UIButtonHeight),
+					"selected", TextAlignLeft, false, nil, nil),
+				tilesSelected,
+			}, FlowDirectionHorizontal),
+			makeButton("insert blank tile", func(first, last int32) (int32, int32, error) {
+				return first, first, CurrentFile.InsertTile(first)
+			}),
+			makeButton("delete", func(first, last int32) (int32, int32, error) {
+				return -1, -1, CurrentFile.DeleteTiles(first, last)
+			}),
+			makeButton("duplicate", func(first, last int32) (int32, int32, error) {
+				count := last - first + 1
+				return first + count, last + count, CurrentFile.DuplicateTiles(first, last)
+			}),
+			makeButton("swap first and last", func(first, last int32) (int32, int32, error) {
+				return first, last, CurrentFile.SwapTiles(first, last)
+			}),
+		},
+		FlowDirectionVertical,
+	)
+	tilesButtons.FlowChildren()
+
+	tilesButtons.Hide()
+
+	return tilesButtons
+}
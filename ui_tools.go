@@ -16,6 +16,9 @@ var (
 	toolPicker           *Entity
 	toolSelector         *Entity
 	toolSettings         *Entity // extra space which can be used by other ui
+
+	// toolSelectorMode is the kind of selection the selector button makes
+	toolSelectorMode SelectorMode
 )
 
 // ToolsUISetCurrentToolSelected makes the tool have the selected appearance
@@ -121,6 +124,106 @@ func ToolsUISetCurrentToolSelected(entity *Entity) {
 		}
 		toolSettings.PushChild(brushShapeBox)
 		toolSettings.PushChild(brushWidthInput)
+	case toolPicker:
+		var mode PickerMode
+		if lt, ok := LeftTool.(*PickerTool); ok {
+			mode = lt.GetMode()
+		}
+		pickerModeButton := NewButtonText(rl.NewRectangle(0, 0, UIButtonHeight*3.5, UIButtonHeight), "pick: "+mode.String(), TextAlignCenter, false,
+			func(e *Entity, button MouseButton) {
+				// button up
+				mode = (mode + 1) % (PickerModeSwitchLayer + 1)
+				if lt, ok := LeftTool.(*PickerTool); ok {
+					lt.SetMode(mode)
+				}
+				if rt, ok := RightTool.(*PickerTool); ok {
+					rt.SetMode(mode)
+				}
+				if drawable, ok := e.GetDrawable(); ok {
+					if drawableText, ok := drawable.DrawableType.(*DrawableText); ok {
+						drawableText.Label = "pick: " + mode.String()
+					}
+				}
+			}, nil)
+		toolSettings.PushChild(pickerModeButton)
+	case toolSelector:
+		selectorModeButton := NewButtonText(rl.NewRectangle(0, 0, UIButtonHeight*1.5, UIButtonHeight/2), toolSelectorMode.String(), TextAlignCenter, false,
+			func(e *Entity, button MouseButton) {
+				// button up
+				toolSelectorMode = (toolSelectorMode + 1) % (SelectorModePolygon + 1)
+				LeftTool = NewSelectionTool(toolSelectorMode)
+				RightTool = NewSelectionTool(toolSelectorMode)
+				ToolsUISetCurrentToolSelected(entity)
+			}, nil)
+		selectorModeBox := NewBox(rl.NewRectangle(0, 0, UIButtonHeight*1.5, UIButtonHeight), []*Entity{
+			selectorModeButton,
+		}, FlowDirectionVertical)
+		toolSettings.PushChild(selectorModeBox)
+
+		lt, ok := LeftTool.(*MagicWandTool)
+		if !ok {
+			break
+		}
+		rt, _ := RightTool.(*MagicWandTool)
+		setBoth := func(set func(t *MagicWandTool)) {
+			set(lt)
+			if rt != nil {
+				set(rt)
+			}
+		}
+
+		toleranceInput := NewInput(rl.NewRectangle(0, 0, UIButtonHeight*1.5, UIButtonHeight/2), fmt.Sprintf("%d", lt.GetTolerance()), TextAlignCenter, false,
+			func(entity *Entity, button MouseButton) {
+				// button up
+			},
+			nil,
+			func(entity *Entity, key Key) {
+				// key pressed
+				if drawable, ok := entity.GetDrawable(); ok {
+					if drawableText, ok := drawable.DrawableType.(*DrawableText); ok {
+						if key == rl.KeyBackspace && len(drawableText.Label) > 0 {
+							drawableText.Label = drawableText.Label[:len(drawableText.Label)-1]
+						} else if key >= 48 && key <= 57 && len(drawableText.Label) < 3 { // 0 to 9
+							drawableText.Label += string(rune(key))
+						}
+						if i, err := strconv.ParseInt(drawableText.Label, 10, 64); err == nil {
+							setBoth(func(t *MagicWandTool) { t.SetTolerance(int32(i)) })
+						}
+					}
+				}
+			})
+		if interactable, ok := toleranceInput.GetInteractable(); ok {
+			interactable.OnScroll = func(direction int32) {
+				setBoth(func(t *MagicWandTool) { t.SetTolerance(lt.GetTolerance() + direction) })
+				setTextLabel(toleranceInput, fmt.Sprintf("%d", lt.GetTolerance()))
+			}
+		}
+		selectorModeBox.PushChild(toleranceInput)
+
+		wandAreaButton := NewButtonText(rl.NewRectangle(0, 0, UIButtonHeight*2, UIButtonHeight/2), lt.GetArea().String(), TextAlignCenter, false,
+			func(e *Entity, button MouseButton) {
+				// button up
+				area := (lt.GetArea() + 1) % (WandAreaGlobal + 1)
+				setBoth(func(t *MagicWandTool) { t.SetArea(area) })
+				setTextLabel(e, area.String())
+			}, nil)
+		sampleLabel := func() string {
+			if lt.GetSampleMerged() {
+				return "merged"
+			}
+			return "layer"
+		}
+		wandSampleButton := NewButtonText(rl.NewRectangle(0, 0, UIButtonHeight*2, UIButtonHeight/2), sampleLabel(), TextAlignCenter, false,
+			func(e *Entity, button MouseButton) {
+				// button up
+				sampleMerged := !lt.GetSampleMerged()
+				setBoth(func(t *MagicWandTool) { t.SetSampleMerged(sampleMerged) })
+				setTextLabel(e, sampleLabel())
+			}, nil)
+		toolSettings.PushChild(NewBox(rl.NewRectangle(0, 0, UIButtonHeight*2, UIButtonHeight), []*Entity{
+			wandAreaButton,
+			wandSampleButton,
+		}, FlowDirectionVertical))
 	}
 
 	toolSettings.FlowChildren()
@@ -133,48 +236,40 @@ func NewToolsUI(bounds rl.Rectangle) *Entity {
 	// TODO allow right click to be replaced with selector if alt is pressed
 	toolPencil = NewButtonTexture(rl.NewRectangle(0, 0, UIButtonHeight, UIButtonHeight),
 		GetFile("./res/icons/pencil.png"), false, func(entity *Entity, button MouseButton) {
-			// Commit the selection, stop showing selection preview etc
-			if len(CurrentFile.Selection) > 0 {
-				CurrentFile.CommitSelection()
-			}
+			// Place a moving selection, what's selected masks the painting
+			CurrentFile.PlaceSelection()
 			LeftTool = NewPixelBrushTool("Pixel Brush", false)
 			RightTool = NewPixelBrushTool("Pixel Brush", false)
 			ToolsUISetCurrentToolSelected(entity)
 		}, nil)
 	toolEraser = NewButtonTexture(rl.NewRectangle(0, 0, UIButtonHeight, UIButtonHeight),
 		GetFile("./res/icons/eraser.png"), false, func(entity *Entity, button MouseButton) {
-			// Commit the selection, stop showing selection preview etc
-			if len(CurrentFile.Selection) > 0 {
-				CurrentFile.CommitSelection()
-			}
+			// Place a moving selection, what's selected masks the painting
+			CurrentFile.PlaceSelection()
 			LeftTool = NewPixelBrushTool("Eraser", true)
 			RightTool = NewPixelBrushTool("Eraser", true)
 			ToolsUISetCurrentToolSelected(entity)
 		}, nil)
 	toolFill = NewButtonTexture(rl.NewRectangle(0, 0, UIButtonHeight, UIButtonHeight),
 		GetFile("./res/icons/fill.png"), false, func(entity *Entity, button MouseButton) {
-			// Commit the selection, stop showing selection preview etc
-			if len(CurrentFile.Selection) > 0 {
-				CurrentFile.CommitSelection()
-			}
+			// Place a moving selection, what's selected masks the painting
+			CurrentFile.PlaceSelection()
 			LeftTool = NewFillTool("Fill")
 			RightTool = NewFillTool("Fill")
 			ToolsUISetCurrentToolSelected(entity)
 		}, nil)
 	toolPicker = NewButtonTexture(rl.NewRectangle(0, 0, UIButtonHeight, UIButtonHeight),
 		GetFile("./res/icons/picker.png"), false, func(entity *Entity, button MouseButton) {
-			// Commit the selection, stop showing selection preview etc
-			if len(CurrentFile.Selection) > 0 {
-				CurrentFile.CommitSelection()
-			}
+			// Place a moving selection, keeping what's selected
+			CurrentFile.PlaceSelection()
 			LeftTool = NewPickerTool("Picker")
 			RightTool = NewPickerTool("Picker")
 			ToolsUISetCurrentToolSelected(entity)
 		}, nil)
 	toolSelector = NewButtonTexture(rl.NewRectangle(0, 0, UIButtonHeight, UIButtonHeight),
 		GetFile("./res/icons/selector.png"), false, func(entity *Entity, button MouseButton) {
-			LeftTool = NewSelectorTool("Selector")
-			RightTool = NewSelectorTool("Selector")
+			LeftTool = NewSelectionTool(toolSelectorMode)
+			RightTool = NewSelectionTool(toolSelectorMode)
 			ToolsUISetCurrentToolSelected(entity)
 		}, nil)
 
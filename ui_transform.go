@@ -0,0 +1,166 @@
+package main
+
+import (
+	"fmt"
+
+	rl "github.com/gen2brain/raylib-go/raylib"
+)
+
+var (
+	transformButtons   *Entity
+	transformInputs    []*Entity
+	transformMode      *Entity
+	transformLockRatio *Entity
+
+	// transformUILockRatio keeps the width and height in proportion while a
+	// handle is dragged
+	transformUILockRatio bool
+	// transformUIUnused is written to by the inputs when nothing is being
+	// transformed
+	transformUIUnused int32
+
+	transformLabelWidth   = UIFontSize * 8
+	transformControlWidth = UIFontSize * 20
+)
+
+// TransformUIShowDialog starts transforming the selection and shows the
+// dialog with its position, size and rotation
+func TransformUIShowDialog() {
+	if CurrentFile.BeginSelectionTransform() == nil {
+		UIShowError(fmt.Errorf("Nothing is selected"))
+		return
+	}
+	transformButtons.Show()
+	TransformUIRefresh()
+}
+
+// TransformUIHideDialog hides the dialog, the selection stays transformed
+// until it's committed
+func TransformUIHideDialog() {
+	transformButtons.Hide()
+}
+
+// transformUIParams returns the transform being edited, or a throwaway one
+func transformUIParams() *TransformParams {
+	if CurrentFile.SelectionTransform == nil {
+		return &TransformParams{}
+	}
+	return &CurrentFile.SelectionTransform.Params
+}
+
+// TransformUIRefresh shows the transform in the controls
+func TransformUIRefresh() {
+	if transformButtons == nil {
+		return
+	}
+	if drawable, ok := transformButtons.GetDrawable(); !ok || drawable.Hidden {
+		return
+	}
+
+	params := transformUIParams()
+	values := []int32{params.X, params.Y, params.Width, params.Height, params.Rotation}
+	for i, input := range transformInputs {
+		setTextLabel(input, fmt.Sprint(values[i]))
+	}
+	setTextLabel(transformMode, params.Mode.String())
+	setTextLabel(transformLockRatio, fmt.Sprint(transformUILockRatio))
+}
+
+// NewTransformUI returns the dialog for setting the exact position, size and
+// rotation of the selection, and how it's resampled
+func NewTransformUI() *Entity {
+	cx := rl.GetScreenWidth() / 2
+	cy := rl.GetScreenHeight() / 2
+
+	width := transformLabelWidth + transformControlWidth
+	bounds := rl.NewRectangle(
+		float32(cx)-width/2,
+		float32(cy)-UIButtonHeight*6,
+		width,
+		UIButtonHeight*12,
+	)
+
+	makeButton := func(onMouseUp func()) *Entity {
+		return NewButtonText(
+			rl.NewRectangle(0, 0, transformControlWidth, UIButtonHeight),
+			"", TextAlignCenter, false, func(entity *Entity, button MouseButton) {
+				onMouseUp()
+				TransformUIRefresh()
+			}, nil)
+	}
+	makeRow := func(label string, control *Entity) *Entity {
+		return NewBox(rl.NewRectangle(0, 0, width, UIButtonHeight), []*Entity{
+			NewButtonText(rl.NewRectangle(0, 0, transformLabelWidth, UIButtonHeight),
+				label, TextAlignLeft, false, nil, nil),
+			control,
+		}, FlowDirectionHorizontal)
+	}
+	// Inputs which write to nothing if the transform has ended
+	bind := func(field func(params *TransformParams) *int32) func() *int32 {
+		return func() *int32 {
+			if CurrentFile.SelectionTransform == nil {
+				return &transformUIUnused
+			}
+			return field(&CurrentFile.SelectionTransform.Params)
+		}
+	}
+
+	transformMode = makeButton(func() {
+		params := transformUIParams()
+		params.Mode = (params.Mode + 1) % (ScaleModeXBR + 1)
+	})
+	transformLockRatio = makeButton(func() {
+		transformUILockRatio = !transformUILockRatio
+	})
+	flipHorizontal := makeButton(func() { CurrentFile.FlipHorizontal() })
+	setTextLabel(flipHorizontal, "flip horizontal")
+	flipVertical := makeButton(func() { CurrentFile.FlipVertical() })
+	setTextLabel(flipVertical, "flip vertical")
+
+	// Made in reverse so that each can tab to the next
+	rotationInput := ResizeUIMakeInput(bind(func(p *TransformParams) *int32 { return &p.Rotation }), nil)
+	heightInput := ResizeUIMakeInput(bind(func(p *TransformParams) *int32 { return &p.Height }), rotationInput)
+	widthInput := ResizeUIMakeInput(bind(func(p *TransformParams) *int32 { return &p.Width }), heightInput)
+	yInput := ResizeUIMakeInput(bind(func(p *TransformParams) *int32 { return &p.Y }), widthInput)
+	xInput := ResizeUIMakeInput(bind(func(p *TransformParams) *int32 { return &p.X }), yInput)
+	transformInputs = []*Entity{xInput, yInput, widthInput, heightInput, rotationInput}
+
+	closeTransformButton := NewButtonText(
+		rl.NewRectangle(0, 0, UIButtonHeight, UIButtonHeight),
+		"X", TextAlignCenter, false, func(entity *Entity, button MouseButton) {
+			TransformUIHideDialog()
+		}, nil)
+	applyButton := NewButtonText(
+		rl.NewRectangle(0, 0, width, UIButtonHeight),
+		"apply", TextAlignCenter, false, func(entity *Entity, button MouseButton) {
+			CurrentFile.PlaceSelection()
+			TransformUIHideDialog()
+		}, nil)
+
+	transformButtons = NewBox(
+		bounds,
+		[]*Entity{
+			NewBox(rl.NewRectangle(0, 0, width, UIButtonHeight), []*Entity{
+				closeTransformButton,
+				NewButtonText(rl.NewRectangle(0, 0, width-UIButtonHeight, UIButtonHeight),
+					"transform", TextAlignCenter, false, nil, nil),
+			}, FlowDirectionHorizontal),
+			makeRow("x", xInput),
+			makeRow("y", yInput),
+			makeRow("width", widthInput),
+			makeRow("height", heightInput),
+			makeRow("rotation", rotationInput),
+			makeRow("scale mode", transformMode),
+			makeRow("lock ratio", transformLockRatio),
+			makeRow("", flipHorizontal),
+			makeRow("", flipVertical),
+			applyButton,
+		},
+		FlowDirectionVertical,
+	)
+	transformButtons.FlowChildren()
+
+	transformButtons.Hide()
+
+	return transformButtons
+}
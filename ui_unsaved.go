@@ -0,0 +1,188 @@
+package main
+
+import (
+	"fmt"
+
+	rl "github.com/gen2brain/raylib-go/raylib"
+)
+
+var (
+	unsavedButtons *Entity
+	unsavedLabel   *Entity
+	// unsavedFiles are the files which still need to be saved or discarded
+	unsavedFiles  []*File
+	unsavedOnDone func()
+
+	// pendingSaveAs is called once the save dialog opened by saveFile closes
+	pendingSaveAs func(saved bool)
+
+	// ShouldQuit ends the main loop when true
+	ShouldQuit = false
+)
+
+// UnsavedUIShowDialog shows the dialog
+func UnsavedUIShowDialog() {
+	unsavedButtons.Show()
+}
+
+// UnsavedUIHideDialog hides the dialog
+func UnsavedUIHideDialog() {
+	unsavedButtons.Hide()
+}
+
+// UnsavedUIConfirm asks to save or discard each of the files with unsaved
+// changes, then calls onDone. onDone isn't called if cancel is pressed
+func UnsavedUIConfirm(files []*File, onDone func()) {
+	unsavedFiles = make([]*File, 0, len(files))
+	for _, file := range files {
+		if file.FileChanged {
+			unsavedFiles = append(unsavedFiles, file)
+		}
+	}
+	unsavedOnDone = onDone
+	unsavedUIAskNext()
+}
+
+// unsavedUIAskNext switches to the next unsaved file and asks about it, or
+// calls unsavedOnDone if there aren't any left
+func unsavedUIAskNext() {
+	if len(unsavedFiles) == 0 {
+		UnsavedUIHideDialog()
+		onDone := unsavedOnDone
+		unsavedOnDone = nil
+		if onDone != nil {
+			onDone()
+		}
+		return
+	}
+
+	CurrentFile = unsavedFiles[0]
+	EditorsUIRebuild()
+
+	if drawable, ok := unsavedLabel.GetDrawable(); ok {
+		if drawableText, ok := drawable.DrawableType.(*DrawableText); ok {
+			drawableText.Label = fmt.Sprintf("save changes to %s?", CurrentFile.Filename)
+		}
+	}
+	UnsavedUIShowDialog()
+}
+
+// saveFile saves the file to where it was last saved or opened from, or asks
+// where to save it if it hasn't been saved yet. done is called once the file
+// has been saved or saving has failed or been cancelled
+func saveFile(file *File, done func(saved bool)) {
+	if len(file.FileDir) > 0 {
+		if err := file.SaveAs(file.FileDir); err != nil {
+			UIShowError(err)
+			done(false)
+			return
+		}
+		done(true)
+		return
+	}
+
+	// The save dialog saves CurrentFile
+	CurrentFile = file
+	EditorsUIRebuild()
+	pendingSaveAs = done
+	UISaveAs()
+}
+
+// UIFinishSaveAs is called by the control system when the save dialog closes
+func UIFinishSaveAs(saved bool) {
+	if pendingSaveAs != nil {
+		done := pendingSaveAs
+		pendingSaveAs = nil
+		done(saved)
+	}
+}
+
+// UISaveAll saves every file with unsaved changes, stopping if one can't be
+// saved
+func UISaveAll() {
+	files := make([]*File, 0, len(Files))
+	for _, file := range Files {
+		if file.FileChanged {
+			files = append(files, file)
+		}
+	}
+
+	var saveNext func(i int)
+	saveNext = func(i int) {
+		if i >= len(files) {
+			return
+		}
+		saveFile(files[i], func(saved bool) {
+			if saved {
+				saveNext(i + 1)
+			}
+		})
+	}
+	saveNext(0)
+}
+
+// UIQuit asks about any unsaved files before quitting
+func UIQuit() {
+	UnsavedUIConfirm(Files, func() {
+		ShouldQuit = true
+	})
+}
+
+// NewUnsavedUI returns the dialog which asks to save, discard or cancel when a
+// file with unsaved changes is closed
+func NewUnsavedUI() *Entity {
+	cx := rl.GetScreenWidth() / 2
+	cy := rl.GetScreenHeight() / 2
+
+	width := float32(UIFontSize * 2 * 15)
+	bounds := rl.NewRectangle(
+		float32(cx)-width/2,
+		float32(cy)-UIButtonHeight,
+		width,
+		UIButtonHeight*2,
+	)
+
+	unsavedLabel = NewButtonText(
+		rl.NewRectangle(0, 0, width, UIButtonHeight),
+		"", TextAlignCenter, false, nil, nil)
+
+	makeButton := func(label string, onMouseUp func()) *Entity {
+		return NewButtonText(
+			rl.NewRectangle(0, 0, width/3, UIButtonHeight),
+			label, TextAlignCenter, false, func(entity *Entity, button MouseButton) {
+				onMouseUp()
+			}, nil)
+	}
+
+	unsavedButtons = NewBox(
+		bounds,
+		[]*Entity{
+			unsavedLabel,
+			makeButton("save", func() {
+				UnsavedUIHideDialog()
+				saveFile(unsavedFiles[0], func(saved bool) {
+					if saved {
+						unsavedFiles = unsavedFiles[1:]
+					}
+					unsavedUIAskNext()
+				})
+			}),
+			makeButton("discard", func() {
+				unsavedFiles[0].RemoveAutosave()
+				unsavedFiles = unsavedFiles[1:]
+				unsavedUIAskNext()
+			}),
+			makeButton("cancel", func() {
+				UnsavedUIHideDialog()
+				unsavedFiles = nil
+				unsavedOnDone = nil
+			}),
+		},
+		FlowDirectionHorizontal,
+	)
+	unsavedButtons.FlowChildren()
+
+	UnsavedUIHideDialog()
+
+	return unsavedButtons
+}
@@ -112,12 +112,32 @@ func BlendWithOpacity(a, b rl.Color, blendMode rl.BlendMode) rl.Color {
 			B: AddAndClampUint8(a.B, b.B), // TODO reduce value by alpha
 		}
 	case rl.BlendMultiplied:
+		return blendChannels(a, b, func(x, y uint8) uint8 {
+			return scaleAlpha(x, y)
+		})
 	case rl.BlendSubtractColors:
+		return blendChannels(a, b, func(x, y uint8) uint8 {
+			return uint8(MaxInt32(int32(x)-int32(y), 0))
+		})
 	}
 
 	return b
 }
 
+// blendChannels blends b over a by combining their channels with op. The
+// more transparent b is, the less a changes
+func blendChannels(a, b rl.Color, op func(x, y uint8) uint8) rl.Color {
+	mix := func(x, y uint8) uint8 {
+		return uint8(int32(x) + (int32(op(x, y))-int32(x))*int32(b.A)/255)
+	}
+	return rl.Color{
+		A: AddAndClampUint8(a.A, scaleAlpha(b.A, 255-a.A)),
+		R: mix(a.R, b.R),
+		G: mix(a.G, b.G),
+		B: mix(a.B, b.B),
+	}
+}
+
 // ColorToHex converts an rl.Color into a hex string
 func ColorToHex(color rl.Color) string {
 	return fmt.Sprintf("%02x%02x%02x%02x", color.R, color.G, color.B, color.A)
@@ -0,0 +1,27 @@
+package main
+
+import (
+	"testing"
+
+	rl "github.com/gen2brain/raylib-go/raylib"
+)
+
+// TestBlendWithOpacity checks the blend modes which combine colors with the
+// ones underneath
+func TestBlendWithOpacity(t *testing.T) {
+	under := rl.NewColor(200, 100, 50, 255)
+	for _, test := range []struct {
+		blendMode rl.BlendMode
+		over      rl.Color
+		want      rl.Color
+	}{
+		{rl.BlendMultiplied, rl.NewColor(255, 0, 255, 255), rl.NewColor(200, 0, 50, 255)},
+		{rl.BlendMultiplied, rl.NewColor(0, 0, 0, 0), under},
+		{rl.BlendSubtractColors, rl.NewColor(100, 200, 25, 255), rl.NewColor(100, 0, 25, 255)},
+		{rl.BlendSubtractColors, rl.NewColor(100, 200, 50, 51), rl.NewColor(180, 80, 40, 255)},
+	} {
+		if got := BlendWithOpacity(under, test.over, test.blendMode); got != test.want {
+			t.Errorf("Blending %v over %v with %d gave %v, want %v", test.over, under, test.blendMode, got, test.want)
+		}
+	}
+}
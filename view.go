@@ -0,0 +1,236 @@
+package main
+
+import (
+	"fmt"
+	"math"
+
+	rl "github.com/gen2brain/raylib-go/raylib"
+)
+
+var (
+	// The panels around the canvas, used to find the space it's shown in
+	viewLeftPanel, viewRightPanel, viewTopBar *Entity
+
+	// rulerSize is the thickness of the rulers
+	rulerSize = UIFontSize
+	// rulerTickSpacing is the minimum space between numbered ticks
+	rulerTickSpacing = UIFontSize * 3
+)
+
+// ViewArea returns the part of the screen the canvas can be seen in, between
+// the panels
+func ViewArea() rl.Rectangle {
+	area := rl.NewRectangle(0, 0, float32(rl.GetScreenWidth()), float32(rl.GetScreenHeight()))
+	if viewTopBar != nil {
+		if moveable, ok := viewTopBar.GetMoveable(); ok {
+			area.Y = moveable.Bounds.Y + moveable.Bounds.Height
+		}
+	}
+	if viewLeftPanel != nil {
+		if moveable, ok := viewLeftPanel.GetMoveable(); ok {
+			area.X = moveable.Bounds.X + moveable.Bounds.Width
+		}
+	}
+	right := area.Width
+	if viewRightPanel != nil {
+		if moveable, ok := viewRightPanel.GetMoveable(); ok {
+			right = moveable.Bounds.X
+		}
+	}
+	area.Width = right - area.X
+	area.Height -= area.Y
+	return area
+}
+
+// setCameraTarget moves the camera, keeping at least UIButtonHeight of the
+// canvas on the screen if the pan is limited
+func (f *File) setCameraTarget(target rl.Vector2) {
+	if Settings.ViewData.LimitPan {
+		clamp := func(v, canvasSize, offset float32) float32 {
+			limit := float64(canvasSize/2 + (offset-UIButtonHeight)/f.FileCamera.Zoom)
+			return float32(math.Max(-limit, math.Min(limit, float64(v))))
+		}
+		target.X = clamp(target.X, float32(f.CanvasWidth), f.FileCamera.Offset.X)
+		target.Y = clamp(target.Y, float32(f.CanvasHeight), f.FileCamera.Offset.Y)
+	}
+	f.FileCameraTarget = target
+	f.FileCamera.Target = target
+}
+
+// Pan moves the camera by an amount of screen pixels
+func (f *File) Pan(dx, dy float32) {
+	f.setCameraTarget(rl.NewVector2(
+		f.FileCameraTarget.X+dx/f.FileCamera.Zoom,
+		f.FileCameraTarget.Y+dy/f.FileCamera.Zoom,
+	))
+}
+
+// ZoomAt sets the zoom, keeping what's under the screen position in place
+func (f *File) ZoomAt(zoom float32, screen rl.Vector2) {
+	world := rl.GetScreenToWorld2D(screen, f.FileCamera)
+	f.FileCamera.Zoom = zoom
+	f.setCameraTarget(rl.NewVector2(
+		world.X-(screen.X-f.FileCamera.Offset.X)/zoom,
+		world.Y-(screen.Y-f.FileCamera.Offset.Y)/zoom,
+	))
+}
+
+// ZoomStep zooms in to the next zoom step, or out to the previous one if
+// steps is negative
+func (f *File) ZoomStep(steps int, screen rl.Vector2) {
+	zoomSteps := Settings.ViewData.ZoomSteps
+	zoom := f.FileCamera.Zoom
+	for ; steps > 0; steps-- {
+		for _, step := range zoomSteps {
+			if step > zoom {
+				zoom = step
+				break
+			}
+		}
+	}
+	for ; steps < 0; steps++ {
+		for i := len(zoomSteps) - 1; i >= 0; i-- {
+			if zoomSteps[i] < zoom {
+				zoom = zoomSteps[i]
+				break
+			}
+		}
+	}
+	f.ZoomAt(zoom, screen)
+}
+
+// viewCenter returns the center of the view area
+func viewCenter() rl.Vector2 {
+	area := ViewArea()
+	return rl.NewVector2(area.X+area.Width/2, area.Y+area.Height/2)
+}
+
+// centerCanvas moves the camera so that the canvas is in the middle of the
+// view area
+func (f *File) centerCanvas() {
+	center := viewCenter()
+	f.setCameraTarget(rl.NewVector2(
+		-(center.X-f.FileCamera.Offset.X)/f.FileCamera.Zoom,
+		-(center.Y-f.FileCamera.Offset.Y)/f.FileCamera.Zoom,
+	))
+}
+
+// ZoomFit zooms so the whole canvas fits in the view area. The zoom is kept
+// to whole numbers unless the canvas is larger than the view, and doesn't go
+// below the smallest zoom step
+func (f *File) ZoomFit() {
+	area := ViewArea()
+	zoom := math.Min(
+		float64(area.Width-rulerSize*2)/float64(f.CanvasWidth),
+		float64(area.Height-rulerSize*2)/float64(f.CanvasHeight),
+	)
+	if zoom >= 1 {
+		zoom = math.Floor(zoom)
+	}
+	if zoomSteps := Settings.ViewData.ZoomSteps; len(zoomSteps) > 0 {
+		zoom = math.Max(zoom, float64(zoomSteps[0]))
+	}
+	f.FileCamera.Zoom = float32(zoom)
+	f.centerCanvas()
+}
+
+// ZoomActual shows the canvas at 100%
+func (f *File) ZoomActual() {
+	f.FileCamera.Zoom = 1
+	f.centerCanvas()
+}
+
+// DrawPixelGrid draws a line between every pixel when zoomed in far enough.
+// It's drawn in world space
+func DrawPixelGrid(f *File) {
+	view := Settings.ViewData
+	if !view.PixelGrid || f.FileCamera.Zoom < view.PixelGridMinZoom {
+		return
+	}
+	color, err := HexToColor(view.PixelGridColor)
+	if err != nil {
+		color = rl.NewColor(255, 255, 255, 48)
+	}
+
+	for x := int32(1); x < f.CanvasWidth; x++ {
+		rl.DrawLine(-f.CanvasWidth/2+x, -f.CanvasHeight/2, -f.CanvasWidth/2+x, f.CanvasHeight/2, color)
+	}
+	for y := int32(1); y < f.CanvasHeight; y++ {
+		rl.DrawLine(-f.CanvasWidth/2, -f.CanvasHeight/2+y, f.CanvasWidth/2, -f.CanvasHeight/2+y, color)
+	}
+}
+
+// rulerStep returns how many pixels apart the numbered ticks are, 1, 2 or 5
+// times a power of 10 so that they don't overlap. The largest step is used if
+// none are far enough apart
+func rulerStep(zoom float32) int32 {
+	step := int32(1)
+	for ; step < math.MaxInt32/50; step *= 10 {
+		for _, m := range []int32{1, 2, 5} {
+			if float32(step*m)*zoom >= rulerTickSpacing {
+				return step * m
+			}
+		}
+	}
+	return step
+}
+
+// DrawRulers draws the pixel coordinates of the canvas along the top and left
+// of the view area, with the cursor's position marked. It's drawn in screen
+// space
+func DrawRulers(f *File, cursor rl.Vector2) {
+	if !Settings.ViewData.Rulers {
+		return
+	}
+
+	area := ViewArea()
+	step := rulerStep(f.FileCamera.Zoom)
+	fontSize := rulerSize * 0.6
+	origin := rl.GetWorldToScreen2D(
+		rl.NewVector2(-float32(f.CanvasWidth)/2, -float32(f.CanvasHeight)/2),
+		f.FileCamera)
+	zoom := f.FileCamera.Zoom
+
+	top := rl.NewRectangle(area.X, area.Y, area.Width, rulerSize)
+	left := rl.NewRectangle(area.X, area.Y, rulerSize, area.Height)
+	rl.DrawRectangleRec(top, rl.Black)
+	rl.DrawRectangleRec(left, rl.Black)
+
+	// The first tick which is on the screen
+	first := func(originPos, areaPos float32) int32 {
+		return int32(math.Floor(float64((areaPos-originPos)/zoom/float32(step)))) * step
+	}
+
+	for px := first(origin.X, area.X); ; px += step {
+		x := origin.X + float32(px)*zoom
+		if x > area.X+area.Width {
+			break
+		}
+		if x < area.X+rulerSize {
+			continue
+		}
+		rl.DrawLineV(rl.NewVector2(x, area.Y), rl.NewVector2(x, area.Y+rulerSize), rl.Gray)
+		rl.DrawTextEx(Font, fmt.Sprint(px), rl.NewVector2(x+2, area.Y), fontSize, 1, rl.White)
+	}
+	for py := first(origin.Y, area.Y); ; py += step {
+		y := origin.Y + float32(py)*zoom
+		if y > area.Y+area.Height {
+			break
+		}
+		if y < area.Y+rulerSize {
+			continue
+		}
+		rl.DrawLineV(rl.NewVector2(area.X, y), rl.NewVector2(area.X+rulerSize, y), rl.Gray)
+		rl.DrawTextEx(Font, fmt.Sprint(py), rl.NewVector2(area.X+2, y+2), fontSize, 1, rl.White)
+	}
+
+	// Cursor position
+	cx := origin.X + float32(math.Floor(float64(cursor.X)))*zoom
+	cy := origin.Y + float32(math.Floor(float64(cursor.Y)))*zoom
+	if cx >= area.X+rulerSize && cx <= area.X+area.Width {
+		rl.DrawRectangleRec(rl.NewRectangle(cx, area.Y, float32(math.Max(float64(zoom), 1)), rulerSize), rl.NewColor(255, 255, 255, 96))
+	}
+	if cy >= area.Y+rulerSize && cy <= area.Y+area.Height {
+		rl.DrawRectangleRec(rl.NewRectangle(area.X, cy, rulerSize, float32(math.Max(float64(zoom), 1))), rl.NewColor(255, 255, 255, 96))
+	}
+}
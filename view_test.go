@@ -0,0 +1,18 @@
+package main
+
+import "testing"
+
+// TestRulerStep checks that the ticks are far enough apart, and that there's
+// a step for zooms which are too small to space them out
+func TestRulerStep(t *testing.T) {
+	for _, zoom := range []float32{64, 1, 0.01} {
+		if step := rulerStep(zoom); float32(step)*zoom < rulerTickSpacing {
+			t.Errorf("The step at zoom %v is %d, which is too close", zoom, step)
+		}
+	}
+	for _, zoom := range []float32{0, -1} {
+		if step := rulerStep(zoom); step <= 0 {
+			t.Errorf("The step at zoom %v is %d", zoom, step)
+		}
+	}
+}